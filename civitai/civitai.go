@@ -0,0 +1,147 @@
+package civitai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	apiURL   = "https://civitai.com/api/v1"
+	modelURL = "https://civitai.com/models/%d?modelVersionId=%d"
+
+	requestTimeout = 10 * time.Second
+)
+
+// ErrNotFound is returned for hashes CivitAI doesn't know, e.g. of models that were never uploaded there
+var ErrNotFound = errors.New("not found on CivitAI")
+
+type apiImpl struct {
+	client *http.Client
+	apiKey string
+}
+
+type Config struct {
+	// API key for authenticated requests, optional for the public endpoints
+	APIKey string
+}
+
+func New(cfg Config) (CivitAI, error) {
+	return &apiImpl{
+		client: &http.Client{Timeout: requestTimeout},
+		apiKey: cfg.APIKey,
+	}, nil
+}
+
+type CivitAIModel struct {
+	ModelID   int64
+	VersionID int64
+	// Name of the model, e.g. "Realistic Vision"
+	Name string
+	// Name of the version, e.g. "V5.1"
+	VersionName string
+	// e.g. "SD 1.5" or "SDXL 1.0"
+	BaseModel string
+	// Username of the creator, empty when the model details can't be fetched
+	Author string
+	URL    string
+}
+
+type jsonModelVersion struct {
+	ID        int64  `json:"id"`
+	ModelID   int64  `json:"modelId"`
+	Name      string `json:"name"`
+	BaseModel string `json:"baseModel"`
+	Model     struct {
+		Name string `json:"name"`
+	} `json:"model"`
+}
+
+type jsonModel struct {
+	Creator struct {
+		Username string `json:"username"`
+	} `json:"creator"`
+}
+
+// GetModelByHash looks up the model version by a file hash, e.g. the short hash of the checkpoint
+// shown by the webui
+func (api *apiImpl) GetModelByHash(hash string) (*CivitAIModel, error) {
+	version := &jsonModelVersion{}
+
+	err := api.get("/model-versions/by-hash/"+url.PathEscape(hash), version)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &CivitAIModel{
+		ModelID:     version.ModelID,
+		VersionID:   version.ID,
+		Name:        version.Model.Name,
+		VersionName: version.Name,
+		BaseModel:   version.BaseModel,
+		URL:         fmt.Sprintf(modelURL, version.ModelID, version.ID),
+	}
+
+	// The creator is only returned with the model
+	details := &jsonModel{}
+
+	err = api.get(fmt.Sprintf("/models/%d", version.ModelID), details)
+	if err != nil {
+		log.Printf("Error getting CivitAI model %d: %v", version.ModelID, err)
+	} else {
+		model.Author = details.Creator.Username
+	}
+
+	return model, nil
+}
+
+func (api *apiImpl) get(path string, result interface{}) error {
+	getURL := apiURL + path
+
+	request, err := http.NewRequest("GET", getURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if api.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+api.apiKey)
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected CivitAI API status %d: %s", response.StatusCode, body)
+	}
+
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return err
+	}
+
+	return nil
+}
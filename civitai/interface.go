@@ -0,0 +1,5 @@
+package civitai
+
+type CivitAI interface {
+	GetModelByHash(hash string) (*CivitAIModel, error)
+}
@@ -6,6 +6,7 @@ import "time"
 
 type Clock interface {
 	Now() time.Time
+	After(d time.Duration) <-chan time.Time
 }
 
 type realClock struct{}
@@ -14,6 +15,10 @@ func (realClock) Now() time.Time {
 	return time.Now()
 }
 
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
 func NewClock() Clock {
 	return &realClock{}
 }
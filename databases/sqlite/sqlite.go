@@ -85,6 +85,58 @@ CREATE INDEX IF NOT EXISTS member_id_idx
 ON statistics(member_id, created_at);
 `
 
+const addTokenMergingRatioToDefaultSettingsQuery string = `
+ALTER TABLE default_settings ADD COLUMN token_merging_ratio REAL NOT NULL DEFAULT 0;
+`
+
+// The ratio is a guild setting now, see settings.KeyTokenMergingRatio
+const dropTokenMergingRatioFromDefaultSettingsQuery string = `
+ALTER TABLE default_settings DROP COLUMN token_merging_ratio;
+`
+
+const createUserSettingsTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS user_settings (
+guild_id TEXT NOT NULL,
+member_id TEXT NOT NULL,
+key TEXT NOT NULL,
+value TEXT NOT NULL,
+PRIMARY KEY (guild_id, member_id, key)
+);`
+
+const addPinnedColumnQuery string = `
+ALTER TABLE image_generations ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+`
+
+const addStatisticsServerIDColumnQuery string = `
+ALTER TABLE statistics ADD COLUMN server_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS server_id_idx
+ON statistics(server_id, member_id);
+`
+
+const createNotificationPreferencesTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS notification_preferences (
+member_id TEXT NOT NULL PRIMARY KEY,
+dm_enabled INTEGER NOT NULL
+);`
+
+const addGenerationDeletedColumnQuery string = `
+ALTER TABLE image_generations ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0;
+`
+
+// Rows added before the column stay NULL, so they don't count as zero wait
+const addStatisticsQueueWaitColumnQuery string = `
+ALTER TABLE statistics ADD COLUMN queue_wait_ms INTEGER;
+`
+
+const addStatisticsBonusPointsColumnQuery string = `
+ALTER TABLE statistics ADD COLUMN bonus_points INTEGER NOT NULL DEFAULT 0;
+`
+
+const addGenerationLineageColumnsQuery string = `
+ALTER TABLE image_generations ADD COLUMN source_message_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE image_generations ADD COLUMN root_message_id TEXT NOT NULL DEFAULT '';
+`
+
 type migration struct {
 	migrationName  string
 	migrationQuery string
@@ -99,6 +151,16 @@ var migrations = []migration{
 	{migrationName: "add hires resize columns", migrationQuery: addHiresResizeColumnsQuery},
 	{migrationName: "create default settings table", migrationQuery: createDefaultSettingsTableIfNotExistsQuery},
 	{migrationName: "create statistics table", migrationQuery: createStatisticsTable},
+	{migrationName: "add token merging ratio to default settings", migrationQuery: addTokenMergingRatioToDefaultSettingsQuery},
+	{migrationName: "create user settings table", migrationQuery: createUserSettingsTableIfNotExistsQuery},
+	{migrationName: "add generation pinned column", migrationQuery: addPinnedColumnQuery},
+	{migrationName: "add statistics server id column", migrationQuery: addStatisticsServerIDColumnQuery},
+	{migrationName: "create notification preferences table", migrationQuery: createNotificationPreferencesTableIfNotExistsQuery},
+	{migrationName: "add generation deleted column", migrationQuery: addGenerationDeletedColumnQuery},
+	{migrationName: "add statistics queue wait column", migrationQuery: addStatisticsQueueWaitColumnQuery},
+	{migrationName: "add statistics bonus points column", migrationQuery: addStatisticsBonusPointsColumnQuery},
+	{migrationName: "add generation lineage columns", migrationQuery: addGenerationLineageColumnsQuery},
+	{migrationName: "drop token merging ratio from default settings", migrationQuery: dropTokenMergingRatioFromDefaultSettingsQuery},
 }
 
 func New(ctx context.Context, dbFilePrefix string) (*sql.DB, error) {
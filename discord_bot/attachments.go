@@ -0,0 +1,30 @@
+package discord_bot
+
+import (
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// attachmentOption returns the attachment passed in the command option
+func attachmentOption(i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) (*discordgo.MessageAttachment, error) {
+	attachmentID, ok := opt.Value.(string)
+	if !ok {
+		return nil, errors.New("invalid attachment option")
+	}
+
+	resolved := i.ApplicationCommandData().Resolved
+	if resolved == nil || resolved.Attachments[attachmentID] == nil {
+		return nil, fmt.Errorf("attachment %s not found", attachmentID)
+	}
+
+	return resolved.Attachments[attachmentID], nil
+}
+
+// downloadAttachmentBase64 downloads the attachment and returns its contents encoded in base64
+func downloadAttachmentBase64(attachment *discordgo.MessageAttachment) (string, error) {
+	return imagine_queue.DownloadImageBase64(attachment.URL)
+}
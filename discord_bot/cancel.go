@@ -0,0 +1,81 @@
+package discord_bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	cancelOptionPosition = `position`
+)
+
+func (b *botImpl) addImagineCancelCommand() error {
+	command := b.imagineCancelCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	minPosition := 1.0
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Remove your request from the queue before it starts",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        cancelOptionPosition,
+				Description: "Position in the queue, your last request when empty",
+				Required:    false,
+				MinValue:    &minPosition,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineCancelCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	position := 0
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == cancelOptionPosition {
+			position = int(opt.IntValue())
+		}
+	}
+
+	userID := interactionUser(i).ID
+
+	// Admins can remove anyone's request by its position
+	if position > 0 && hasPermission(i, discordgo.PermissionManageServer) {
+		userID = ""
+	}
+
+	err := b.imagineQueue.Cancel(userID, position)
+
+	switch {
+	case errors.Is(err, imagine_queue.ErrNoPendingItem) && position == 0:
+		respondEphemeral(s, i, "You have no requests waiting in the queue.")
+	case errors.Is(err, imagine_queue.ErrNoPendingItem):
+		respondEphemeral(s, i, fmt.Sprintf("There is no request waiting at position #%d.", position))
+	case errors.Is(err, imagine_queue.ErrNotItemOwner):
+		respondEphemeral(s, i, fmt.Sprintf("The request at position #%d isn't yours.", position))
+	case err != nil:
+		log.Printf("Error cancelling queue item: %v", err)
+
+		respondEphemeral(s, i, "Error cancelling the request...")
+	case position == 0:
+		respondEphemeral(s, i, "Your last request was removed from the queue.")
+	default:
+		respondEphemeral(s, i, fmt.Sprintf("The request at position #%d was removed from the queue.", position))
+	}
+}
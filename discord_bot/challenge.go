@@ -0,0 +1,125 @@
+package discord_bot
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Challenge is a prompt template of the daily prompt challenge
+type Challenge struct {
+	Prompt string `json:"prompt"`
+	// A prompt enters the challenge when it contains all keywords
+	Keywords []string `json:"keywords"`
+}
+
+//go:embed challenges.json
+var challengesJSON []byte
+
+var challenges []*Challenge
+
+// Discord archives the submission thread after a day without messages
+const challengeThreadArchiveMinutes = 1440
+
+func init() {
+	err := json.Unmarshal(challengesJSON, &challenges)
+	if err != nil {
+		log.Fatalf("Error parsing prompt challenges: %v", err)
+	}
+
+	if len(challenges) == 0 {
+		log.Fatal("No prompt challenges defined")
+	}
+}
+
+// challengeForDate picks the challenge of the UTC day of t, it is the same for every call on that day
+func challengeForDate(t time.Time) *Challenge {
+	year, month, day := t.UTC().Date()
+	rng := rand.New(rand.NewSource(int64(year*10000 + int(month)*100 + day)))
+
+	return challenges[rng.Intn(len(challenges))]
+}
+
+// matches reports whether the prompt contains all keywords of the challenge, ignoring case
+func (c *Challenge) matches(prompt string) bool {
+	prompt = strings.ToLower(prompt)
+
+	for _, keyword := range c.Keywords {
+		if !strings.Contains(prompt, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isChallengeEntry reports whether the prompt enters today's challenge, which requires a challenge channel
+func (b *botImpl) isChallengeEntry(prompt string) bool {
+	return b.challengeChannelID != "" && challengeForDate(b.clock.Now()).matches(prompt)
+}
+
+func (b *botImpl) addImagineChallengeCommand() error {
+	command := b.imagineChallengeCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Post today's prompt challenge (administrators only)",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineChallengeCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only administrators can post the prompt challenge.")
+
+		return
+	}
+
+	if b.challengeChannelID == "" {
+		respondEphemeral(s, i, "The challenge channel is not configured.")
+
+		return
+	}
+
+	now := b.clock.Now().UTC()
+	challenge := challengeForDate(now)
+
+	message, err := s.ChannelMessageSend(b.challengeChannelID,
+		fmt.Sprintf("Today's prompt challenge: %s\nReply with your images in the thread, "+
+			"prompts containing `%s` earn bonus points.",
+			challenge.Prompt, strings.Join(challenge.Keywords, "`, `")))
+	if err != nil {
+		log.Printf("Error posting the prompt challenge: %v", err)
+
+		respondEphemeral(s, i, "Error posting the prompt challenge...")
+
+		return
+	}
+
+	thread, err := s.MessageThreadStart(b.challengeChannelID, message.ID,
+		"Prompt challenge "+now.Format("2006-01-02"), challengeThreadArchiveMinutes)
+	if err != nil {
+		log.Printf("Error creating the prompt challenge thread: %v", err)
+
+		respondEphemeral(s, i, "The prompt challenge was posted, but the submission thread could not be created.")
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Posted today's prompt challenge, submissions go to <#%s>.", thread.ID))
+}
@@ -0,0 +1,54 @@
+package discord_bot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelAllowed reports whether commands may be used in the channel. Threads count as their parent channel,
+// e.g. the prompt challenge thread. All channels are allowed when none are configured
+func (b *botImpl) channelAllowed(s *discordgo.Session, channelID string) bool {
+	if len(b.allowedChannelIDs) == 0 {
+		return true
+	}
+
+	for _, allowedChannelID := range b.allowedChannelIDs {
+		if channelID == allowedChannelID {
+			return true
+		}
+	}
+
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			log.Printf("Error getting channel %s: %v", channelID, err)
+
+			return false
+		}
+	}
+
+	if !channel.IsThread() || channel.ParentID == "" {
+		return false
+	}
+
+	return b.channelAllowed(s, channel.ParentID)
+}
+
+// checkAllowedChannel responds with the allowed channels when the command is used elsewhere. DMs are left to the commands
+func (b *botImpl) checkAllowedChannel(s *discordgo.Session, i *discordgo.InteractionCreate) bool {
+	if i.GuildID == "" || b.channelAllowed(s, i.ChannelID) {
+		return true
+	}
+
+	channels := make([]string, len(b.allowedChannelIDs))
+	for idx, channelID := range b.allowedChannelIDs {
+		channels[idx] = "<#" + channelID + ">"
+	}
+
+	respondEphemeral(s, i, "Commands can't be used in this channel, please use "+strings.Join(channels, ", "))
+
+	return false
+}
@@ -0,0 +1,44 @@
+package discord_bot
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// controlNetOptions returns the ControlNet image and model options of imagine_ext, or nil without ControlNet models
+func controlNetOptions(models []string) []*discordgo.ApplicationCommandOption {
+	if len(models) == 0 {
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, model := range models {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncatePrompt(model, maxChoiceNameLength-len("...")),
+			Value: model,
+		})
+
+		// Max 25 choices
+		if len(choices) == 25 {
+			log.Printf("Loaded 25/%d ControlNet models...", len(models))
+			break
+		}
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionAttachment,
+			Name:        extOptionControlNetImage,
+			Description: "ControlNet control map, e.g. a depth map, pose or edges. Requires controlnet_model",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        extOptionControlNetModel,
+			Description: "ControlNet model applied to controlnet_image",
+			Required:    false,
+			Choices:     choices,
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package discord_bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stable_diffusion_bot/clock"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type cooldownKey struct {
+	commandName string
+	userID      string
+}
+
+// cooldownTracker limits how often each user can run a command
+type cooldownTracker struct {
+	cooldowns map[string]time.Duration
+	clock     clock.Clock
+	mu        sync.Mutex
+	lastUsed  map[cooldownKey]time.Time
+}
+
+func newCooldownTracker(cooldowns map[string]time.Duration, clock clock.Clock) *cooldownTracker {
+	return &cooldownTracker{
+		cooldowns: cooldowns,
+		clock:     clock,
+		lastUsed:  make(map[cooldownKey]time.Time),
+	}
+}
+
+// use records the command use and returns zero, or the time left until the user can run the command again
+func (t *cooldownTracker) use(commandName, userID string) time.Duration {
+	cooldown := t.cooldowns[commandName]
+	if cooldown <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	key := cooldownKey{commandName: commandName, userID: userID}
+
+	if lastUsed, ok := t.lastUsed[key]; ok {
+		if remaining := lastUsed.Add(cooldown).Sub(now); remaining > 0 {
+			return remaining
+		}
+	}
+
+	t.lastUsed[key] = now
+
+	return 0
+}
+
+// checkCooldown responds with the time left and returns false if the user has run the command too recently.
+// Cooldowns are configured by the command name without the development mode prefix
+func (b *botImpl) checkCooldown(s *discordgo.Session, i *discordgo.InteractionCreate) bool {
+	commandName := strings.TrimPrefix(i.ApplicationCommandData().Name, "dev_")
+
+	remaining := b.cooldowns.use(commandName, interactionUser(i).ID)
+	if remaining == 0 {
+		return true
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Please wait %s before using this command again.",
+		remaining.Round(time.Second)))
+
+	return false
+}
@@ -1,15 +1,24 @@
 package discord_bot
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"stable_diffusion_bot/civitai"
+	"stable_diffusion_bot/clock"
 	"stable_diffusion_bot/imagine_queue"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/repositories/settings"
 	"stable_diffusion_bot/repositories/statistics"
 	"stable_diffusion_bot/stable_diffusion_api"
 
@@ -17,26 +26,113 @@ import (
 )
 
 type botImpl struct {
-	developmentMode    bool
-	botSession         *discordgo.Session
-	guildID            string
-	imagineQueue       imagine_queue.Queue
-	registeredCommands []*discordgo.ApplicationCommand
-	imagineCommand     string
-	removeCommands     bool
-	stableDiffusionAPI stable_diffusion_api.StableDiffusionAPI
-	statisticsRepo     statistics.Repository
+	developmentMode     bool
+	botSession          *discordgo.Session
+	guildID             string
+	imagineQueue        imagine_queue.Queue
+	registeredCommands  []*discordgo.ApplicationCommand
+	imagineCommand      string
+	removeCommands      bool
+	stableDiffusionAPI  stable_diffusion_api.StableDiffusionAPI
+	statisticsRepo      statistics.Repository
+	generationRepo      image_generations.Repository
+	maxPromptLength     int
+	maxNegativeLength   int
+	rawBlacklist        []string
+	translator          *translator
+	clock               clock.Clock
+	tipsChannelID       string
+	tips                []string
+	tipsTime            time.Time
+	latency             *latencyTracker
+	cooldowns           *cooldownTracker
+	version             string
+	outputChannelID     string
+	maxEmbeddingRetries int
+	embeddingRetryDelay time.Duration
+	samplerChoices      []*discordgo.ApplicationCommandOptionChoice
+	civitAI             civitai.CivitAI
+	challengeChannelID  string
+	allowedRoleIDs      []string
+	allowedChannelIDs   []string
+	// Registered imagine_ext command and its options without the embeddings, for updating the embeddings
+	imagineExtCmd     *discordgo.ApplicationCommand
+	imagineExtOptions []*discordgo.ApplicationCommandOption
 }
 
 type Config struct {
-	DevelopmentMode    bool
-	BotToken           string
-	GuildID            string
-	ImagineQueue       imagine_queue.Queue
-	ImagineCommand     string
-	RemoveCommands     bool
-	StableDiffusionAPI stable_diffusion_api.StableDiffusionAPI
-	StatisticsRepo     statistics.Repository
+	DevelopmentMode bool
+	BotToken        string
+	GuildID         string
+	// Guild for registering commands in development mode, GuildID is used when empty
+	DevelopmentGuildID  string
+	ImagineQueue        imagine_queue.Queue
+	ImagineCommand      string
+	RemoveCommands      bool
+	StableDiffusionAPI  stable_diffusion_api.StableDiffusionAPI
+	StatisticsRepo      statistics.Repository
+	ImageGenerationRepo image_generations.Repository
+	// Maximum prompt length in characters, 0 = unlimited
+	MaxPromptLength int
+	// Maximum negative prompt length in characters, 0 = unlimited
+	MaxNegativePromptLength int
+	// Override settings that cannot be passed to the raw command. DefaultRawOverrideBlacklist is used when empty
+	RawOverrideBlacklist []string
+	// Translation service for non-English prompts, TranslationProviderGoogle or TranslationProviderLibreTranslate.
+	// Translation is disabled when empty
+	TranslationProvider string
+	TranslationAPIKey   string
+	// Channel for the daily tip, tips are disabled when empty
+	TipsChannelID string
+	// Path to a JSON array of tip strings, required with TipsChannelID
+	TipsFile string
+	// UTC time of day in the 15:04 format, DefaultTipsTime when empty
+	TipsTime string
+	// Clock schedules the tips and the cooldowns, the real clock when nil
+	Clock clock.Clock
+	// P99 interaction response latency that triggers a warning, DefaultLatencyAlertThresholdMs when 0
+	LatencyAlertThresholdMs int
+	// Channel for operational warnings, they are only logged when empty
+	ErrorChannelID string
+	// Attempts to load embeddings for imagine_ext when the API isn't ready on start, DefaultMaxEmbeddingRetries when 0
+	MaxEmbeddingRetries int
+	// Delay between the attempts, DefaultEmbeddingRetryDelay when 0
+	EmbeddingRetryDelay time.Duration
+	// Per-user cooldowns by command name, e.g. "imagine" or "imagine_ext". Commands without a cooldown can be run anytime
+	CommandCooldowns map[string]time.Duration
+	// Bot version shown in the activity status
+	Version string
+	// API key for CivitAI requests of the model info command, optional
+	CivitAIAPIKey string
+	// Channel for the results of the imagine commands, the command response is only seen by the requester then.
+	// Results are posted in the command's channel when empty
+	OutputChannelID string
+	// Channel for the daily prompt challenge, the challenge command is disabled when empty
+	ChallengeChannelID string
+	// Roles allowed to generate images, members need at least one of them. Everyone is allowed when empty
+	AllowedRoleIDs []string
+	// Channels where the commands can be used, threads included. All channels are allowed when empty
+	AllowedChannelIDs []string
+}
+
+const (
+	DefaultMaxEmbeddingRetries = 10
+	DefaultEmbeddingRetryDelay = 30 * time.Second
+)
+
+// DefaultRawOverrideBlacklist lists the A1111 options that allow writing to arbitrary server paths
+var DefaultRawOverrideBlacklist = []string{
+	"samples_filename_pattern",
+	"directories_filename_pattern",
+	"outdir_samples",
+	"outdir_txt2img_samples",
+	"outdir_img2img_samples",
+	"outdir_extras_samples",
+	"outdir_grids",
+	"outdir_txt2img_grids",
+	"outdir_img2img_grids",
+	"outdir_save",
+	"outdir_init_images",
 }
 
 func (b *botImpl) imagineCommandString() string {
@@ -72,29 +168,229 @@ func (b *botImpl) imagineStatsCommandString() string {
 	return b.imagineCommand + "_stats"
 }
 
-func New(cfg Config) (Bot, error) {
+func (b *botImpl) imagineMySettingsCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_my_settings"
+	}
+
+	return b.imagineCommand + "_my_settings"
+}
+
+func (b *botImpl) imagineRegionalCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_regional"
+	}
+
+	return b.imagineCommand + "_regional"
+}
+
+func (b *botImpl) imagineInterrogateCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_interrogate"
+	}
+
+	return b.imagineCommand + "_interrogate"
+}
+
+func (b *botImpl) imagineRawCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_raw"
+	}
+
+	return b.imagineCommand + "_raw"
+}
+
+func (b *botImpl) imaginePinCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_pin"
+	}
+
+	return b.imagineCommand + "_pin"
+}
+
+func (b *botImpl) imagineNotificationsCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_notifications"
+	}
+
+	return b.imagineCommand + "_notifications"
+}
+
+func (b *botImpl) imagineQueueCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_queue"
+	}
+
+	return b.imagineCommand + "_queue"
+}
+
+func (b *botImpl) imagineFlushQueueCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_flush_queue"
+	}
+
+	return b.imagineCommand + "_flush_queue"
+}
+
+func (b *botImpl) imagineStatusCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_status"
+	}
+
+	return b.imagineCommand + "_status"
+}
+
+func (b *botImpl) imagineModelInfoCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_model_info"
+	}
+
+	return b.imagineCommand + "_model_info"
+}
+
+func (b *botImpl) imagineImg2ImgCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_img2img"
+	}
+
+	return b.imagineCommand + "_img2img"
+}
+
+func (b *botImpl) imagineModelCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_model"
+	}
+
+	return b.imagineCommand + "_model"
+}
+
+func (b *botImpl) imagineCancelCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_cancel"
+	}
+
+	return b.imagineCommand + "_cancel"
+}
+
+func (b *botImpl) imagineLeaderboardCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_leaderboard"
+	}
+
+	return b.imagineCommand + "_leaderboard"
+}
+
+func (b *botImpl) imagineInterruptCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_interrupt"
+	}
+
+	return b.imagineCommand + "_interrupt"
+}
+
+func (b *botImpl) imagineVAECommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_vae"
+	}
+
+	return b.imagineCommand + "_vae"
+}
+
+func (b *botImpl) imagineInfoCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_info"
+	}
+
+	return b.imagineCommand + "_info"
+}
+
+func (b *botImpl) imagineChallengeCommandString() string {
+	if b.developmentMode {
+		return "dev_" + b.imagineCommand + "_challenge"
+	}
+
+	return b.imagineCommand + "_challenge"
+}
+
+// commandNames returns the names of all commands registered by the bot
+func (b *botImpl) commandNames() []string {
+	return []string{
+		b.imagineCommandString(),
+		b.imagineExtCommandString(),
+		b.imagineSettingsCommandString(),
+		b.imagineStatsCommandString(),
+		b.imagineMySettingsCommandString(),
+		b.imagineRegionalCommandString(),
+		b.imagineInterrogateCommandString(),
+		b.imagineRawCommandString(),
+		b.imaginePinCommandString(),
+		b.imagineNotificationsCommandString(),
+		b.imagineQueueCommandString(),
+		b.imagineFlushQueueCommandString(),
+		b.imagineStatusCommandString(),
+		b.imagineModelInfoCommandString(),
+		b.imagineImg2ImgCommandString(),
+		b.imagineChallengeCommandString(),
+		b.imagineInfoCommandString(),
+		b.imagineModelCommandString(),
+		b.imagineCancelCommandString(),
+		b.imagineLeaderboardCommandString(),
+		b.imagineInterruptCommandString(),
+		b.imagineVAECommandString(),
+	}
+}
+
+// See https://discord.com/developers/docs/interactions/application-commands#application-command-object-application-command-naming
+var commandNameRegex = regexp.MustCompile(`^[-_\p{L}\p{N}\p{Devanagari}\p{Thai}]{1,32}$`)
+
+// Validate checks that the required fields are set and that the command names derived from
+// ImagineCommand are accepted by Discord
+func (cfg *Config) Validate() error {
 	if cfg.BotToken == "" {
-		return nil, errors.New("missing bot token")
+		return errors.New("missing bot token")
 	}
 
 	if cfg.GuildID == "" {
-		return nil, errors.New("missing guild ID")
+		return errors.New("missing guild ID")
 	}
 
 	if cfg.ImagineQueue == nil {
-		return nil, errors.New("missing imagine queue")
+		return errors.New("missing imagine queue")
 	}
 
 	if cfg.ImagineCommand == "" {
-		return nil, errors.New("missing imagine command")
+		return errors.New("missing imagine command")
+	}
+
+	// The other commands are named after the imagine command, so all of them must be valid
+	names := (&botImpl{developmentMode: cfg.DevelopmentMode, imagineCommand: cfg.ImagineCommand}).commandNames()
+	for _, name := range names {
+		if !commandNameRegex.MatchString(name) || strings.ToLower(name) != name {
+			return fmt.Errorf("invalid imagine command %q: command %q must be 1-32 lowercase letters, digits, "+
+				"dashes or underscores, see https://discord.com/developers/docs/interactions/application-commands#application-command-object-application-command-naming",
+				cfg.ImagineCommand, name)
+		}
 	}
 
 	if cfg.StableDiffusionAPI == nil {
-		return nil, errors.New("missing stable diffusion API")
+		return errors.New("missing stable diffusion API")
 	}
 
 	if cfg.StatisticsRepo == nil {
-		return nil, errors.New("missing statistics repo")
+		return errors.New("missing statistics repo")
+	}
+
+	if cfg.ImageGenerationRepo == nil {
+		return errors.New("missing image generation repo")
+	}
+
+	return nil
+}
+
+func New(cfg Config) (Bot, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
 	}
 
 	botSession, err := discordgo.New("Bot " + cfg.BotToken)
@@ -110,17 +406,92 @@ func New(cfg Config) (Bot, error) {
 		return nil, err
 	}
 
+	guildID := cfg.GuildID
+	if cfg.DevelopmentMode && cfg.DevelopmentGuildID != "" {
+		guildID = cfg.DevelopmentGuildID
+	}
+
+	civitAI, err := civitai.New(civitai.Config{APIKey: cfg.CivitAIAPIKey})
+	if err != nil {
+		return nil, err
+	}
+
+	promptTranslator, err := newTranslator(cfg.TranslationProvider, cfg.TranslationAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []string
+
+	tipsTime, err := time.Parse(tipsTimeLayout, DefaultTipsTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TipsChannelID != "" {
+		tips, err = loadTips(cfg.TipsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tips: %w", err)
+		}
+
+		if cfg.TipsTime != "" {
+			tipsTime, err = time.Parse(tipsTimeLayout, cfg.TipsTime)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tips time: %w", err)
+			}
+		}
+	}
+
+	botClock := cfg.Clock
+	if botClock == nil {
+		botClock = clock.NewClock()
+	}
+
 	bot := &botImpl{
-		developmentMode:    cfg.DevelopmentMode,
-		botSession:         botSession,
-		imagineQueue:       cfg.ImagineQueue,
-		registeredCommands: make([]*discordgo.ApplicationCommand, 0),
-		imagineCommand:     cfg.ImagineCommand,
-		removeCommands:     cfg.RemoveCommands,
-		stableDiffusionAPI: cfg.StableDiffusionAPI,
-		statisticsRepo:     cfg.StatisticsRepo,
+		developmentMode:     cfg.DevelopmentMode,
+		botSession:          botSession,
+		guildID:             guildID,
+		imagineQueue:        cfg.ImagineQueue,
+		registeredCommands:  make([]*discordgo.ApplicationCommand, 0),
+		imagineCommand:      cfg.ImagineCommand,
+		removeCommands:      cfg.RemoveCommands,
+		stableDiffusionAPI:  cfg.StableDiffusionAPI,
+		statisticsRepo:      cfg.StatisticsRepo,
+		generationRepo:      cfg.ImageGenerationRepo,
+		maxPromptLength:     cfg.MaxPromptLength,
+		maxNegativeLength:   cfg.MaxNegativePromptLength,
+		rawBlacklist:        cfg.RawOverrideBlacklist,
+		translator:          promptTranslator,
+		clock:               botClock,
+		tipsChannelID:       cfg.TipsChannelID,
+		tips:                tips,
+		tipsTime:            tipsTime,
+		latency:             newLatencyTracker(cfg.LatencyAlertThresholdMs, cfg.ErrorChannelID),
+		cooldowns:           newCooldownTracker(cfg.CommandCooldowns, botClock),
+		version:             cfg.Version,
+		outputChannelID:     cfg.OutputChannelID,
+		civitAI:             civitAI,
+		challengeChannelID:  cfg.ChallengeChannelID,
+		allowedRoleIDs:      cfg.AllowedRoleIDs,
+		allowedChannelIDs:   cfg.AllowedChannelIDs,
+		maxEmbeddingRetries: cfg.MaxEmbeddingRetries,
+		embeddingRetryDelay: cfg.EmbeddingRetryDelay,
+	}
+
+	if bot.maxEmbeddingRetries <= 0 {
+		bot.maxEmbeddingRetries = DefaultMaxEmbeddingRetries
+	}
+
+	if bot.embeddingRetryDelay <= 0 {
+		bot.embeddingRetryDelay = DefaultEmbeddingRetryDelay
+	}
+
+	if len(bot.rawBlacklist) == 0 {
+		bot.rawBlacklist = DefaultRawOverrideBlacklist
 	}
 
+	bot.samplerChoices = bot.loadSamplerChoices()
+
 	err = bot.addImagineCommand()
 	if err != nil {
 		return nil, err
@@ -141,9 +512,114 @@ func New(cfg Config) (Bot, error) {
 		return nil, err
 	}
 
+	err = bot.addImagineMySettingsCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineRegionalCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineInterrogateCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineRawCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImaginePinCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineNotificationsCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineQueueCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineFlushQueueCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineStatusCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineModelInfoCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineImg2ImgCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineChallengeCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineInfoCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineModelCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineCancelCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineLeaderboardCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineInterruptCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.addImagineVAECommand()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := botSession.Client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	botSession.Client.Transport = &latencyTransport{inner: transport, tracker: bot.latency, session: botSession}
+
 	botSession.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		switch i.Type {
 		case discordgo.InteractionApplicationCommand:
+			if !bot.checkAllowedChannel(s, i) {
+				return
+			}
+
+			if !bot.checkCooldown(s, i) {
+				return
+			}
+
 			switch i.ApplicationCommandData().Name {
 			case bot.imagineCommandString():
 				bot.processImagineCommand(s, i)
@@ -153,13 +629,73 @@ func New(cfg Config) (Bot, error) {
 				bot.processImagineSettingsCommand(s, i)
 			case bot.imagineStatsCommandString():
 				bot.processImagineStatsCommand(s, i)
+			case bot.imagineMySettingsCommandString():
+				bot.processImagineMySettingsCommand(s, i)
+			case bot.imagineRegionalCommandString():
+				bot.processImagineRegionalCommand(s, i)
+			case bot.imagineInterrogateCommandString():
+				bot.processImagineInterrogateCommand(s, i)
+			case bot.imagineRawCommandString():
+				bot.processImagineRawCommand(s, i)
+			case bot.imaginePinCommandString():
+				bot.processImaginePinCommand(s, i)
+			case bot.imagineNotificationsCommandString():
+				bot.processImagineNotificationsCommand(s, i)
+			case bot.imagineQueueCommandString():
+				bot.processImagineQueueCommand(s, i)
+			case bot.imagineFlushQueueCommandString():
+				bot.processImagineFlushQueueCommand(s, i)
+			case bot.imagineStatusCommandString():
+				bot.processImagineStatusCommand(s, i)
+			case bot.imagineModelInfoCommandString():
+				bot.processImagineModelInfoCommand(s, i)
+			case bot.imagineImg2ImgCommandString():
+				bot.processImagineImg2ImgCommand(s, i)
+			case bot.imagineChallengeCommandString():
+				bot.processImagineChallengeCommand(s, i)
+			case bot.imagineInfoCommandString():
+				bot.processImagineInfoCommand(s, i)
+			case bot.imagineModelCommandString():
+				bot.processImagineModelCommand(s, i)
+			case bot.imagineCancelCommandString():
+				bot.processImagineCancelCommand(s, i)
+			case bot.imagineLeaderboardCommandString():
+				bot.processImagineLeaderboard(s, i)
+			case bot.imagineInterruptCommandString():
+				bot.processImagineInterruptCommand(s, i)
+			case bot.imagineVAECommandString():
+				bot.processImagineVAECommand(s, i)
 			default:
 				log.Printf("Unknown command '%v'", i.ApplicationCommandData().Name)
 			}
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			switch i.ApplicationCommandData().Name {
+			case bot.imagineExtCommandString():
+				bot.processImagineExtAutocomplete(s, i)
+			}
+		case discordgo.InteractionModalSubmit:
+			switch i.ModalSubmitData().CustomID {
+			case imagineModalID:
+				bot.processImagineModalSubmit(s, i)
+			default:
+				log.Printf("Unknown modal '%v'", i.ModalSubmitData().CustomID)
+			}
 		case discordgo.InteractionMessageComponent:
 			switch customID := i.MessageComponentData().CustomID; {
 			case customID == "imagine_reroll":
 				bot.processImagineReroll(s, i)
+			case customID == "imagine_preview_yes":
+				bot.processImaginePreviewAnswer(s, i, true)
+			case customID == "imagine_preview_no":
+				bot.processImaginePreviewAnswer(s, i, false)
+			case customID == "imagine_delete":
+				bot.processImagineDelete(s, i)
+			case customID == "imagine_flush_confirm":
+				bot.processImagineFlushConfirm(s, i)
+			case customID == "imagine_flush_cancel":
+				bot.processImagineFlushCancel(s, i)
+			case customID == "imagine_upscale_all":
+				bot.processImagineUpscaleAll(s, i)
 			case strings.HasPrefix(customID, "imagine_upscale_"):
 				interactionIndex := strings.TrimPrefix(customID, "imagine_upscale_")
 
@@ -219,6 +755,18 @@ func New(cfg Config) (Bot, error) {
 }
 
 func (b *botImpl) Start() {
+	presenceDone := make(chan struct{})
+	defer close(presenceDone)
+
+	go b.updatePresence(presenceDone)
+
+	if b.tipsChannelID != "" {
+		done := make(chan struct{})
+		defer close(done)
+
+		go b.postTips(done)
+	}
+
 	b.imagineQueue.StartPolling(b.botSession)
 
 	err := b.teardown()
@@ -255,8 +803,8 @@ func (b *botImpl) addImagineCommand() error {
 			{
 				Type:        discordgo.ApplicationCommandOptionString,
 				Name:        "prompt",
-				Description: "The text prompt to imagine",
-				Required:    true,
+				Description: "The text prompt to imagine, leave it out to fill in a form with more parameters",
+				Required:    false,
 			},
 		},
 	})
@@ -272,37 +820,221 @@ func (b *botImpl) addImagineCommand() error {
 }
 
 const (
-	extOptionAR             = `aspect_ratio`
-	extOptionCFGScale       = `cfg_scale`
-	extOptionEmbeddings     = `embeddings`
-	extOptionNegativePrompt = `negative_prompt`
-	extOptionPrompt         = `prompt`
-	extOptionRestoreFaces   = `restore_faces`
-	extOptionSampler        = `sampler`
-	extOptionSeed           = `seed`
-	extOptionSteps          = `steps`
+	extOptionAR                 = `aspect_ratio`
+	extOptionCFGScale           = `cfg_scale`
+	extOptionEmbeddings         = `embeddings`
+	extOptionNegativePrompt     = `negative_prompt`
+	extOptionPrompt             = `prompt`
+	extOptionRestoreFaces       = `restore_faces`
+	extOptionRestoreFacesWeight = `restore_faces_weight`
+	extOptionSampler            = `sampler`
+	extOptionSeed               = `seed`
+	extOptionSteps              = `steps`
+	extOptionPreset             = `preset`
+	extOptionNoiseMultiplier    = `noise_multiplier`
+	extOptionPreview            = `preview`
+	extOptionControlNetImage    = `controlnet_image`
+	extOptionControlNetModel    = `controlnet_model`
+	extOptionWidth              = `width`
+	extOptionHeight             = `height`
+	extOptionHiresFix           = `hires_fix`
+	extOptionHiresDenoising     = `hires_denoising`
+	extOptionHiresSteps         = `hires_steps`
+	extOptionHiresUpscaler      = `hires_upscaler`
+	extOptionLoRA               = `lora`
+	extOptionLoRAWeight         = `lora_weight`
+	extOptionClipSkip           = `clip_skip`
+	extOptionTiling             = `tiling`
 )
 
-func (b *botImpl) addImagineExtCommand() error {
-	command := b.imagineExtCommandString()
-	log.Printf("Adding command '%s'...", command)
+// Limits of the custom width and height of imagine_ext
+const (
+	minCustomDimension  = 256
+	maxCustomDimension  = 2048
+	customDimensionStep = 64
+)
 
-	minNum := 1.0
-	commandOptions := []*discordgo.ApplicationCommandOption{
-		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        extOptionPrompt,
-			Description: "The text prompt to imagine (`--ar x:y` to set aspect ratio)",
-			Required:    true,
-		},
-		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        extOptionAR,
-			Description: "Aspect Ratio",
-			Required:    false,
-			Choices: []*discordgo.ApplicationCommandOptionChoice{
-				{
-					Name:  "1:1  (square, 512×512)",
+// fallbackSamplerChoices are offered when the server doesn't return its samplers
+var fallbackSamplerChoices = []*discordgo.ApplicationCommandOptionChoice{
+	{
+		Name:  "DPM++ 2M Karras",
+		Value: "DPM++ 2M Karras",
+	},
+	{
+		Name:  "Euler a",
+		Value: "Euler a",
+	},
+	{
+		Name:  "DDIM",
+		Value: "DDIM",
+	},
+	{
+		Name:  "PLMS",
+		Value: "PLMS",
+	},
+	{
+		Name:  "UniPC",
+		Value: "UniPC",
+	},
+	{
+		Name:  "Heun",
+		Value: "Heun",
+	},
+	{
+		Name:  "Euler",
+		Value: "Euler",
+	},
+	{
+		Name:  "LMS",
+		Value: "LMS",
+	},
+	{
+		Name:  "LMS Karras",
+		Value: "LMS Karras",
+	},
+	{
+		Name:  "DPM2 a",
+		Value: "DPM2 a",
+	},
+	{
+		Name:  "DPM2 a Karras",
+		Value: "DPM2 a Karras",
+	},
+	{
+		Name:  "DPM2",
+		Value: "DPM2",
+	},
+	{
+		Name:  "DPM2 Karras",
+		Value: "DPM2 Karras",
+	},
+	{
+		Name:  "DPM fast",
+		Value: "DPM fast",
+	},
+	{
+		Name:  "DPM adaptive",
+		Value: "DPM adaptive",
+	},
+	{
+		Name:  "DPM++ 2S a",
+		Value: "DPM++ 2S a",
+	},
+	{
+		Name:  "DPM++ 2M",
+		Value: "DPM++ 2M",
+	},
+	{
+		Name:  "DPM++ SDE",
+		Value: "DPM++ SDE",
+	},
+	{
+		Name:  "DPM++ 2S a Karras",
+		Value: "DPM++ 2S a Karras",
+	},
+	{
+		Name:  "DPM++ SDE Karras",
+		Value: "DPM++ SDE Karras",
+	},
+}
+
+// loadSamplerChoices offers the samplers of the server, or fallbackSamplerChoices when it can't be reached
+func (b *botImpl) loadSamplerChoices() []*discordgo.ApplicationCommandOptionChoice {
+	samplers, err := b.stableDiffusionAPI.GetSamplers(context.Background())
+	if err != nil {
+		log.Printf("Error getting samplers, using the default list: %v", err)
+
+		return fallbackSamplerChoices
+	}
+
+	if len(samplers) == 0 {
+		return fallbackSamplerChoices
+	}
+
+	// Max 25 choices
+	// https://discord.com/developers/docs/interactions/application-commands#application-command-object-application-command-option-structure
+	if len(samplers) > 25 {
+		log.Printf("Warning: the server has %d samplers, only the first 25 can be chosen", len(samplers))
+
+		samplers = samplers[:25]
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(samplers))
+	for _, sampler := range samplers {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  sampler.Name,
+			Value: sampler.Name,
+		})
+	}
+
+	return choices
+}
+
+// upscalerOption returns the hires fix upscaler choices, or nil when the upscalers can't be loaded
+func (b *botImpl) upscalerOption() *discordgo.ApplicationCommandOption {
+	upscalers, err := b.stableDiffusionAPI.GetUpscalers(context.Background())
+	if err != nil {
+		log.Printf("Error getting upscalers: %v", err)
+
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, upscaler := range upscalers {
+		// "None" only resizes the latent, the server default covers it
+		if upscaler.Name == "None" {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncatePrompt(upscaler.Name, maxChoiceNameLength-len("...")),
+			Value: upscaler.Name,
+		})
+
+		// Max 25 choices
+		if len(choices) == 25 {
+			log.Printf("Loaded 25/%d upscalers...", len(upscalers))
+			break
+		}
+	}
+
+	if len(choices) == 0 {
+		return nil
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        extOptionHiresUpscaler,
+		Description: "Upscaler of the hires fix",
+		Required:    false,
+		Choices:     choices,
+	}
+}
+
+func (b *botImpl) addImagineExtCommand() error {
+	command := b.imagineExtCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	minNum := 1.0
+	minWeight := 0.0
+	minNoiseMultiplier := 0.5
+	minDimension := float64(minCustomDimension)
+	minHiresDenoising := 0.01
+	commandOptions := []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        extOptionPrompt,
+			Description: "The text prompt to imagine (`--ar x:y` to set aspect ratio)",
+			Required:    true,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        extOptionAR,
+			Description: "Aspect Ratio",
+			Required:    false,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{
+					Name:  "1:1  (square, 512×512)",
 					Value: "",
 				},
 				{
@@ -331,6 +1063,22 @@ func (b *botImpl) addImagineExtCommand() error {
 				},
 			},
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        extOptionWidth,
+			Description: fmt.Sprintf("Custom width, a multiple of %d. Requires height, replaces the aspect ratio", customDimensionStep),
+			Required:    false,
+			MinValue:    &minDimension,
+			MaxValue:    maxCustomDimension,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        extOptionHeight,
+			Description: fmt.Sprintf("Custom height, a multiple of %d. Requires width, replaces the aspect ratio", customDimensionStep),
+			Required:    false,
+			MinValue:    &minDimension,
+			MaxValue:    maxCustomDimension,
+		},
 		{
 			Type:        discordgo.ApplicationCommandOptionString,
 			Name:        extOptionNegativePrompt,
@@ -343,6 +1091,14 @@ func (b *botImpl) addImagineExtCommand() error {
 			Description: "Restore faces" + fmt.Sprintf(" (%v)", imagine_queue.DefaultRestoreFaces),
 			Required:    false,
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionNumber,
+			Name:        extOptionRestoreFacesWeight,
+			Description: "CodeFormer weight for restore faces: 0 = max quality enhancement, 1 = max fidelity to original",
+			Required:    false,
+			MinValue:    &minWeight,
+			MaxValue:    1,
+		},
 		{
 			Type:        discordgo.ApplicationCommandOptionNumber,
 			Name:        extOptionCFGScale,
@@ -362,89 +1118,7 @@ func (b *botImpl) addImagineExtCommand() error {
 			Name:        extOptionSampler,
 			Description: fmt.Sprintf("Sampler (%s)", imagine_queue.DefaultSampler),
 			Required:    false,
-			Choices: []*discordgo.ApplicationCommandOptionChoice{
-				// TODO: move to config
-				{
-					Name:  "DPM++ 2M Karras",
-					Value: "DPM++ 2M Karras",
-				},
-				{
-					Name:  "Euler a",
-					Value: "Euler a",
-				},
-				{
-					Name:  "DDIM",
-					Value: "DDIM",
-				},
-				{
-					Name:  "PLMS",
-					Value: "PLMS",
-				},
-				{
-					Name:  "UniPC",
-					Value: "UniPC",
-				},
-				{
-					Name:  "Heun",
-					Value: "Heun",
-				},
-				{
-					Name:  "Euler",
-					Value: "Euler",
-				},
-				{
-					Name:  "LMS",
-					Value: "LMS",
-				},
-				{
-					Name:  "LMS Karras",
-					Value: "LMS Karras",
-				},
-				{
-					Name:  "DPM2 a",
-					Value: "DPM2 a",
-				},
-				{
-					Name:  "DPM2 a Karras",
-					Value: "DPM2 a Karras",
-				},
-				{
-					Name:  "DPM2",
-					Value: "DPM2",
-				},
-				{
-					Name:  "DPM2 Karras",
-					Value: "DPM2 Karras",
-				},
-				{
-					Name:  "DPM fast",
-					Value: "DPM fast",
-				},
-				{
-					Name:  "DPM adaptive",
-					Value: "DPM adaptive",
-				},
-				{
-					Name:  "DPM++ 2S a",
-					Value: "DPM++ 2S a",
-				},
-				{
-					Name:  "DPM++ 2M",
-					Value: "DPM++ 2M",
-				},
-				{
-					Name:  "DPM++ SDE",
-					Value: "DPM++ SDE",
-				},
-				{
-					Name:  "DPM++ 2S a Karras",
-					Value: "DPM++ 2S a Karras",
-				},
-				{
-					Name:  "DPM++ SDE Karras",
-					Value: "DPM++ SDE Karras",
-				},
-			},
+			Choices:     b.samplerChoices,
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionInteger,
@@ -453,43 +1127,88 @@ func (b *botImpl) addImagineExtCommand() error {
 			MinValue:    &minNum,
 			MaxValue:    50,
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        extOptionClipSkip,
+			Description: "CLIP layers to skip from the end, 2 for most anime models (server setting)",
+			Required:    false,
+			MinValue:    &minNum,
+			MaxValue:    maxClipSkip,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionNumber,
+			Name:        extOptionNoiseMultiplier,
+			Description: "Initial noise multiplier (1.0). Below 1.0 gives less varied but more stable images",
+			Required:    false,
+			MinValue:    &minNoiseMultiplier,
+			MaxValue:    1.5,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        extOptionTiling,
+			Description: "Seamlessly tileable image, e.g. for textures. Works best without hires fix",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        extOptionPreview,
+			Description: "Show a small quick preview to approve before generating the full images",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        extOptionHiresFix,
+			Description: "Generate at the default size and upscale with a second pass, for more details",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionNumber,
+			Name:        extOptionHiresDenoising,
+			Description: fmt.Sprintf("How much the hires fix pass changes the image (%v)", imagine_queue.DefaultDenoisingStrength),
+			Required:    false,
+			MinValue:    &minHiresDenoising,
+			MaxValue:    1,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        extOptionHiresSteps,
+			Description: "Sampling steps of the hires fix pass (half of the steps)",
+			Required:    false,
+			MinValue:    &minNum,
+			MaxValue:    50,
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionString,
+			Name:         extOptionPreset,
+			Description:  "Preset of sampler, CFG scale, steps and negative prompt. Other options take precedence",
+			Required:     false,
+			Autocomplete: true,
+		},
 	}
 
-	// TODO: reload embeddings on model change
-	embs, err := b.stableDiffusionAPI.GetEmbeddings()
-	if err != nil {
-		log.Printf("Error getting embeddings: %v", err)
+	if option := b.upscalerOption(); option != nil {
+		commandOptions = append(commandOptions, option)
 	}
-	if len(embs.Loaded) > 0 {
-		var options []*discordgo.ApplicationCommandOptionChoice
-		for embed := range embs.Loaded {
-			options = append(options, &discordgo.ApplicationCommandOptionChoice{
-				Name:  embed,
-				Value: embed,
-			})
 
-			// Max 25 choices
-			// https://discord.com/developers/docs/interactions/application-commands#application-command-object-application-command-option-structure
-			if len(options) == 25 {
-				log.Printf("Loaded 25/%d textual inversions...", len(embs.Loaded))
-				break
-			}
-		}
+	commandOptions = append(commandOptions, b.loraOptions()...)
 
-		commandOptions = append(commandOptions, &discordgo.ApplicationCommandOption{
-			Type:         discordgo.ApplicationCommandOptionString,
-			Name:         extOptionEmbeddings,
-			Description:  "Textual Inversion",
-			Required:     false,
-			Autocomplete: false,
-			Choices:      options,
-		})
+	// The options are left out when the ControlNet extension isn't installed
+	controlNetModels, err := b.stableDiffusionAPI.GetControlNetModels(context.Background())
+	if err != nil {
+		log.Printf("Error getting ControlNet models: %v", err)
+	} else {
+		commandOptions = append(commandOptions, controlNetOptions(controlNetModels)...)
+	}
+
+	embs, embErr := b.stableDiffusionAPI.GetEmbeddingsFull(context.Background())
+	if embErr != nil {
+		log.Printf("Error getting embeddings: %v", embErr)
 	}
 
 	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
 		Name:        command,
 		Description: "Ask the bot to imagine something",
-		Options:     commandOptions,
+		Options:     withEmbeddingsOption(commandOptions, embeddingsOption(embs)),
 	})
 	if err != nil {
 		log.Printf("Error creating '%s' command: %v", command, err)
@@ -498,16 +1217,182 @@ func (b *botImpl) addImagineExtCommand() error {
 	}
 
 	b.registeredCommands = append(b.registeredCommands, cmd)
+	b.imagineExtCmd = cmd
+	b.imagineExtOptions = commandOptions
+
+	// The server may still be starting, the embeddings are added once it responds
+	if embErr != nil {
+		go b.retryImagineExtEmbeddings(cmd, commandOptions)
+	}
 
 	return nil
 }
 
+// withEmbeddingsOption returns a copy of the options with the embeddings option replaced by the given one,
+// or removed when it is nil. Discord rejects commands with two options of the same name
+func withEmbeddingsOption(options []*discordgo.ApplicationCommandOption, embeddings *discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	result := make([]*discordgo.ApplicationCommandOption, 0, len(options)+1)
+
+	for _, option := range options {
+		if option.Name != extOptionEmbeddings {
+			result = append(result, option)
+		}
+	}
+
+	if embeddings != nil {
+		result = append(result, embeddings)
+	}
+
+	return result
+}
+
+// embeddingsOption returns the textual inversion choices, or nil when none are loaded
+func embeddingsOption(embs *stable_diffusion_api.EmbeddingsResponse) *discordgo.ApplicationCommandOption {
+	if embs == nil || len(embs.Loaded) == 0 {
+		return nil
+	}
+
+	var options []*discordgo.ApplicationCommandOptionChoice
+	for name, embedding := range embs.Loaded {
+		options = append(options, &discordgo.ApplicationCommandOptionChoice{
+			Name:  embeddingChoiceName(name, embedding),
+			Value: name,
+		})
+
+		// Max 25 choices
+		// https://discord.com/developers/docs/interactions/application-commands#application-command-object-application-command-option-structure
+		if len(options) == 25 {
+			log.Printf("Loaded 25/%d textual inversions...", len(embs.Loaded))
+			break
+		}
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         extOptionEmbeddings,
+		Description:  "Textual Inversion",
+		Required:     false,
+		Autocomplete: false,
+		Choices:      options,
+	}
+}
+
+// Choice names are limited to 100 characters
+const maxChoiceNameLength = 100
+
+// embeddingChoiceName shows the checkpoint the embedding was trained on, when known, to help picking a compatible one
+func embeddingChoiceName(name string, embedding stable_diffusion_api.Embedding) string {
+	if embedding.SDCheckpointName != "" {
+		name = fmt.Sprintf("%s (checkpoint: %s)", name, embedding.SDCheckpointName)
+	}
+
+	return truncatePrompt(name, maxChoiceNameLength-len("..."))
+}
+
+// retryImagineExtEmbeddings keeps requesting the embeddings and edits the registered command once they are loaded
+func (b *botImpl) retryImagineExtEmbeddings(cmd *discordgo.ApplicationCommand, commandOptions []*discordgo.ApplicationCommandOption) {
+	for attempt := 1; attempt <= b.maxEmbeddingRetries; attempt++ {
+		time.Sleep(b.embeddingRetryDelay)
+
+		embs, err := b.stableDiffusionAPI.GetEmbeddingsFull(context.Background())
+		if err != nil {
+			log.Printf("Error getting embeddings (attempt %d/%d): %v", attempt, b.maxEmbeddingRetries, err)
+
+			continue
+		}
+
+		option := embeddingsOption(embs)
+		if option == nil {
+			return
+		}
+
+		_, err = b.botSession.ApplicationCommandEdit(b.botSession.State.User.ID, b.guildID, cmd.ID, &discordgo.ApplicationCommand{
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Options:     withEmbeddingsOption(commandOptions, option),
+		})
+		if err != nil {
+			log.Printf("Error editing '%s' command: %v", cmd.Name, err)
+		} else {
+			log.Printf("Added embeddings to the '%s' command", cmd.Name)
+		}
+
+		return
+	}
+}
+
+const (
+	settingsOptionTokenMerging         = `tome`
+	settingsOptionClipSkip             = `clip_skip`
+	settingsOptionPresetName           = `preset_name`
+	settingsOptionPresetSampler        = `preset_sampler`
+	settingsOptionPresetCFGScale       = `preset_cfg_scale`
+	settingsOptionPresetSteps          = `preset_steps`
+	settingsOptionPresetNegativePrompt = `preset_negative_prompt`
+)
+
 func (b *botImpl) addImagineSettingsCommand() error {
 	log.Printf("Adding command '%s'...", b.imagineSettingsCommandString())
 
+	// A negative ratio goes back to the server option
+	minRatio := -1.0
+	minNum := 1.0
 	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
 		Name:        b.imagineSettingsCommandString(),
 		Description: "Change the default settings for the imagine command",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        settingsOptionTokenMerging,
+				Description: "Token merging ratio, admins only (0.0 = disabled, 0.5 = maximum merger, -1 = server option)",
+				Required:    false,
+				MinValue:    &minRatio,
+				MaxValue:    0.5,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        settingsOptionClipSkip,
+				Description: "Default number of CLIP layers to skip from the end, admins only",
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    maxClipSkip,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        settingsOptionPresetName,
+				Description: "Add or replace a custom imagine_ext preset with this name, admins only",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        settingsOptionPresetSampler,
+				Description: "Sampler of the custom preset",
+				Required:    false,
+				Choices:     b.samplerChoices,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        settingsOptionPresetCFGScale,
+				Description: "CFG Scale of the custom preset",
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    30,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        settingsOptionPresetSteps,
+				Description: "Sampling steps of the custom preset",
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    50,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        settingsOptionPresetNegativePrompt,
+				Description: "Negative prompt of the custom preset",
+				Required:    false,
+			},
+		},
 	})
 	if err != nil {
 		log.Printf("Error creating '%s' command: %v", b.imagineSettingsCommandString(), err)
@@ -520,7 +1405,17 @@ func (b *botImpl) addImagineSettingsCommand() error {
 	return nil
 }
 
-const statsOptionUser = `user`
+const (
+	statsOptionUser        = `user`
+	statsOptionExport      = `export`
+	statsOptionLeaderboard = `leaderboard`
+	statsOptionHeatmap     = `heatmap`
+	statsOptionTimezone    = `timezone`
+	statsOptionDaily       = `daily`
+
+	leaderboardSize = 10
+	dailyStatsDays  = 7
+)
 
 func (b *botImpl) addStatsCommand() error {
 	log.Printf("Adding command '%s'...", b.imagineStatsCommandString())
@@ -534,6 +1429,32 @@ func (b *botImpl) addStatsCommand() error {
 				Name:        statsOptionUser,
 				Description: "Show stats for user",
 			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        statsOptionExport,
+				Description: "Export all statistics as a CSV file, admins only",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        statsOptionLeaderboard,
+				Description: "Show top generators of this server",
+			},
+			periodOption(),
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        statsOptionHeatmap,
+				Description: "Show generations by hour of day, admins only",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        statsOptionTimezone,
+				Description: "Server timezone for the heatmap, e.g. Europe/Moscow. Saved for later heatmaps",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        statsOptionDaily,
+				Description: "Show the activity of the last 7 days",
+			},
 		},
 	})
 	if err != nil {
@@ -547,193 +1468,1635 @@ func (b *botImpl) addStatsCommand() error {
 	return nil
 }
 
-func (b *botImpl) processImagineReroll(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
-		Type:               imagine_queue.ItemTypeReroll,
-		DiscordInteraction: i.Interaction,
-	})
-	if queueError != nil {
-		log.Printf("Error adding imagine to queue: %v\n", queueError)
-	}
+const (
+	mySettingsOptionSampler  = `sampler`
+	mySettingsOptionCFGScale = `cfg_scale`
+	mySettingsOptionSteps    = `steps`
+	mySettingsOptionNegative = `negative_prompt`
+	mySettingsOptionReset    = `reset`
+)
 
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("I'm reimagining that for you... You are currently #%d in line.", position),
-		},
-	})
-	if err != nil {
-		log.Printf("Error responding to interaction: %v", err)
-	}
-}
+func (b *botImpl) addImagineMySettingsCommand() error {
+	command := b.imagineMySettingsCommandString()
+	log.Printf("Adding command '%s'...", command)
 
-func (b *botImpl) processImagineUpscale(s *discordgo.Session, i *discordgo.InteractionCreate, upscaleIndex int) {
-	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
-		Type:               imagine_queue.ItemTypeUpscale,
-		InteractionIndex:   upscaleIndex,
-		DiscordInteraction: i.Interaction,
-	})
+	minNum := 1.0
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Change your personal defaults for the imagine commands",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        mySettingsOptionSampler,
+				Description: "Default sampler",
+				Required:    false,
+				Choices:     b.samplerChoices,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        mySettingsOptionCFGScale,
+				Description: "Default CFG Scale",
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    30,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        mySettingsOptionSteps,
+				Description: "Default sampling steps",
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    50,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        mySettingsOptionNegative,
+				Description: "Default negative prompt, replaces the server one",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        mySettingsOptionReset,
+				Description: "Remove all your personal defaults",
+				Required:    false,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+const (
+	regionalOptionBasePrompt   = `base_prompt`
+	regionalOptionPromptLeft   = `prompt_left`
+	regionalOptionPromptRight  = `prompt_right`
+	regionalOptionPromptTop    = `prompt_top`
+	regionalOptionPromptBottom = `prompt_bottom`
+	regionalOptionBaseRatio    = `base_ratio`
+
+	defaultRegionalBaseRatio = 0.2
+)
+
+// addImagineRegionalCommand registers the regional command only when the regional prompter extension is installed
+func (b *botImpl) addImagineRegionalCommand() error {
+	command := b.imagineRegionalCommandString()
+
+	extensions, err := b.stableDiffusionAPI.GetExtensions(context.Background())
+	if err != nil {
+		log.Printf("Error getting extensions, skipping command '%s': %v", command, err)
+
+		return nil
+	}
+
+	if !stable_diffusion_api.HasExtension(extensions, stable_diffusion_api.RegionalPrompterExtension) {
+		log.Printf("Extension '%s' is not installed, skipping command '%s'",
+			stable_diffusion_api.RegionalPrompterExtension, command)
+
+		return nil
+	}
+
+	log.Printf("Adding command '%s'...", command)
+
+	minNum := 1.0
+	minRatio := 0.0
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Ask the bot to imagine something with different prompts for image regions",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        regionalOptionPromptLeft,
+				Description: "The prompt for the left half of the image",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        regionalOptionPromptRight,
+				Description: "The prompt for the right half of the image",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        regionalOptionPromptTop,
+				Description: "The prompt for the top half of the image (instead of left/right)",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        regionalOptionPromptBottom,
+				Description: "The prompt for the bottom half of the image (instead of left/right)",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        regionalOptionBasePrompt,
+				Description: "The prompt applied to the whole image",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        regionalOptionBaseRatio,
+				Description: fmt.Sprintf("Weight of the base prompt (%v)", defaultRegionalBaseRatio),
+				Required:    false,
+				MinValue:    &minRatio,
+				MaxValue:    1,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        extOptionNegativePrompt,
+				Description: "Negative prompt",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        extOptionCFGScale,
+				Description: fmt.Sprintf("CFG Scale (%d)", imagine_queue.DefaultCFGScale),
+				Required:    false,
+				MinValue:    &minNum,
+				MaxValue:    30,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        extOptionSeed,
+				Description: fmt.Sprintf("Seed (%d)", imagine_queue.DefaultSeed),
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        extOptionSampler,
+				Description: fmt.Sprintf("Sampler (%s)", imagine_queue.DefaultSampler),
+				Required:    false,
+				Choices:     b.samplerChoices,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        extOptionSteps,
+				Description: fmt.Sprintf("Sampling Steps (%d)", imagine_queue.DefaultSteps),
+				MinValue:    &minNum,
+				MaxValue:    50,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+const interrogateOptionImage = `image`
+
+func (b *botImpl) addImagineInterrogateCommand() error {
+	command := b.imagineInterrogateCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Describe an image and show its generation parameters",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Name:        interrogateOptionImage,
+				Description: "The image to interrogate",
+				Required:    true,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+const rawOptionParamsJSON = `params_json`
+
+// Discord allows up to 10 attachments per message
+const maxRawImages = 10
+
+// Same limit as the steps option of /imagine_ext
+const maxRawSteps = 50
+
+func (b *botImpl) addImagineRawCommand() error {
+	command := b.imagineRawCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Send txt2img parameters directly to the API, for power users",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        rawOptionParamsJSON,
+				Description: "Full txt2img request JSON",
+				Required:    true,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+const (
+	pinOptionMessageID = `message_id`
+	pinOptionUnpin     = `unpin`
+
+	pinReaction = "📌"
+)
+
+func (b *botImpl) addImagineQueueCommand() error {
+	command := b.imagineQueueCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Show the items in the queue and how long they are waiting",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) addImagineFlushQueueCommand() error {
+	command := b.imagineFlushQueueCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Remove all waiting items from the queue, admins only",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) addImagineStatusCommand() error {
+	command := b.imagineStatusCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Show the queue performance, admins only",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+const (
+	notificationsOptionDM = `dm`
+
+	notificationsOn  = `on`
+	notificationsOff = `off`
+)
+
+func (b *botImpl) addImagineNotificationsCommand() error {
+	command := b.imagineNotificationsCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Choose whether to get a direct message when your queued images are ready",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        notificationsOptionDM,
+				Description: "Direct message notifications",
+				Required:    true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: notificationsOn, Value: notificationsOn},
+					{Name: notificationsOff, Value: notificationsOff},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) addImaginePinCommand() error {
+	command := b.imaginePinCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Pin a generation result message in this channel",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        pinOptionMessageID,
+				Description: "ID of the message to pin",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        pinOptionUnpin,
+				Description: "Unpin the message instead",
+				Required:    false,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineReroll(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Type:               imagine_queue.ItemTypeReroll,
+		SourceMessageID:    i.Message.ID,
+		DiscordInteraction: i.Interaction,
+	})
+	if b.respondRateLimited(s, i, queueError) {
+		return
+	} else if queueError != nil {
+		log.Printf("Error adding imagine to queue: %v\n", queueError)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("I'm reimagining that for you... You are currently #%d in line.", position),
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineUpscale(s *discordgo.Session, i *discordgo.InteractionCreate, upscaleIndex int) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	// Discord doesn't send clicks on disabled buttons, but an outdated client may still show them enabled
+	if buttonDisabled(i.Message.Components, fmt.Sprintf("imagine_upscale_%d", upscaleIndex)) {
+		respondEphemeral(s, i, "This image has already been upscaled.")
+
+		return
+	}
+
+	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Type:               imagine_queue.ItemTypeUpscale,
+		InteractionIndex:   upscaleIndex,
+		DiscordInteraction: i.Interaction,
+	})
+	if queueError != nil {
+		log.Printf("Error adding imagine to queue: %v\n", queueError)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("I'm upscaling that for you... You are currently #%d in line.", position),
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// processImagineDelete removes a generated grid on request of the user who asked for it, or a moderator
+func (b *botImpl) processImagineDelete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	requesterID := ""
+
+	generation, err := b.generationRepo.GetByMessage(context.Background(), i.Message.ID)
+	if err != nil {
+		log.Printf("Error getting image generation: %v", err)
+	} else {
+		requesterID = generation.MemberID
+	}
+
+	if (requesterID == "" || interactionUser(i).ID != requesterID) && !hasPermission(i, discordgo.PermissionManageMessages) {
+		respondEphemeral(s, i, "Only the user who asked for these images can delete them.")
+
+		return
+	}
+
+	err = s.ChannelMessageDelete(i.ChannelID, i.Message.ID)
+	if err != nil {
+		log.Printf("Error deleting message %s: %v", i.Message.ID, err)
+
+		respondEphemeral(s, i, "I couldn't delete this message.")
+
+		return
+	}
+
+	err = b.generationRepo.MarkDeleted(context.Background(), i.Message.ID)
+	if err != nil {
+		log.Printf("Error marking generation as deleted: %v", err)
+	}
+
+	respondEphemeral(s, i, "The images are deleted.")
+}
+
+func (b *botImpl) processImagineUpscaleAll(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Type:               imagine_queue.ItemTypeBatchUpscale,
+		DiscordInteraction: i.Interaction,
+	})
 	if queueError != nil {
 		log.Printf("Error adding imagine to queue: %v\n", queueError)
+
+		respondEphemeral(s, i, "I'm sorry, but I couldn't queue the upscale.")
+
+		return
+	}
+
+	// The button is removed from the grid message so that the grid can't be upscaled twice
+	content := i.Message.Content + fmt.Sprintf("\nUpscaling all images... #%d in line.", position)
+	components := withoutButton(i.Message.Components, "imagine_upscale_all")
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// withoutButton returns the message components without the button with the given custom ID
+func withoutButton(components []discordgo.MessageComponent, customID string) []discordgo.MessageComponent {
+	result := make([]discordgo.MessageComponent, 0, len(components))
+
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			result = append(result, component)
+
+			continue
+		}
+
+		newRow := discordgo.ActionsRow{}
+
+		for _, rowComponent := range row.Components {
+			if button, isButton := rowComponent.(*discordgo.Button); isButton && button.CustomID == customID {
+				continue
+			}
+
+			newRow.Components = append(newRow.Components, rowComponent)
+		}
+
+		if len(newRow.Components) > 0 {
+			result = append(result, newRow)
+		}
+	}
+
+	return result
+}
+
+// buttonDisabled reports whether the button with the given custom ID is disabled in the message components
+func buttonDisabled(components []discordgo.MessageComponent, customID string) bool {
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		for _, rowComponent := range row.Components {
+			if button, isButton := rowComponent.(*discordgo.Button); isButton && button.CustomID == customID {
+				return button.Disabled
+			}
+		}
+	}
+
+	return false
+}
+
+func (b *botImpl) processImagineVariation(s *discordgo.Session, i *discordgo.InteractionCreate, variationIndex int) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Type:               imagine_queue.ItemTypeVariation,
+		InteractionIndex:   variationIndex,
+		SourceMessageID:    i.Message.ID,
+		DiscordInteraction: i.Interaction,
+	})
+	if b.respondRateLimited(s, i, queueError) {
+		return
+	} else if queueError != nil {
+		log.Printf("Error adding imagine to queue: %v\n", queueError)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("I'm creating variations of image %d for you... You are currently #%d in line.", variationIndex, position),
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+const loggedPromptLength = 80
+
+// logQueuedImagine writes an audit line with the full parameter set of an enqueued imagine item
+func (b *botImpl) logQueuedImagine(i *discordgo.InteractionCreate, item *imagine_queue.QueueItem, position int) {
+	width, err := b.imagineQueue.GetDefaultBotWidth()
+	if err != nil {
+		log.Printf("Error getting default width: %v", err)
+	}
+
+	height, err := b.imagineQueue.GetDefaultBotHeight()
+	if err != nil {
+		log.Printf("Error getting default height: %v", err)
+	}
+
+	width, height = imagine_queue.ItemDimensions(item, width, height)
+
+	prompt := []rune(item.Prompt)
+	if len(prompt) > loggedPromptLength {
+		prompt = prompt[:loggedPromptLength]
+	}
+
+	log.Printf("Queued imagine: guild_id=%s channel_id=%s user_id=%s prompt=%q negative_prompt=%q "+
+		"sampler=%q steps=%d cfg_scale=%v dimensions=%dx%d seed=%d position=%d",
+		i.GuildID, i.ChannelID, interactionUser(i).ID, string(prompt), item.Options.NegativePrompt,
+		item.Options.SamplerName, item.Options.Steps, item.Options.CfgScale, width, height, item.Options.Seed, position)
+}
+
+// checkPromptLength responds with an ephemeral error and returns false when a prompt exceeds its limit
+func (b *botImpl) checkPromptLength(s *discordgo.Session, i *discordgo.InteractionCreate, prompt, negativePrompt string) bool {
+	if length := len([]rune(prompt)); b.maxPromptLength > 0 && length > b.maxPromptLength {
+		respondEphemeral(s, i, fmt.Sprintf("Your prompt is too long: %d characters, the limit is %d.",
+			length, b.maxPromptLength))
+
+		return false
+	}
+
+	if length := len([]rune(negativePrompt)); b.maxNegativeLength > 0 && length > b.maxNegativeLength {
+		respondEphemeral(s, i, fmt.Sprintf("Your negative prompt is too long: %d characters, the limit is %d.",
+			length, b.maxNegativeLength))
+
+		return false
+	}
+
+	return true
+}
+
+// TODO: add option to enable usage in DM
+func (b *botImpl) processImagineCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, opt := range options {
+		optionMap[opt.Name] = opt
+	}
+
+	var position int
+	var queueError error
+	var prompt string
+	var translated bool
+
+	// Do not allow DM usage
+	isDM := i.GuildID == ""
+
+	if _, ok := optionMap["prompt"]; !ok && !isDM {
+		b.respondImagineModal(s, i)
+
+		return
+	}
+
+	if option, ok := optionMap["prompt"]; ok {
+		// Translations can be longer than the original, so the translated prompt is checked
+		prompt, translated = b.translatePrompt(option.StringValue())
+
+		if !b.checkPromptLength(s, i, prompt, "") {
+			return
+		}
+
+		if !isDM {
+			item := &imagine_queue.QueueItem{
+				Prompt:             prompt,
+				Options:            b.imagineQueue.NewMemberQueueItemOptions(i.GuildID, interactionUser(i).ID),
+				Type:               imagine_queue.ItemTypeImagine,
+				DiscordInteraction: i.Interaction,
+			}
+
+			position, queueError = b.imagineQueue.AddImagine(item)
+			if b.respondRateLimited(s, i, queueError) {
+				return
+			} else if queueError != nil {
+				log.Printf("Error adding imagine to queue: %v\n", queueError)
+			} else {
+				b.logQueuedImagine(i, item, position)
+			}
+		}
+	}
+
+	message := "DM usage is not allowed."
+	if !isDM {
+		message = fmt.Sprintf(
+			"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
+			position,
+			interactionUser(i).ID,
+			sanitizePromptForDisplay(truncatePrompt(prompt, maxDisplayedPromptLength)),
+		)
+	}
+
+	if !isDM && queueError == nil {
+		message += b.truncationWarning(prompt, translated)
+	}
+
+	if translated {
+		message += fmt.Sprintf("\nTranslated from: `%s`.",
+			sanitizePromptForDisplay(truncatePrompt(optionMap["prompt"].StringValue(), maxDisplayedPromptLength)))
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+		},
+	})
+	if err != nil {
+		log.Printf("Error send interaction resp: %v\n", err)
+	}
+}
+
+func (b *botImpl) processImagineExtCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+
+	// Do not allow DM usage
+	isDM := i.GuildID == ""
+
+	queueOptions := imagine_queue.NewQueueItemOptions()
+	if !isDM {
+		queueOptions = b.imagineQueue.NewMemberQueueItemOptions(i.GuildID, interactionUser(i).ID)
+	}
+
+	// Preset values replace the defaults, explicitly passed options take precedence over the preset
+	for _, opt := range options {
+		if opt.Name == extOptionPreset {
+			preset := b.findPreset(i.GuildID, opt.StringValue())
+			if preset == nil {
+				respondEphemeral(s, i, fmt.Sprintf("Unknown preset `%s`.", opt.StringValue()))
+
+				return
+			}
+
+			applyPreset(&queueOptions, preset)
+		}
+	}
+
+	aspectRatio := ""
+	preview := false
+	for _, opt := range options {
+		switch opt.Name {
+		case extOptionAR:
+			aspectRatio = opt.StringValue()
+		case extOptionPrompt:
+			queueOptions.Prompt = opt.StringValue()
+		case extOptionNegativePrompt:
+			queueOptions.NegativePrompt = opt.StringValue()
+		case extOptionRestoreFaces:
+			queueOptions.RestoreFaces = opt.BoolValue()
+		case extOptionRestoreFacesWeight:
+			weight := opt.FloatValue()
+			queueOptions.CodeFormerWeight = &weight
+		case extOptionCFGScale:
+			queueOptions.CfgScale = opt.FloatValue()
+		case extOptionSeed:
+			queueOptions.Seed = int(opt.IntValue())
+		case extOptionSampler:
+			queueOptions.SamplerName = opt.StringValue()
+		case extOptionEmbeddings:
+			queueOptions.Prompt += `, ` + opt.StringValue()
+		case extOptionSteps:
+			queueOptions.Steps = int(opt.IntValue())
+		case extOptionNoiseMultiplier:
+			queueOptions.InitialNoiseMultiplier = opt.FloatValue()
+		case extOptionPreview:
+			preview = opt.BoolValue()
+		case extOptionControlNetImage:
+			attachment, err := attachmentOption(i, opt)
+			if err != nil {
+				log.Printf("Error getting attachment: %v", err)
+			} else {
+				queueOptions.ControlNetImageURL = attachment.URL
+			}
+		case extOptionControlNetModel:
+			queueOptions.ControlNetModel = opt.StringValue()
+		case extOptionHiresFix:
+			queueOptions.HiresFix = opt.BoolValue()
+		case extOptionHiresDenoising:
+			queueOptions.DenoisingStrength = opt.FloatValue()
+		case extOptionHiresSteps:
+			queueOptions.HiresSteps = int(opt.IntValue())
+		case extOptionHiresUpscaler:
+			queueOptions.HiresUpscaler = opt.StringValue()
+		case extOptionLoRA:
+			queueOptions.LoRAName = opt.StringValue()
+		case extOptionLoRAWeight:
+			queueOptions.LoRAWeight = opt.FloatValue()
+		case extOptionClipSkip:
+			queueOptions.ClipSkip = int(opt.IntValue())
+		case extOptionTiling:
+			queueOptions.Tiling = opt.BoolValue()
+		case extOptionWidth:
+			queueOptions.Width = int(opt.IntValue())
+		case extOptionHeight:
+			queueOptions.Height = int(opt.IntValue())
+		}
+	}
+
+	if message := validateCustomDimensions(queueOptions.Width, queueOptions.Height); message != "" {
+		respondEphemeral(s, i, message)
+
+		return
+	}
+
+	if (queueOptions.ControlNetImageURL == "") != (queueOptions.ControlNetModel == "") {
+		respondEphemeral(s, i, "ControlNet needs both `controlnet_image` and `controlnet_model`.")
+
+		return
+	}
+
+	// --ar in the prompt, e.g. copied from Midjourney, overrides the dropdown. The queue processor
+	// computes the dimensions and removes the flag from the prompt
+	if aspectRatio != "" && !imagine_queue.HasAspectRatioFlag(queueOptions.Prompt) {
+		queueOptions.Prompt += ` ` + aspectRatio
+	}
+
+	// CodeFormer weight only makes sense when faces are restored
+	if !queueOptions.RestoreFaces {
+		queueOptions.CodeFormerWeight = nil
+	}
+
+	if preview {
+		b.queuePreview(s, i, queueOptions, isDM)
+
+		return
+	}
+
+	b.queueImagineOptions(s, i, queueOptions, isDM)
+}
+
+// validateCustomDimensions returns the error message for invalid custom dimensions, empty when both are valid or unset
+func validateCustomDimensions(width, height int) string {
+	if width == 0 && height == 0 {
+		return ""
+	}
+
+	if width == 0 || height == 0 {
+		return "Please set both `width` and `height`, or neither of them."
+	}
+
+	for _, dimension := range []int{width, height} {
+		if dimension < minCustomDimension || dimension > maxCustomDimension || dimension%customDimensionStep != 0 {
+			return fmt.Sprintf("Width and height must be multiples of %d between %d and %d.",
+				customDimensionStep, minCustomDimension, maxCustomDimension)
+		}
+	}
+
+	return ""
+}
+
+// validateRawRequest returns the error message for a raw request exceeding the bot limits, empty when it is within them.
+// The request must be validated first, so that hires fix defaults are filled.
+func validateRawRequest(request *stable_diffusion_api.TextToImageRequest) string {
+	if images := request.NIter * request.BatchSize; images > maxRawImages {
+		return fmt.Sprintf("Too many images requested: %d, the limit is %d.", images, maxRawImages)
+	}
+
+	if request.Steps > maxRawSteps || request.HrSecondPassSteps > maxRawSteps {
+		return fmt.Sprintf("Too many steps requested, the limit is %d.", maxRawSteps)
+	}
+
+	for _, dimension := range []int{request.Width, request.Height} {
+		if dimension < minCustomDimension || dimension > maxCustomDimension {
+			return fmt.Sprintf("Width and height must be between %d and %d.", minCustomDimension, maxCustomDimension)
+		}
+	}
+
+	if !request.EnableHR {
+		return ""
+	}
+
+	hiresWidth := int(float32(request.Width) * request.HrScale)
+	hiresHeight := int(float32(request.Height) * request.HrScale)
+
+	if request.HRResizeX != 0 || request.HRResizeY != 0 {
+		hiresWidth, hiresHeight = request.HRResizeX, request.HRResizeY
+	}
+
+	if hiresWidth < 0 || hiresHeight < 0 || hiresWidth > maxCustomDimension || hiresHeight > maxCustomDimension {
+		return fmt.Sprintf("Hires fix width and height must not exceed %d.", maxCustomDimension)
+	}
+
+	return ""
+}
+
+// queueImagineOptions validates the prompt, queues the imagine item and responds with the position in line
+func (b *botImpl) queueImagineOptions(s *discordgo.Session, i *discordgo.InteractionCreate, queueOptions imagine_queue.QueueItemOptions, isDM bool) {
+	originalPrompt := queueOptions.Prompt
+
+	var translated bool
+	queueOptions.Prompt, translated = b.translatePrompt(queueOptions.Prompt)
+
+	if !b.checkPromptLength(s, i, queueOptions.Prompt, queueOptions.NegativePrompt) {
+		return
+	}
+
+	var position int
+	var queueError error
+
+	// Commands issued in the output channel itself are answered as usual
+	useOutputChannel := b.outputChannelID != "" && i.ChannelID != b.outputChannelID
+
+	if !isDM {
+		item := &imagine_queue.QueueItem{
+			Prompt:             queueOptions.Prompt,
+			Options:            queueOptions,
+			Type:               imagine_queue.ItemTypeImagine,
+			DiscordInteraction: i.Interaction,
+		}
+
+		if useOutputChannel {
+			item.OutputChannelID = b.outputChannelID
+		}
+
+		item.ChallengeEntry = b.isChallengeEntry(queueOptions.Prompt)
+
+		position, queueError = b.imagineQueue.AddImagine(item)
+		if b.respondRateLimited(s, i, queueError) {
+			return
+		} else if queueError != nil {
+			log.Printf("Error adding imagine to queue: %v\n", queueError)
+		} else {
+			b.logQueuedImagine(i, item, position)
+		}
+	}
+
+	message := "DM usage is not allowed."
+	if !isDM {
+		message = fmt.Sprintf(
+			"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
+			position,
+			interactionUser(i).ID,
+			sanitizePromptForDisplay(truncatePrompt(queueOptions.Prompt, maxDisplayedPromptLength)),
+		)
+	}
+
+	var flags discordgo.MessageFlags
+
+	if !isDM && useOutputChannel {
+		message = fmt.Sprintf("Generating... You are currently #%d in line, the result will be posted in <#%s>.",
+			position, b.outputChannelID)
+		flags = discordgo.MessageFlagsEphemeral
+	}
+
+	if !isDM && queueError == nil {
+		message += b.truncationWarning(queueOptions.Prompt, translated)
+	}
+
+	if translated {
+		message += fmt.Sprintf("\nTranslated from: `%s`.",
+			sanitizePromptForDisplay(truncatePrompt(originalPrompt, maxDisplayedPromptLength)))
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   flags,
+		},
+	})
+	if err != nil {
+		log.Printf("Error send interaction resp: %v\n", err)
+	}
+}
+
+// interactionUser returns the user who issued the interaction: the member's user in guilds, i.User in DMs
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+
+	if i.User != nil {
+		return i.User
+	}
+
+	return &discordgo.User{}
+}
+
+// respondRateLimited responds with the user's pending items and the limit when the queue rejected the item
+// for exceeding the per-user limit, reporting whether it did
+func (b *botImpl) respondRateLimited(s *discordgo.Session, i *discordgo.InteractionCreate, queueError error) bool {
+	message := rateLimitedMessage(queueError)
+	if message == "" {
+		return false
+	}
+
+	respondEphemeral(s, i, message)
+
+	return true
+}
+
+// rateLimitedMessage returns the message for an item rejected for exceeding the per-user limit,
+// empty for other errors
+func rateLimitedMessage(queueError error) string {
+	var rateLimitErr *imagine_queue.RateLimitError
+	if !errors.As(queueError, &rateLimitErr) {
+		return ""
+	}
+
+	return fmt.Sprintf("You already have %d of %d allowed requests in the queue. "+
+		"Please wait until one of them is finished.", rateLimitErr.Pending, rateLimitErr.Limit)
+}
+
+// hasPermission reports whether the member who issued the interaction has the given permission
+func hasPermission(i *discordgo.InteractionCreate, permission int64) bool {
+	if i.Member == nil {
+		return false
+	}
+
+	return i.Member.Permissions&permission == permission ||
+		i.Member.Permissions&discordgo.PermissionAdministrator == discordgo.PermissionAdministrator
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineRegionalCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "DM usage is not allowed.")
+
+		return
+	}
+
+	queueOptions := b.imagineQueue.NewMemberQueueItemOptions(i.GuildID, interactionUser(i).ID)
+
+	regions := make(map[string]string)
+	basePrompt := ""
+	baseRatio := defaultRegionalBaseRatio
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case regionalOptionPromptLeft, regionalOptionPromptRight, regionalOptionPromptTop, regionalOptionPromptBottom:
+			regions[opt.Name] = opt.StringValue()
+		case regionalOptionBasePrompt:
+			basePrompt = opt.StringValue()
+		case regionalOptionBaseRatio:
+			baseRatio = opt.FloatValue()
+		case extOptionNegativePrompt:
+			queueOptions.NegativePrompt = opt.StringValue()
+		case extOptionCFGScale:
+			queueOptions.CfgScale = opt.FloatValue()
+		case extOptionSeed:
+			queueOptions.Seed = int(opt.IntValue())
+		case extOptionSampler:
+			queueOptions.SamplerName = opt.StringValue()
+		case extOptionSteps:
+			queueOptions.Steps = int(opt.IntValue())
+		}
+	}
+
+	var first, second string
+	rows := false
+
+	switch {
+	case len(regions) != 2:
+		respondEphemeral(s, i, "Provide either `prompt_left` and `prompt_right`, or `prompt_top` and `prompt_bottom`.")
+
+		return
+	case regions[regionalOptionPromptLeft] != "" && regions[regionalOptionPromptRight] != "":
+		first, second = regions[regionalOptionPromptLeft], regions[regionalOptionPromptRight]
+	case regions[regionalOptionPromptTop] != "" && regions[regionalOptionPromptBottom] != "":
+		first, second = regions[regionalOptionPromptTop], regions[regionalOptionPromptBottom]
+		rows = true
+	default:
+		respondEphemeral(s, i, "Provide either `prompt_left` and `prompt_right`, or `prompt_top` and `prompt_bottom`.")
+
+		return
+	}
+
+	queueOptions.Prompt = first + " BREAK " + second
+	if basePrompt != "" {
+		queueOptions.Prompt = basePrompt + " ADDBASE " + queueOptions.Prompt
+	}
+
+	queueOptions.AlwaysonScripts = stable_diffusion_api.RegionalPrompterScripts(stable_diffusion_api.RegionalPrompterOptions{
+		Rows:      rows,
+		Ratios:    "1,1",
+		BaseRatio: baseRatio,
+		UseBase:   basePrompt != "",
+	})
+
+	item := &imagine_queue.QueueItem{
+		Prompt:             queueOptions.Prompt,
+		Options:            queueOptions,
+		Type:               imagine_queue.ItemTypeImagine,
+		DiscordInteraction: i.Interaction,
+	}
+
+	position, queueError := b.imagineQueue.AddImagine(item)
+	if b.respondRateLimited(s, i, queueError) {
+		return
+	} else if queueError != nil {
+		log.Printf("Error adding imagine to queue: %v\n", queueError)
+	} else {
+		b.logQueuedImagine(i, item, position)
 	}
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("I'm upscaling that for you... You are currently #%d in line.", position),
+			Content: fmt.Sprintf(
+				"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
+				position,
+				interactionUser(i).ID,
+				sanitizePromptForDisplay(truncatePrompt(queueOptions.Prompt, maxDisplayedPromptLength)),
+			),
 		},
 	})
+	if err != nil {
+		log.Printf("Error send interaction resp: %v\n", err)
+	}
+}
+
+func (b *botImpl) processImagineInterrogateCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var attachment *discordgo.MessageAttachment
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case interrogateOptionImage:
+			var err error
+
+			attachment, err = attachmentOption(i, opt)
+			if err != nil {
+				log.Printf("Error getting attachment: %v", err)
+			}
+		}
+	}
+
+	if attachment == nil {
+		respondEphemeral(s, i, "Please attach an image.")
+
+		return
+	}
+
+	// Downloading the attachment may take longer than the 3 seconds Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 	if err != nil {
 		log.Printf("Error responding to interaction: %v", err)
+
+		return
+	}
+
+	message := "I'm sorry, but I had a problem interrogating your image."
+
+	image, err := downloadAttachmentBase64(attachment)
+	if err != nil {
+		log.Printf("Error downloading attachment: %v", err)
+
+		editInteractionContent(s, i, message)
+
+		return
+	}
+
+	// The queue processor replaces this message with the caption, so it has to be posted before queueing
+	editInteractionContent(s, i, fmt.Sprintf("I'm looking at your image. You are currently #%d in line.",
+		len(b.imagineQueue.GetWaitingItems())+1))
+
+	_, err = b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Type:               imagine_queue.ItemTypeInterrogate,
+		InterrogateImage:   image,
+		DiscordInteraction: i.Interaction,
+	})
+	if rateLimited := rateLimitedMessage(err); rateLimited != "" {
+		editInteractionContent(s, i, rateLimited)
+	} else if err != nil {
+		log.Printf("Error adding interrogate to queue: %v\n", err)
+
+		editInteractionContent(s, i, message)
 	}
 }
 
-func (b *botImpl) processImagineVariation(s *discordgo.Session, i *discordgo.InteractionCreate, variationIndex int) {
+func editInteractionContent(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineRawCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		respondEphemeral(s, i, "You need the Manage Server permission to send raw requests.")
+
+		return
+	}
+
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "DM usage is not allowed.")
+
+		return
+	}
+
+	paramsJSON := ""
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case rawOptionParamsJSON:
+			paramsJSON = opt.StringValue()
+		}
+	}
+
+	var overrides struct {
+		OverrideSettings map[string]json.RawMessage `json:"override_settings"`
+	}
+
+	err := json.Unmarshal([]byte(paramsJSON), &overrides)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Invalid JSON: %v", err))
+
+		return
+	}
+
+	for _, key := range b.rawBlacklist {
+		if _, ok := overrides.OverrideSettings[key]; ok {
+			respondEphemeral(s, i, fmt.Sprintf("Override setting `%s` is not allowed.", key))
+
+			return
+		}
+	}
+
+	request := &stable_diffusion_api.TextToImageRequest{}
+
+	decoder := json.NewDecoder(strings.NewReader(paramsJSON))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(request)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Invalid JSON: %v", err))
+
+		return
+	}
+
+	err = request.Validate()
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Invalid parameters: %v", err))
+
+		return
+	}
+
+	if message := validateRawRequest(request); message != "" {
+		respondEphemeral(s, i, message)
+
+		return
+	}
+
+	if !b.checkPromptLength(s, i, request.Prompt, request.NegativePrompt) {
+		return
+	}
+
 	position, queueError := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
-		Type:               imagine_queue.ItemTypeVariation,
-		InteractionIndex:   variationIndex,
+		Prompt:             request.Prompt,
+		Type:               imagine_queue.ItemTypeRaw,
+		RawRequest:         request,
 		DiscordInteraction: i.Interaction,
 	})
-	if queueError != nil {
+	if b.respondRateLimited(s, i, queueError) {
+		return
+	} else if queueError != nil {
 		log.Printf("Error adding imagine to queue: %v\n", queueError)
 	}
 
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("I'm imagining more variations for you... You are currently #%d in line.", position),
+			Content: fmt.Sprintf(
+				"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
+				position,
+				interactionUser(i).ID,
+				sanitizePromptForDisplay(truncatePrompt(request.Prompt, maxDisplayedPromptLength)),
+			),
 		},
 	})
 	if err != nil {
-		log.Printf("Error responding to interaction: %v", err)
+		log.Printf("Error send interaction resp: %v\n", err)
 	}
 }
 
-// TODO: add option to enable usage in DM
-func (b *botImpl) processImagineCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	options := i.ApplicationCommandData().Options
-
-	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt
-	}
+func (b *botImpl) processImagineExtAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
 
-	var position int
-	var queueError error
-	var prompt string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if !opt.Focused || opt.Name != extOptionPreset {
+			continue
+		}
 
-	// Do not allow DM usage
-	isDM := i.GuildID == ""
+		typed := strings.ToLower(opt.StringValue())
 
-	if option, ok := optionMap["prompt"]; ok {
-		prompt = option.StringValue()
+		for _, preset := range b.presets(i.GuildID) {
+			if !strings.Contains(strings.ToLower(preset.Name), typed) {
+				continue
+			}
 
-		if !isDM {
-			position, queueError = b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
-				Prompt:             prompt,
-				Options:            imagine_queue.NewQueueItemOptions(),
-				Type:               imagine_queue.ItemTypeImagine,
-				DiscordInteraction: i.Interaction,
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  preset.Name,
+				Value: preset.Name,
 			})
-			if queueError != nil {
-				log.Printf("Error adding imagine to queue: %v\n", queueError)
+
+			if len(choices) == maxPresetChoices {
+				break
 			}
 		}
 	}
 
-	message := "DM usage is not allowed."
-	if !isDM {
-		message = fmt.Sprintf(
-			"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine \"%s\".",
-			position,
-			i.Member.User.ID,
-			prompt,
-		)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to autocomplete: %v", err)
+	}
+}
+
+func (b *botImpl) processImaginePinCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionManageMessages) {
+		respondEphemeral(s, i, "You need the Manage Messages permission to pin messages.")
+
+		return
+	}
+
+	messageID := ""
+	unpin := false
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case pinOptionMessageID:
+			messageID = strings.TrimSpace(opt.StringValue())
+		case pinOptionUnpin:
+			unpin = opt.BoolValue()
+		}
+	}
+
+	var err error
+
+	if unpin {
+		err = s.ChannelMessageUnpin(i.ChannelID, messageID)
+	} else {
+		err = s.ChannelMessagePin(i.ChannelID, messageID)
+	}
+
+	if err != nil {
+		log.Printf("Error pinning message %s: %v", messageID, err)
+
+		respondEphemeral(s, i, "I couldn't change the pin of that message. Is it in this channel?")
+
+		return
+	}
+
+	if unpin {
+		err = s.MessageReactionRemove(i.ChannelID, messageID, pinReaction, "@me")
+	} else {
+		err = s.MessageReactionAdd(i.ChannelID, messageID, pinReaction)
+	}
+
+	if err != nil {
+		log.Printf("Error changing pin reaction: %v", err)
+	}
+
+	err = b.generationRepo.SetPinned(context.Background(), messageID, !unpin)
+	if err != nil {
+		log.Printf("Error updating pinned generation: %v", err)
+	}
+
+	message := "Message pinned."
+	if unpin {
+		message = "Message unpinned."
+	}
+
+	respondEphemeral(s, i, message)
+}
+
+func (b *botImpl) processImagineQueueCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	active := b.imagineQueue.GetActiveItems()
+	waiting := b.imagineQueue.GetWaitingItems()
+
+	if len(active) == 0 && len(waiting) == 0 {
+		respondEphemeral(s, i, "The queue is empty.")
+
+		return
+	}
+
+	lines := make([]string, 0, len(active)+len(waiting))
+
+	for _, item := range active {
+		lines = append(lines, fmt.Sprintf("Processing: %s, queued %s ago", describeQueueItem(item), queueItemAge(item)))
+	}
+
+	for idx, item := range waiting {
+		lines = append(lines, fmt.Sprintf("#%d: %s, waiting %s", idx+1, describeQueueItem(item), queueItemAge(item)))
+	}
+
+	respondEphemeral(s, i, joinLines(lines, maxMessageLength))
+}
+
+func (b *botImpl) processImagineStatusCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can see the status.")
+
+		return
+	}
+
+	ctx := context.Background()
+
+	average, err := b.statisticsRepo.GetAverageQueueWaitTime(ctx, i.GuildID)
+	if err != nil {
+		log.Printf("Error getting average queue wait time: %v", err)
+
+		respondEphemeral(s, i, "Error getting the status...")
+
+		return
+	}
+
+	percentiles, err := b.statisticsRepo.GetQueueWaitPercentiles(ctx, i.GuildID, 50, 95)
+	if err != nil {
+		log.Printf("Error getting queue wait percentiles: %v", err)
+
+		respondEphemeral(s, i, "Error getting the status...")
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Waiting in line: %d\nAverage queue wait: %s\nP50: %s, P95: %s",
+		len(b.imagineQueue.GetWaitingItems()),
+		average.Round(time.Second), percentiles[0].Round(time.Second), percentiles[1].Round(time.Second)))
+}
+
+// processImagineFlushQueueCommand asks for a confirmation, the queue is flushed by processImagineFlushConfirm
+func (b *botImpl) processImagineFlushQueueCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can flush the queue.")
+
+		return
+	}
+
+	waiting := len(b.imagineQueue.GetWaitingItems())
+	if waiting == 0 {
+		respondEphemeral(s, i, "There are no waiting items in the queue.")
+
+		return
 	}
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: message,
+			Content: fmt.Sprintf("Remove %d waiting items from the queue? Items being generated are not affected.", waiting),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Flush",
+							Style:    discordgo.DangerButton,
+							CustomID: "imagine_flush_confirm",
+						},
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: "imagine_flush_cancel",
+						},
+					},
+				},
+			},
 		},
 	})
 	if err != nil {
-		log.Printf("Error send interaction resp: %v\n", err)
+		log.Printf("Error responding to interaction: %v", err)
 	}
 }
 
-func (b *botImpl) processImagineExtCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	options := i.ApplicationCommandData().Options
+func (b *botImpl) processImagineFlushConfirm(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can flush the queue.")
 
-	queueOptions := imagine_queue.NewQueueItemOptions()
-	aspectRatio := ""
-	for _, opt := range options {
-		switch opt.Name {
-		case extOptionAR:
-			aspectRatio = opt.StringValue()
+		return
+	}
 
-			if aspectRatio != "" {
-				queueOptions.Prompt += ` ` + aspectRatio
-			}
-		case extOptionPrompt:
-			queueOptions.Prompt = opt.StringValue()
-		case extOptionNegativePrompt:
-			queueOptions.NegativePrompt = opt.StringValue()
-		case extOptionRestoreFaces:
-			queueOptions.RestoreFaces = opt.BoolValue()
-		case extOptionCFGScale:
-			queueOptions.CfgScale = opt.FloatValue()
-		case extOptionSeed:
-			queueOptions.Seed = int(opt.IntValue())
-		case extOptionSampler:
-			queueOptions.SamplerName = opt.StringValue()
-		case extOptionEmbeddings:
-			queueOptions.Prompt += `, ` + opt.StringValue()
-		case extOptionSteps:
-			queueOptions.Steps = int(opt.IntValue())
-		}
+	content := "Error flushing the queue..."
+
+	flushed, err := b.imagineQueue.Flush()
+	if err != nil {
+		log.Printf("Error flushing the queue: %v", err)
+	} else {
+		log.Printf("%s flushed %d pending items from the queue", interactionUser(i).Username, flushed)
+
+		content = fmt.Sprintf("Flushed %d pending items from the queue.", flushed)
+	}
+
+	updateFlushMessage(s, i, content)
+}
+
+func (b *botImpl) processImagineFlushCancel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	updateFlushMessage(s, i, "The queue was not flushed.")
+}
+
+// updateFlushMessage replaces the confirmation, removing the buttons
+func updateFlushMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
 	}
+}
 
-	var position int
-	var queueError error
+func describeQueueItem(item *imagine_queue.QueueItem) string {
+	userID := ""
+	if item.DiscordInteraction.Member != nil {
+		userID = item.DiscordInteraction.Member.User.ID
+	}
 
-	// Do not allow DM usage
-	isDM := i.GuildID == ""
+	switch item.Type {
+	case imagine_queue.ItemTypeUpscale, imagine_queue.ItemTypeBatchUpscale:
+		return fmt.Sprintf("upscale for <@%s>", userID)
+	case imagine_queue.ItemTypeVariation:
+		return fmt.Sprintf("variation for <@%s>", userID)
+	case imagine_queue.ItemTypeReroll:
+		return fmt.Sprintf("re-roll for <@%s>", userID)
+	case imagine_queue.ItemTypeInterrogate:
+		return fmt.Sprintf("caption for <@%s>", userID)
+	case imagine_queue.ItemTypeImg2Img:
+		return fmt.Sprintf("img2img for <@%s>", userID)
+	}
 
-	if !isDM {
-		position, queueError = b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
-			Prompt:             queueOptions.Prompt,
-			Options:            queueOptions,
-			Type:               imagine_queue.ItemTypeImagine,
-			DiscordInteraction: i.Interaction,
-		})
-		if queueError != nil {
-			log.Printf("Error adding imagine to queue: %v\n", queueError)
-		}
+	prompt := item.Prompt
+	if item.RawRequest != nil {
+		prompt = item.RawRequest.Prompt
 	}
 
-	message := "DM usage is not allowed."
-	if !isDM {
-		message = fmt.Sprintf(
-			"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
-			position,
-			i.Member.User.ID,
-			queueOptions.Prompt,
-		)
+	return fmt.Sprintf("<@%s> `%s`", userID, sanitizePromptForDisplay(truncatePrompt(prompt, loggedPromptLength)))
+}
+
+func queueItemAge(item *imagine_queue.QueueItem) time.Duration {
+	return time.Since(item.CreatedAt).Round(time.Second)
+}
+
+func (b *botImpl) processImagineNotificationsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	enabled := true
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case notificationsOptionDM:
+			enabled = opt.StringValue() == notificationsOn
+		}
 	}
 
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: message,
-		},
-	})
+	err := b.imagineQueue.UpdateDMNotifications(interactionUser(i).ID, enabled)
 	if err != nil {
-		log.Printf("Error send interaction resp: %v\n", err)
+		log.Printf("Error updating notification preferences: %v", err)
+
+		respondEphemeral(s, i, "I couldn't save your notification preferences.")
+
+		return
+	}
+
+	if enabled {
+		respondEphemeral(s, i, "I will send you a direct message when your long-waiting images are ready.")
+	} else {
+		respondEphemeral(s, i, "I won't send you direct messages anymore.")
 	}
 }
 
 func (b *botImpl) processImagineSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	preset := &Preset{}
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case settingsOptionTokenMerging:
+			b.processImagineTokenMergingSetting(s, i, opt.FloatValue())
+
+			return
+		case settingsOptionClipSkip:
+			b.processImagineClipSkipSetting(s, i, int(opt.IntValue()))
+
+			return
+		case settingsOptionPresetName:
+			preset.Name = opt.StringValue()
+		case settingsOptionPresetSampler:
+			preset.Sampler = opt.StringValue()
+		case settingsOptionPresetCFGScale:
+			preset.CFGScale = opt.FloatValue()
+		case settingsOptionPresetSteps:
+			preset.Steps = int(opt.IntValue())
+		case settingsOptionPresetNegativePrompt:
+			preset.NegativePrompt = opt.StringValue()
+		}
+	}
+
+	if preset.Name != "" {
+		b.processImagineCustomPresetSetting(s, i, preset)
+
+		return
+	}
+
 	defaultWidth, err := b.imagineQueue.GetDefaultBotWidth()
 	if err != nil {
 		log.Printf("error getting default width for settings command: %v", err)
@@ -751,6 +3114,7 @@ func (b *botImpl) processImagineSettingsCommand(s *discordgo.Session, i *discord
 		Data: &discordgo.InteractionResponseData{
 			Title:   "Settings",
 			Content: "Choose defaults settings for the imagine command:",
+			Embeds:  []*discordgo.MessageEmbed{b.settingsEmbed(i.GuildID)},
 			Components: []discordgo.MessageComponent{
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
@@ -781,36 +3145,433 @@ func (b *botImpl) processImagineSettingsCommand(s *discordgo.Session, i *discord
 	}
 }
 
+// Discord limits embed field values to 1024 characters
+const maxEmbedFieldLength = 1000
+
+// settingsEmbed lists the current server defaults of the imagine command
+func (b *botImpl) settingsEmbed(guildID string) *discordgo.MessageEmbed {
+	width, err := b.imagineQueue.GetDefaultBotWidth()
+	if err != nil {
+		log.Printf("error getting default width for settings command: %v", err)
+	}
+
+	height, err := b.imagineQueue.GetDefaultBotHeight()
+	if err != nil {
+		log.Printf("error getting default height for settings command: %v", err)
+	}
+
+	sampler, err := b.imagineQueue.GetDefaultSampler(guildID, "")
+	if err != nil {
+		log.Printf("error getting default sampler for settings command: %v", err)
+	}
+
+	cfgScale, err := b.imagineQueue.GetDefaultCFGScale(guildID, "")
+	if err != nil {
+		log.Printf("error getting default CFG scale for settings command: %v", err)
+	}
+
+	steps, err := b.imagineQueue.GetDefaultSteps(guildID, "")
+	if err != nil {
+		log.Printf("error getting default steps for settings command: %v", err)
+	}
+
+	tokenMergingRatio := "server setting"
+
+	tokenMergingValue, err := b.imagineQueue.GetDefaultTokenMergingRatio(guildID)
+	if err != nil {
+		log.Printf("error getting default token merging ratio for settings command: %v", err)
+	} else if tokenMergingValue != nil {
+		tokenMergingRatio = strconv.FormatFloat(*tokenMergingValue, 'f', -1, 64)
+	}
+
+	clipSkip := "server setting"
+
+	clipSkipValue, err := b.imagineQueue.GetDefaultClipSkip(guildID, "")
+	if err != nil {
+		log.Printf("error getting default CLIP skip for settings command: %v", err)
+	} else if clipSkipValue > 0 {
+		clipSkip = strconv.Itoa(clipSkipValue)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "Current settings",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Size", Value: fmt.Sprintf("%dx%d", width, height), Inline: true},
+			{Name: "Sampler", Value: sampler, Inline: true},
+			{Name: "CFG scale", Value: strconv.FormatFloat(cfgScale, 'f', -1, 64), Inline: true},
+			{Name: "Steps", Value: strconv.Itoa(steps), Inline: true},
+			{Name: "Token merging ratio", Value: tokenMergingRatio, Inline: true},
+			{Name: "CLIP skip", Value: clipSkip, Inline: true},
+			{Name: "Restore faces", Value: strconv.FormatBool(imagine_queue.DefaultRestoreFaces), Inline: true},
+			{Name: "Hires fix", Value: strconv.FormatBool(imagine_queue.DefaultHiRes), Inline: true},
+			{Name: "Denoising strength", Value: strconv.FormatFloat(imagine_queue.DefaultDenoisingStrength, 'f', -1, 64), Inline: true},
+			{Name: "Negative prompt", Value: truncatePrompt(imagine_queue.DefaultNegative, maxEmbedFieldLength)},
+		},
+	}
+}
+
+func (b *botImpl) processImagineTokenMergingSetting(s *discordgo.Session, i *discordgo.InteractionCreate, ratio float64) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can change the token merging ratio.")
+
+		return
+	}
+
+	if ratio < 0 {
+		ratio = -1
+	}
+
+	err := b.imagineQueue.UpdateGuildSetting(i.GuildID, settings.KeyTokenMergingRatio, strconv.FormatFloat(ratio, 'f', -1, 64))
+	if err != nil {
+		log.Printf("error updating default token merging ratio: %v", err)
+
+		respondEphemeral(s, i, "Error updating token merging ratio...")
+
+		return
+	}
+
+	var message string
+
+	switch {
+	case ratio < 0:
+		message = "Token merging ratio is reset to the server option."
+	case ratio == 0:
+		message = "Token merging is disabled."
+	default:
+		message = fmt.Sprintf("Token merging ratio is set to `%.2f`.", ratio)
+	}
+
+	sdOptions, err := b.stableDiffusionAPI.GetSDOptions(context.Background())
+	if err != nil {
+		log.Printf("Error getting SD options: %v", err)
+	} else {
+		message += fmt.Sprintf(" Server global value: `%.2f`.", sdOptions.TokenMergingRatio)
+	}
+
+	respondEphemeral(s, i, message)
+}
+
+// Maximum CLIP skip of the settings and imagine_ext options, SD 1.x text encoders have 12 layers
+const maxClipSkip = 12
+
+func (b *botImpl) processImagineClipSkipSetting(s *discordgo.Session, i *discordgo.InteractionCreate, clipSkip int) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can change the CLIP skip.")
+
+		return
+	}
+
+	err := b.imagineQueue.UpdateGuildSetting(i.GuildID, settings.KeyClipSkip, strconv.Itoa(clipSkip))
+	if err != nil {
+		log.Printf("error updating default CLIP skip: %v", err)
+
+		respondEphemeral(s, i, "Error updating CLIP skip...")
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("CLIP skip is set to `%d`.", clipSkip))
+}
+
+func (b *botImpl) processImagineMySettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "DM usage is not allowed.")
+
+		return
+	}
+
+	memberID := i.Member.User.ID
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		var err error
+
+		switch opt.Name {
+		case mySettingsOptionReset:
+			if opt.BoolValue() {
+				err = b.imagineQueue.ResetMemberSettings(i.GuildID, memberID)
+			}
+		case mySettingsOptionSampler:
+			err = b.imagineQueue.UpdateMemberSetting(i.GuildID, memberID, settings.KeySampler, opt.StringValue())
+		case mySettingsOptionCFGScale:
+			err = b.imagineQueue.UpdateMemberSetting(i.GuildID, memberID, settings.KeyCFGScale,
+				strconv.FormatFloat(opt.FloatValue(), 'f', -1, 64))
+		case mySettingsOptionSteps:
+			err = b.imagineQueue.UpdateMemberSetting(i.GuildID, memberID, settings.KeySteps,
+				strconv.FormatInt(opt.IntValue(), 10))
+		case mySettingsOptionNegative:
+			if !b.checkPromptLength(s, i, "", opt.StringValue()) {
+				return
+			}
+
+			err = b.imagineQueue.UpdateMemberSetting(i.GuildID, memberID, settings.KeyNegativePrompt, opt.StringValue())
+		}
+
+		if err != nil {
+			log.Printf("Error updating member settings: %v", err)
+
+			respondEphemeral(s, i, "Error updating your settings...")
+
+			return
+		}
+	}
+
+	memberSettings, err := b.imagineQueue.GetMemberSettings(i.GuildID, memberID)
+	if err != nil {
+		log.Printf("Error getting member settings: %v", err)
+
+		respondEphemeral(s, i, "Error getting your settings...")
+
+		return
+	}
+
+	message := "You have no personal defaults, server defaults are used."
+	if len(memberSettings) > 0 {
+		message = "Your personal defaults:"
+		for _, setting := range memberSettings {
+			message += fmt.Sprintf("\n- %s: `%s`", setting.Key, setting.Value)
+		}
+	}
+
+	respondEphemeral(s, i, message)
+}
+
+func (b *botImpl) processImagineCustomPresetSetting(s *discordgo.Session, i *discordgo.InteractionCreate, preset *Preset) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can add presets.")
+
+		return
+	}
+
+	err := b.saveCustomPreset(i.GuildID, preset)
+	if err != nil {
+		log.Printf("Error saving custom preset: %v", err)
+
+		respondEphemeral(s, i, "Error saving the preset...")
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Preset `%s` is saved.", preset.Name))
+}
+
 func (b *botImpl) processImagineStatsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	message := "Something wrong."
 
 	options := i.ApplicationCommandData().Options
 
+	heatmap := false
+	timezone := ""
+
 	member := i.Member.User
 	for _, opt := range options {
 		switch opt.Name {
 		case statsOptionUser:
 			member = opt.UserValue(s)
+		case statsOptionHeatmap:
+			heatmap = opt.BoolValue()
+		case statsOptionTimezone:
+			timezone = opt.StringValue()
+		case statsOptionExport:
+			if opt.BoolValue() {
+				b.processImagineStatsExport(s, i)
+
+				return
+			}
+		case statsOptionLeaderboard:
+			if opt.BoolValue() {
+				b.processImagineLeaderboard(s, i)
+
+				return
+			}
+		case statsOptionDaily:
+			if opt.BoolValue() {
+				b.processImagineDailyStats(s, i)
+
+				return
+			}
 		}
 	}
 
-	stats, err := b.statisticsRepo.GetStatByMember(context.Background(), member.ID)
+	if heatmap {
+		b.processImagineHeatmap(s, i, timezone)
+
+		return
+	}
+
+	period := interactionPeriod(i)
+
+	stats, err := b.statisticsRepo.GetStatByMember(context.Background(), member.ID, periodStart(b.clock.Now(), period))
 	if err != nil {
 		log.Print("Error getting stats: ", err)
 	} else if stats == nil {
 		message = "No statistics found."
 	} else {
-		message = fmt.Sprintf("<@%s> generated %d images. Total time: %s", stats.MemberID, stats.Count, (time.Duration(stats.TimeMs) * time.Millisecond).Round(time.Second).String())
+		message = fmt.Sprintf("<@%s> generated %d images%s. Total time: %s", stats.MemberID, stats.Count, periodLabel(period), (time.Duration(stats.TimeMs) * time.Millisecond).Round(time.Second).String())
+
+		if stats.BonusPoints > 0 {
+			message += fmt.Sprintf("\nPrompt challenge points: %d", stats.BonusPoints)
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Description: message,
+	}
+
+	peakHour, err := b.statisticsRepo.GetMostActiveHour(context.Background(), i.GuildID)
+	if err == nil {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Peak usage hour: %02d:00 UTC", peakHour),
+		}
+	} else if !errors.Is(err, &repositories.NotFoundError{}) {
+		log.Printf("Error getting the most active hour: %v", err)
 	}
 
 	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: message,
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineLeaderboard(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildName := i.GuildID
+
+	guild, err := s.Guild(i.GuildID)
+	if err != nil {
+		log.Printf("Error getting guild: %v", err)
+	} else {
+		guildName = guild.Name
+	}
+
+	period := interactionPeriod(i)
+
+	top, err := b.statisticsRepo.GetTopNByCount(context.Background(), i.GuildID, leaderboardSize, periodStart(b.clock.Now(), period))
+	if err != nil {
+		log.Printf("Error getting leaderboard: %v", err)
+
+		respondEphemeral(s, i, "Error getting the leaderboard...")
+
+		return
+	}
+
+	description := "No statistics found."
+	if len(top) > 0 {
+		lines := make([]string, 0, len(top))
+		for idx, stat := range top {
+			lines = append(lines, fmt.Sprintf("%d. %s: %d images, %s, %s per image", idx+1, leaderboardName(s, stat.MemberID),
+				stat.Count, (time.Duration(stat.TimeMs)*time.Millisecond).Round(time.Second).String(),
+				averageRenderTime(stat.TimeMs, stat.Count)))
+		}
+
+		description = strings.Join(lines, "\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Top generators in %s%s", guildName, periodLabel(period)),
+		Description: description,
+	}
+
+	global, err := b.statisticsRepo.GetGlobalStats(context.Background())
+	if err != nil {
+		log.Printf("Error getting global stats: %v", err)
+	} else if global.Count > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("All servers: %d images, %s per image", global.Count,
+				averageRenderTime(global.TimeMs, global.Count)),
+		}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineDailyStats(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	days, err := b.statisticsRepo.GetDailyStats(context.Background(), i.GuildID, dailyStatsDays)
+	if err != nil {
+		log.Printf("Error getting daily stats: %v", err)
+
+		respondEphemeral(s, i, "Error getting the daily statistics...")
+
+		return
+	}
+
+	table := fmt.Sprintf("%-10s %7s %10s\n", "Date", "Images", "Time")
+
+	for _, day := range days {
+		table += fmt.Sprintf("%-10s %7d %10s\n", day.Date, day.Count,
+			(time.Duration(day.TotalTimeMs) * time.Millisecond).Round(time.Second).String())
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       fmt.Sprintf("Activity of the last %d days", dailyStatsDays),
+					Description: "```\n" + table + "```",
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (b *botImpl) processImagineStatsExport(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can export statistics.")
+
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
 		},
 	})
 	if err != nil {
 		log.Printf("Error responding to interaction: %v", err)
+
+		return
+	}
+
+	message := "Here are the statistics:"
+	var files []*discordgo.File
+
+	buf := new(bytes.Buffer)
+
+	err = b.statisticsRepo.ExportCSV(context.Background(), buf)
+	if err != nil {
+		log.Printf("Error exporting statistics: %v", err)
+
+		message = "Error exporting statistics..."
+	} else {
+		files = append(files, &discordgo.File{
+			Name:        "statistics.csv",
+			ContentType: "text/csv",
+			Reader:      buf,
+		})
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &message,
+		Files:   files,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
 	}
 }
 
@@ -838,6 +3599,7 @@ func (b *botImpl) processImagineDimensionSetting(s *discordgo.Session, i *discor
 		Type: discordgo.InteractionResponseUpdateMessage,
 		Data: &discordgo.InteractionResponseData{
 			Content: "Choose defaults settings for the imagine command:",
+			Embeds:  []*discordgo.MessageEmbed{b.settingsEmbed(i.GuildID)},
 			Components: []discordgo.MessageComponent{
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
@@ -0,0 +1,71 @@
+package discord_bot
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxDisplayedPromptLength = 200
+	// Discord limit for the content of a message
+	maxMessageLength = 2000
+)
+
+// truncatePrompt shortens the prompt to max characters (runes), appending "..." when it was cut
+func truncatePrompt(prompt string, max int) string {
+	runes := []rune(prompt)
+	if len(runes) <= max {
+		return prompt
+	}
+
+	return string(runes[:max]) + "..."
+}
+
+// Prompts are shown in inline code spans, where markdown isn't rendered and a backtick is the only character
+// able to end the span early. It is replaced with the look-alike modifier letter grave accent
+var codeSpanReplacer = strings.NewReplacer("`", "ˋ")
+
+// sanitizePromptForDisplay makes the prompt safe to show inside single backticks
+func sanitizePromptForDisplay(prompt string) string {
+	return codeSpanReplacer.Replace(prompt)
+}
+
+// joinLines joins the lines into a message of at most maxLength characters. The lines that don't fit
+// are replaced with a "...and N more" line
+func joinLines(lines []string, maxLength int) string {
+	message := strings.Join(lines, "\n") + "\n"
+	if len(lines) == 0 {
+		message = ""
+	}
+
+	if utf8.RuneCountInString(message) <= maxLength {
+		return message
+	}
+
+	message = ""
+	length := 0
+
+	for idx, line := range lines {
+		lineLength := utf8.RuneCountInString(line) + 1
+
+		// Room for the summary of the remaining lines has to be left unless this is the last one
+		reserved := 0
+		if idx < len(lines)-1 {
+			reserved = utf8.RuneCountInString(moreLinesLine(len(lines) - idx - 1))
+		}
+
+		if length+lineLength+reserved > maxLength {
+			return message + moreLinesLine(len(lines)-idx)
+		}
+
+		message += line + "\n"
+		length += lineLength
+	}
+
+	return message
+}
+
+func moreLinesLine(count int) string {
+	return fmt.Sprintf("...and %d more\n", count)
+}
@@ -0,0 +1,103 @@
+package discord_bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncatePrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		max    int
+		want   string
+	}{
+		{name: "shorter", prompt: "a cat", max: 10, want: "a cat"},
+		{name: "exact", prompt: "a cat", max: 5, want: "a cat"},
+		{name: "longer", prompt: "a cat in a hat", max: 5, want: "a cat..."},
+		{name: "empty", prompt: "", max: 5, want: ""},
+		{name: "cyrillic counted by runes", prompt: "кот в шляпе", max: 3, want: "кот..."},
+		{name: "cyrillic within limit", prompt: "кот", max: 3, want: "кот"},
+		{name: "emoji not split", prompt: "🐱🎩🐶", max: 2, want: "🐱🎩..."},
+		{name: "cjk", prompt: "猫と帽子", max: 2, want: "猫と..."},
+		{name: "zero", prompt: "cat", max: 0, want: "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncatePrompt(tt.prompt, tt.max)
+			if got != tt.want {
+				t.Errorf("truncatePrompt(%q, %d) = %q, want %q", tt.prompt, tt.max, got, tt.want)
+			}
+
+			if !utf8.ValidString(got) {
+				t.Errorf("truncatePrompt(%q, %d) = %q is not valid UTF-8", tt.prompt, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestSanitizePromptForDisplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{name: "plain", prompt: "a cat in a hat", want: "a cat in a hat"},
+		{name: "markdown is kept", prompt: "**bold** _italic_ ~~strike~~ > quote | pipe", want: "**bold** _italic_ ~~strike~~ > quote | pipe"},
+		{name: "backslash is kept", prompt: `C:\images\cat`, want: `C:\images\cat`},
+		{name: "backtick", prompt: "a `cat`", want: "a ˋcatˋ"},
+		{name: "code block", prompt: "```cat```", want: "ˋˋˋcatˋˋˋ"},
+		{name: "multibyte", prompt: "кот `в` шляпе 🐱", want: "кот ˋвˋ шляпе 🐱"},
+		{name: "empty", prompt: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizePromptForDisplay(tt.prompt); got != tt.want {
+				t.Errorf("sanitizePromptForDisplay(%q) = %q, want %q", tt.prompt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		maxLength int
+		want      string
+	}{
+		{name: "empty", lines: nil, maxLength: 100, want: ""},
+		{name: "all fit", lines: []string{"one", "two"}, maxLength: 8, want: "one\ntwo\n"},
+		{name: "cut", lines: []string{"one", "two", "three", "four", "five"}, maxLength: 20, want: "one\n...and 4 more\n"},
+		{name: "nothing fits", lines: []string{"a long line"}, maxLength: 5, want: "...and 1 more\n"},
+		{name: "multibyte counted as characters", lines: []string{"кот", "🐱🐱"}, maxLength: 7, want: "кот\n🐱🐱\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinLines(tt.lines, tt.maxLength); got != tt.want {
+				t.Errorf("joinLines(%q, %d) = %q, want %q", tt.lines, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinLinesWithinLimit(t *testing.T) {
+	lines := make([]string, 500)
+	for idx := range lines {
+		lines[idx] = strings.Repeat("x", idx%50)
+	}
+
+	message := joinLines(lines, maxMessageLength)
+
+	if length := utf8.RuneCountInString(message); length > maxMessageLength {
+		t.Errorf("message length = %d, want at most %d", length, maxMessageLength)
+	}
+
+	if !strings.HasSuffix(message, " more\n") {
+		t.Errorf("message doesn't end with the number of omitted lines: %q", message[len(message)-20:])
+	}
+}
@@ -0,0 +1,141 @@
+package discord_bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"stable_diffusion_bot/repositories/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Width of the longest heatmap bar in characters
+const heatmapBarWidth = 20
+
+// Partial blocks by eighths of a character
+var heatmapPartialBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// guildTimezone returns the timezone saved for the guild, UTC when it isn't set
+func (b *botImpl) guildTimezone(guildID string) *time.Location {
+	guildSettings, err := b.imagineQueue.GetGuildSettings(guildID)
+	if err != nil {
+		log.Printf("Error getting guild settings: %v", err)
+
+		return time.UTC
+	}
+
+	for _, setting := range guildSettings {
+		if setting.Key != settings.KeyTimezone {
+			continue
+		}
+
+		location, loadErr := time.LoadLocation(setting.Value)
+		if loadErr != nil {
+			log.Printf("Error loading guild timezone %s: %v", setting.Value, loadErr)
+
+			return time.UTC
+		}
+
+		return location
+	}
+
+	return time.UTC
+}
+
+// processImagineHeatmap shows the generations by hour of day in the guild's timezone, saving the timezone when given
+func (b *botImpl) processImagineHeatmap(s *discordgo.Session, i *discordgo.InteractionCreate, timezone string) {
+	if !hasPermission(i, discordgo.PermissionAdministrator) {
+		respondEphemeral(s, i, "Only admins can see the heatmap.")
+
+		return
+	}
+
+	location := b.guildTimezone(i.GuildID)
+
+	if timezone != "" {
+		var err error
+
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("Unknown timezone `%s`, expected a name like `Europe/Moscow`.", timezone))
+
+			return
+		}
+
+		err = b.imagineQueue.UpdateGuildSetting(i.GuildID, settings.KeyTimezone, location.String())
+		if err != nil {
+			log.Printf("Error saving guild timezone: %v", err)
+		}
+	}
+
+	distribution, err := b.statisticsRepo.GetHourlyDistribution(context.Background(), i.GuildID)
+	if err != nil {
+		log.Printf("Error getting hourly distribution: %v", err)
+
+		respondEphemeral(s, i, "Error getting the heatmap...")
+
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       fmt.Sprintf("Generations by hour (%s)", location),
+					Description: "```\n" + renderHeatmap(localHours(distribution, location)) + "```",
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// localHours shifts the UTC hour counts to the location by its current offset,
+// so hours around a DST change are off by one
+func localHours(utc [24]int64, location *time.Location) [24]int64 {
+	_, offsetSeconds := time.Now().In(location).Zone()
+	offsetHours := offsetSeconds / 3600
+
+	var local [24]int64
+
+	for hour, count := range utc {
+		local[((hour+offsetHours)%24+24)%24] += count
+	}
+
+	return local
+}
+
+// renderHeatmap draws a horizontal bar per hour, scaled to the busiest hour
+func renderHeatmap(hours [24]int64) string {
+	var busiest int64
+
+	for _, count := range hours {
+		if count > busiest {
+			busiest = count
+		}
+	}
+
+	var sb strings.Builder
+
+	for hour, count := range hours {
+		bar := ""
+		barLength := 0
+
+		if busiest > 0 {
+			eighths := int(count * heatmapBarWidth * 8 / busiest)
+			bar = strings.Repeat("█", eighths/8) + heatmapPartialBlocks[eighths%8]
+			barLength = (eighths + 7) / 8
+		}
+
+		// Padded by characters, fmt pads the multibyte blocks by bytes
+		sb.WriteString(fmt.Sprintf("%02d %s%s %d\n", hour, bar, strings.Repeat(" ", heatmapBarWidth-barLength), count))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package discord_bot
+
+import (
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	img2imgOptionPrompt            = `prompt`
+	img2imgOptionImage             = `image`
+	img2imgOptionDenoisingStrength = `denoising_strength`
+	img2imgOptionSkipResize        = `skip_resize`
+)
+
+func (b *botImpl) addImagineImg2ImgCommand() error {
+	command := b.imagineImg2ImgCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	minDenoisingStrength := 0.0
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Ask the bot to reimagine an image",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        img2imgOptionPrompt,
+				Description: "The text prompt to imagine (`--ar x:y` to set aspect ratio)",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Name:        img2imgOptionImage,
+				Description: "The source image",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        img2imgOptionDenoisingStrength,
+				Description: fmt.Sprintf("How much the image changes, from 0 to 1 (%v)", imagine_queue.DefaultDenoisingStrength),
+				Required:    false,
+				MinValue:    &minDenoisingStrength,
+				MaxValue:    1,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        img2imgOptionSkipResize,
+				Description: "Send the image as is instead of resizing it to the generation size first",
+				Required:    false,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineImg2ImgCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "DM usage is not allowed.")
+
+		return
+	}
+
+	queueOptions := b.imagineQueue.NewMemberQueueItemOptions(i.GuildID, interactionUser(i).ID)
+
+	var attachment *discordgo.MessageAttachment
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case img2imgOptionPrompt:
+			queueOptions.Prompt = opt.StringValue()
+		case img2imgOptionImage:
+			var err error
+
+			attachment, err = attachmentOption(i, opt)
+			if err != nil {
+				log.Printf("Error getting attachment: %v", err)
+			}
+		case img2imgOptionDenoisingStrength:
+			queueOptions.DenoisingStrength = opt.FloatValue()
+		case img2imgOptionSkipResize:
+			queueOptions.SkipResize = opt.BoolValue()
+		}
+	}
+
+	if attachment == nil {
+		respondEphemeral(s, i, "Please attach an image.")
+
+		return
+	}
+
+	if !b.checkPromptLength(s, i, queueOptions.Prompt, queueOptions.NegativePrompt) {
+		return
+	}
+
+	item := &imagine_queue.QueueItem{
+		Prompt:             queueOptions.Prompt,
+		Options:            queueOptions,
+		Type:               imagine_queue.ItemTypeImg2Img,
+		InitImageURL:       attachment.URL,
+		DiscordInteraction: i.Interaction,
+	}
+
+	position, err := b.imagineQueue.AddImagine(item)
+	if b.respondRateLimited(s, i, err) {
+		return
+	} else if err != nil {
+		log.Printf("Error adding img2img to queue: %v\n", err)
+
+		respondEphemeral(s, i, "I'm sorry, but I couldn't queue your image.")
+
+		return
+	}
+
+	b.logQueuedImagine(i, item, position)
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("I'm reimagining your image. You are currently #%d in line.\n<@%s> asked me to imagine `%s`.",
+				position, interactionUser(i).ID,
+				sanitizePromptForDisplay(truncatePrompt(queueOptions.Prompt, maxDisplayedPromptLength))),
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package discord_bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	infoOptionMessageID = `message_id`
+)
+
+// Discord limits embed descriptions to 4096 characters
+const maxEmbedDescriptionLength = 4096
+
+func (b *botImpl) addImagineInfoCommand() error {
+	command := b.imagineInfoCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Show the re-rolls and variations a generation is part of",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        infoOptionMessageID,
+				Description: "ID of the message with the generation",
+				Required:    true,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineInfoCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	messageID := ""
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == infoOptionMessageID {
+			messageID = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	lineage, err := b.generationRepo.GetGenerationLineage(context.Background(), messageID)
+	if errors.Is(err, &repositories.NotFoundError{}) {
+		respondEphemeral(s, i, "No generation found for that message.")
+
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error getting the generation lineage: %v", err)
+
+		respondEphemeral(s, i, "Error getting the generation lineage...")
+
+		return
+	}
+
+	description := lineageTree(lineage, messageID)
+	if len(description) > maxEmbedDescriptionLength {
+		description = description[:maxEmbedDescriptionLength-3] + "..."
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "Generation lineage",
+					Description: description,
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// lineageTree renders the generations as a tree, each indented below the generation it was created from.
+// The generation of highlightedMessageID is shown in bold
+func lineageTree(lineage []*entities.ImageGeneration, highlightedMessageID string) string {
+	known := make(map[string]bool, len(lineage))
+	for _, generation := range lineage {
+		known[generation.MessageID] = true
+	}
+
+	children := make(map[string][]*entities.ImageGeneration)
+	roots := make([]*entities.ImageGeneration, 0, 1)
+
+	for _, generation := range lineage {
+		// Sources missing from the lineage happen for generations created before it was recorded
+		if generation.SourceMessageID == "" || !known[generation.SourceMessageID] {
+			roots = append(roots, generation)
+		} else {
+			children[generation.SourceMessageID] = append(children[generation.SourceMessageID], generation)
+		}
+	}
+
+	var builder strings.Builder
+
+	var render func(generation *entities.ImageGeneration, depth int)
+	render = func(generation *entities.ImageGeneration, depth int) {
+		line := fmt.Sprintf("`%s` <@%s>: `%s`", generation.MessageID, generation.MemberID,
+			sanitizePromptForDisplay(truncatePrompt(generation.Prompt, loggedPromptLength)))
+		if generation.MessageID == highlightedMessageID {
+			line = "**" + line + "**"
+		}
+
+		// Discord strips leading regular spaces, so the indentation uses em spaces
+		if depth > 0 {
+			line = strings.Repeat(" ", depth-1) + "└ " + line
+		}
+
+		builder.WriteString(line + "\n")
+
+		for _, child := range children[generation.MessageID] {
+			render(child, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		render(root, 0)
+	}
+
+	return builder.String()
+}
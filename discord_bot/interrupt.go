@@ -0,0 +1,54 @@
+package discord_bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *botImpl) addImagineInterruptCommand() error {
+	command := b.imagineInterruptCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Stop the current generation, e.g. when it's stuck (Manage Server permission)",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineInterruptCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		respondEphemeral(s, i, "You need the Manage Server permission to interrupt generations.")
+
+		return
+	}
+
+	interrupted, err := b.imagineQueue.Interrupt(context.Background())
+
+	switch {
+	case errors.Is(err, imagine_queue.ErrNoActiveItem):
+		respondEphemeral(s, i, "Nothing is being generated right now.")
+	case err != nil:
+		log.Printf("Error interrupting generation: %v", err)
+
+		respondEphemeral(s, i, "Error interrupting the generation...")
+	case interrupted == 1:
+		respondEphemeral(s, i, "The current generation was interrupted.")
+	default:
+		respondEphemeral(s, i, fmt.Sprintf("%d generations were interrupted.", interrupted))
+	}
+}
@@ -0,0 +1,172 @@
+package discord_bot
+
+import (
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultLatencyAlertThresholdMs is the P99 response latency above which a warning is logged
+	DefaultLatencyAlertThresholdMs = 2000
+
+	latencyWindow = time.Hour
+	// P99 of a handful of samples is just their maximum, so alerts wait for enough samples
+	minLatencySamples = 20
+	// Bucket i counts latencies from 2^(i-1) up to 2^i ms, bucket 0 counts 0 ms and the last bucket takes everything above
+	latencyBuckets = 20
+)
+
+// latencyTracker keeps an exponential histogram of interaction response latencies, reset every hour
+type latencyTracker struct {
+	mu          sync.Mutex
+	buckets     [latencyBuckets]int
+	count       int
+	windowStart time.Time
+	alerted     bool
+	thresholdMs int
+	// Channel for the alerts in addition to the log, optional
+	errorChannelID string
+}
+
+func newLatencyTracker(thresholdMs int, errorChannelID string) *latencyTracker {
+	if thresholdMs <= 0 {
+		thresholdMs = DefaultLatencyAlertThresholdMs
+	}
+
+	return &latencyTracker{
+		windowStart:    time.Now(),
+		thresholdMs:    thresholdMs,
+		errorChannelID: errorChannelID,
+	}
+}
+
+// track records the time from the interaction creation to now, it is called once the interaction is responded.
+// Handlers may keep working after responding, e.g. deferred responses waiting for the API, so the latency is
+// taken when the response is sent, not when the handler returns
+func (t *latencyTracker) track(s *discordgo.Session, interactionID string) {
+	createdAt, err := discordgo.SnowflakeTimestamp(interactionID)
+	if err != nil {
+		return
+	}
+
+	p99, alert := t.record(time.Since(createdAt))
+	if !alert {
+		return
+	}
+
+	message := fmt.Sprintf("Warning: P99 interaction response latency is at least %dms, above the %dms threshold", p99, t.thresholdMs)
+
+	log.Print(message)
+
+	if t.errorChannelID != "" {
+		// Sent in the background, track is called before the interaction response returns
+		go func() {
+			_, err := s.ChannelMessageSend(t.errorChannelID, message)
+			if err != nil {
+				log.Printf("Error sending latency alert: %v", err)
+			}
+		}()
+	}
+}
+
+// record adds the latency to the histogram, returning the P99 in ms and whether it needs an alert.
+// Alerts are raised at most once per window
+func (t *latencyTracker) record(latency time.Duration) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.windowStart) > latencyWindow {
+		t.buckets = [latencyBuckets]int{}
+		t.count = 0
+		t.alerted = false
+		t.windowStart = time.Now()
+	}
+
+	ms := latency.Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+
+	bucket := bits.Len64(uint64(ms))
+	if bucket >= latencyBuckets {
+		bucket = latencyBuckets - 1
+	}
+
+	t.buckets[bucket]++
+	t.count++
+
+	p99 := t.percentile(0.99)
+
+	if t.alerted || t.count < minLatencySamples || p99 <= t.thresholdMs {
+		return p99, false
+	}
+
+	t.alerted = true
+
+	return p99, true
+}
+
+// percentile returns the lower bound in ms of the bucket containing the percentile, so that alerts are
+// only raised when the percentile is certainly above the threshold
+func (t *latencyTracker) percentile(p float64) int {
+	target := int(float64(t.count)*p + 0.5)
+	seen := 0
+
+	for bucket, count := range t.buckets {
+		seen += count
+		if seen >= target {
+			return bucketLowerBound(bucket)
+		}
+	}
+
+	return bucketLowerBound(latencyBuckets - 1)
+}
+
+func bucketLowerBound(bucket int) int {
+	if bucket == 0 {
+		return 0
+	}
+
+	return 1 << (bucket - 1)
+}
+
+// latencyTransport tracks the latency of every interaction response sent through the session
+type latencyTransport struct {
+	inner   http.RoundTripper
+	tracker *latencyTracker
+	session *discordgo.Session
+}
+
+func (t *latencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, err
+	}
+
+	if interactionID, ok := interactionResponseID(req.URL.Path); ok {
+		t.tracker.track(t.session, interactionID)
+	}
+
+	return resp, nil
+}
+
+// interactionResponseID returns the interaction ID of an interaction response path,
+// see discordgo.EndpointInteractionResponse
+func interactionResponseID(path string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	// interactions/<id>/<token>/callback
+	n := len(segments)
+	if n < 4 || segments[n-4] != "interactions" || segments[n-1] != "callback" {
+		return "", false
+	}
+
+	return segments[n-3], true
+}
@@ -0,0 +1,120 @@
+package discord_bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTrackerPercentile(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		wantP99   int
+		wantAlert bool
+	}{
+		{
+			name:      "fast responses",
+			latencies: repeatLatency(100*time.Millisecond, minLatencySamples),
+			wantP99:   64,
+		},
+		{
+			// The bucket of 1024 to 2047 ms must not be reported as 2048 ms
+			name:      "just above one second",
+			latencies: repeatLatency(1100*time.Millisecond, minLatencySamples),
+			wantP99:   1024,
+		},
+		{
+			name:      "above the threshold",
+			latencies: repeatLatency(5*time.Second, minLatencySamples),
+			wantP99:   4096,
+			wantAlert: true,
+		},
+		{
+			name:      "too few samples",
+			latencies: repeatLatency(5*time.Second, minLatencySamples-1),
+			wantP99:   4096,
+		},
+		{
+			name:      "zero latency",
+			latencies: repeatLatency(0, minLatencySamples),
+			wantP99:   0,
+		},
+		{
+			name:      "beyond the last bucket",
+			latencies: repeatLatency(time.Hour, minLatencySamples),
+			wantP99:   1 << (latencyBuckets - 2),
+			wantAlert: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newLatencyTracker(DefaultLatencyAlertThresholdMs, "")
+
+			var p99 int
+
+			alerts := 0
+
+			for _, latency := range tt.latencies {
+				var alert bool
+
+				p99, alert = tracker.record(latency)
+				if alert {
+					alerts++
+				}
+			}
+
+			if p99 != tt.wantP99 {
+				t.Errorf("P99 = %dms, want %dms", p99, tt.wantP99)
+			}
+
+			// Alerts are raised once per window
+			if alerts > 1 || (alerts == 1) != tt.wantAlert {
+				t.Errorf("alerts = %d, want alert %v", alerts, tt.wantAlert)
+			}
+		})
+	}
+}
+
+func TestLatencyTrackerPercentileOutlier(t *testing.T) {
+	tracker := newLatencyTracker(DefaultLatencyAlertThresholdMs, "")
+
+	// One slow response out of a hundred stays under the P99
+	for i := 0; i < 99; i++ {
+		tracker.record(10 * time.Millisecond)
+	}
+
+	p99, alert := tracker.record(10 * time.Second)
+	if p99 != 8 || alert {
+		t.Errorf("record() = %d, %v, want 8, false", p99, alert)
+	}
+}
+
+func TestInteractionResponseID(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "/api/v9/interactions/123/token/callback", want: "123", wantOK: true},
+		{path: "/api/v9/webhooks/app/token/messages/@original"},
+		{path: "/api/v9/interactions/123/token"},
+		{path: "/callback"},
+	}
+
+	for _, tt := range tests {
+		got, ok := interactionResponseID(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("interactionResponseID(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func repeatLatency(latency time.Duration, n int) []time.Duration {
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		latencies[i] = latency
+	}
+
+	return latencies
+}
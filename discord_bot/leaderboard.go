@@ -0,0 +1,51 @@
+package discord_bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *botImpl) addImagineLeaderboardCommand() error {
+	command := b.imagineLeaderboardCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Show the top generators of this server",
+		Options: []*discordgo.ApplicationCommandOption{
+			periodOption(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+// leaderboardName returns the username of the member, or a mention when the user can't be fetched
+func leaderboardName(s *discordgo.Session, memberID string) string {
+	user, err := s.User(memberID)
+	if err != nil {
+		log.Printf("Error getting user %s: %v", memberID, err)
+
+		return "<@" + memberID + ">"
+	}
+
+	return user.Username
+}
+
+// averageRenderTime returns the render time per image rounded to tenths of a second
+func averageRenderTime(timeMs, count int64) string {
+	if count == 0 {
+		return "0s"
+	}
+
+	return (time.Duration(timeMs/count) * time.Millisecond).Round(100 * time.Millisecond).String()
+}
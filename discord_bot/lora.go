@@ -0,0 +1,64 @@
+package discord_bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// loraOptions returns the LoRA and weight options of imagine_ext, or nil when the server has no LoRA networks
+func (b *botImpl) loraOptions() []*discordgo.ApplicationCommandOption {
+	models, err := b.stableDiffusionAPI.GetLoRAModels(context.Background())
+	if err != nil {
+		log.Printf("Warning: LoRA networks are not available, the LoRA options are left out: %v", err)
+
+		return nil
+	}
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, model := range models {
+		name := model.Name
+		if model.Alias != "" && model.Alias != model.Name {
+			name = fmt.Sprintf("%s (%s)", model.Alias, model.Name)
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncatePrompt(name, maxChoiceNameLength-len("...")),
+			Value: model.Name,
+		})
+
+		// Max 25 choices
+		if len(choices) == 25 {
+			log.Printf("Loaded 25/%d LoRA networks...", len(models))
+			break
+		}
+	}
+
+	minWeight := 0.0
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        extOptionLoRA,
+			Description: "LoRA network added to the prompt",
+			Required:    false,
+			Choices:     choices,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionNumber,
+			Name:        extOptionLoRAWeight,
+			Description: fmt.Sprintf("Weight of the LoRA network (%v)", imagine_queue.DefaultLoRAWeight),
+			Required:    false,
+			MinValue:    &minWeight,
+			MaxValue:    2,
+		},
+	}
+}
@@ -0,0 +1,140 @@
+package discord_bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const imagineModalID = "imagine_modal"
+
+// Custom IDs of the imagine modal fields
+const (
+	modalFieldPrompt         = `prompt`
+	modalFieldNegativePrompt = `negative_prompt`
+	modalFieldSteps          = `steps`
+	modalFieldCFGScale       = `cfg_scale`
+	modalFieldSeed           = `seed`
+)
+
+// imagineModal is the form with the main imagine_ext parameters, handled by processImagineModalSubmit
+func imagineModal() *discordgo.InteractionResponseData {
+	textInput := func(customID, label string, style discordgo.TextInputStyle, required bool) discordgo.MessageComponent {
+		return discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID: customID,
+					Label:    label,
+					Style:    style,
+					Required: required,
+				},
+			},
+		}
+	}
+
+	return &discordgo.InteractionResponseData{
+		CustomID: imagineModalID,
+		Title:    "Imagine",
+		Components: []discordgo.MessageComponent{
+			textInput(modalFieldPrompt, "Prompt", discordgo.TextInputParagraph, true),
+			textInput(modalFieldNegativePrompt, "Negative prompt", discordgo.TextInputParagraph, false),
+			textInput(modalFieldSteps, "Sampling steps (1-50)", discordgo.TextInputShort, false),
+			textInput(modalFieldCFGScale, "CFG scale (1-30)", discordgo.TextInputShort, false),
+			textInput(modalFieldSeed, "Seed (-1 for random)", discordgo.TextInputShort, false),
+		},
+	}
+}
+
+// respondImagineModal opens the imagine modal in response to the interaction
+func (b *botImpl) respondImagineModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: imagineModal(),
+	})
+	if err != nil {
+		log.Printf("Error responding with the imagine modal: %v", err)
+	}
+}
+
+// modalValues collects the text input values of the submitted modal by custom ID
+func modalValues(data discordgo.ModalSubmitInteractionData) map[string]string {
+	values := make(map[string]string)
+
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		for _, rowComponent := range row.Components {
+			if input, isInput := rowComponent.(*discordgo.TextInput); isInput {
+				values[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+
+	return values
+}
+
+func (b *botImpl) processImagineModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.checkAllowedRole(s, i) {
+		return
+	}
+
+	isDM := i.GuildID == ""
+
+	queueOptions := b.imagineQueue.NewMemberQueueItemOptions(i.GuildID, interactionUser(i).ID)
+
+	for customID, value := range modalValues(i.ModalSubmitData()) {
+		if value == "" {
+			continue
+		}
+
+		switch customID {
+		case modalFieldPrompt:
+			queueOptions.Prompt = value
+		case modalFieldNegativePrompt:
+			queueOptions.NegativePrompt = value
+		case modalFieldSteps:
+			steps, err := strconv.Atoi(value)
+			if err != nil || steps < 1 || steps > 50 {
+				respondEphemeral(s, i, fmt.Sprintf("Sampling steps must be a number from 1 to 50, got `%s`.", value))
+
+				return
+			}
+
+			queueOptions.Steps = steps
+		case modalFieldCFGScale:
+			cfgScale, err := strconv.ParseFloat(value, 64)
+			if err != nil || cfgScale < 1 || cfgScale > 30 {
+				respondEphemeral(s, i, fmt.Sprintf("CFG scale must be a number from 1 to 30, got `%s`.", value))
+
+				return
+			}
+
+			queueOptions.CfgScale = cfgScale
+		case modalFieldSeed:
+			seed, err := strconv.Atoi(value)
+			if err != nil {
+				respondEphemeral(s, i, fmt.Sprintf("Seed must be a number, got `%s`.", value))
+
+				return
+			}
+
+			queueOptions.Seed = seed
+		default:
+			log.Printf("Unknown imagine modal field '%s'", customID)
+		}
+	}
+
+	if queueOptions.Prompt == "" {
+		respondEphemeral(s, i, "The prompt is required.")
+
+		return
+	}
+
+	b.queueImagineOptions(s, i, queueOptions, isDM)
+}
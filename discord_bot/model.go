@@ -0,0 +1,172 @@
+package discord_bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	modelOptionModel = `model`
+)
+
+func (b *botImpl) addImagineModelCommand() error {
+	command := b.imagineModelCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "List the available models or switch the loaded model (Manage Server permission)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        modelOptionModel,
+				Description: "Title or name of the model to load, the models are listed when empty",
+				Required:    false,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineModelCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		respondEphemeral(s, i, "You need the Manage Server permission to change the model.")
+
+		return
+	}
+
+	model := ""
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == modelOptionModel {
+			model = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	// Loading a model takes longer than the 3 seconds Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+
+		return
+	}
+
+	if model == "" {
+		b.listModels(s, i)
+
+		return
+	}
+
+	log.Printf("Loading model '%s' requested by %s", model, interactionUser(i).Username)
+
+	err = b.stableDiffusionAPI.SetModel(context.Background(), model)
+	if err != nil {
+		log.Printf("Error setting the model: %v", err)
+
+		editInteractionContent(s, i, fmt.Sprintf("Error loading `%s`. Is it listed by `/%s`?", model, b.imagineModelCommandString()))
+
+		return
+	}
+
+	// The embeddings compatible with the new model differ
+	err = b.reloadImagineExtEmbeddings()
+	if err != nil {
+		log.Printf("Error reloading the embeddings: %v", err)
+
+		editInteractionContent(s, i, fmt.Sprintf("Loaded `%s`, but the embeddings could not be reloaded.", model))
+
+		return
+	}
+
+	editInteractionContent(s, i, fmt.Sprintf("Loaded `%s`.", model))
+}
+
+// listModels edits the deferred response to an embed with the available models, marking the loaded one
+func (b *botImpl) listModels(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	models, err := b.stableDiffusionAPI.GetModels(context.Background())
+	if err != nil {
+		log.Printf("Error getting the models: %v", err)
+
+		editInteractionContent(s, i, "Error getting the models...")
+
+		return
+	}
+
+	current, err := b.stableDiffusionAPI.GetCurrentModel(context.Background())
+	if err != nil {
+		log.Printf("Error getting the current model: %v", err)
+	}
+
+	var builder strings.Builder
+
+	for _, model := range models {
+		line := fmt.Sprintf("`%s`\n", model.Title)
+		if model.Title == current {
+			line = fmt.Sprintf("**`%s`** (loaded)\n", model.Title)
+		}
+
+		if builder.Len()+len(line) > maxEmbedDescriptionLength {
+			break
+		}
+
+		builder.WriteString(line)
+	}
+
+	if builder.Len() == 0 {
+		builder.WriteString("No models found.")
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{
+			{
+				Title:       fmt.Sprintf("Available models (%d)", len(models)),
+				Description: builder.String(),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
+
+// reloadImagineExtEmbeddings replaces the embedding choices of the imagine_ext command with the ones of the loaded model
+func (b *botImpl) reloadImagineExtEmbeddings() error {
+	if b.imagineExtCmd == nil {
+		return nil
+	}
+
+	embs, err := b.stableDiffusionAPI.GetEmbeddingsFull(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = b.botSession.ApplicationCommandEdit(b.botSession.State.User.ID, b.guildID, b.imagineExtCmd.ID, &discordgo.ApplicationCommand{
+		Name:        b.imagineExtCmd.Name,
+		Description: b.imagineExtCmd.Description,
+		Options:     withEmbeddingsOption(b.imagineExtOptions, embeddingsOption(embs)),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Reloaded the embeddings of the '%s' command", b.imagineExtCmd.Name)
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package discord_bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"stable_diffusion_bot/civitai"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *botImpl) addImagineModelInfoCommand() error {
+	command := b.imagineModelInfoCommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "Show the CivitAI page of the loaded model",
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+// The webui shows checkpoints as "v1-5-pruned-emaonly.safetensors [6ce0161689]"
+var checkpointHashRegex = regexp.MustCompile(`\[([0-9a-fA-F]+)]$`)
+
+// checkpointHash returns the short hash of the checkpoint title, or an empty string when the title has none
+func checkpointHash(title string) string {
+	match := checkpointHashRegex.FindStringSubmatch(title)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+func (b *botImpl) processImagineModelInfoCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// The model is looked up on CivitAI, which may take longer than the 3 seconds Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+
+		return
+	}
+
+	model, err := b.stableDiffusionAPI.GetCurrentModel(context.Background())
+	if err != nil {
+		log.Printf("Error getting the current model: %v", err)
+
+		editInteractionContent(s, i, "Error getting the loaded model...")
+
+		return
+	}
+
+	hash := checkpointHash(model)
+	if hash == "" {
+		editInteractionContent(s, i, fmt.Sprintf("The hash of the loaded model `%s` is unknown.", model))
+
+		return
+	}
+
+	info, err := b.civitAI.GetModelByHash(hash)
+	if errors.Is(err, civitai.ErrNotFound) {
+		editInteractionContent(s, i, fmt.Sprintf("The loaded model `%s` is not on CivitAI.", model))
+
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error getting the model from CivitAI: %v", err)
+
+		editInteractionContent(s, i, "Error getting the model from CivitAI...")
+
+		return
+	}
+
+	var fields []*discordgo.MessageEmbedField
+
+	// Embed fields can't be empty
+	for _, field := range [][2]string{{"Version", info.VersionName}, {"Base model", info.BaseModel}, {"Author", info.Author}} {
+		if field[1] != "" {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   field[0],
+				Value:  field[1],
+				Inline: true,
+			})
+		}
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{
+			{
+				Title:  info.Name,
+				URL:    info.URL,
+				Fields: fields,
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: model,
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package discord_bot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+)
+
+// embeddingsAPI returns the same embeddings on every request, the other methods are not implemented
+type embeddingsAPI struct {
+	stable_diffusion_api.StableDiffusionAPI
+	embs *stable_diffusion_api.EmbeddingsResponse
+}
+
+func (api *embeddingsAPI) GetEmbeddingsFull(ctx context.Context) (*stable_diffusion_api.EmbeddingsResponse, error) {
+	return api.embs, nil
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReloadImagineExtEmbeddings(t *testing.T) {
+	var edits []*discordgo.ApplicationCommand
+
+	// The Discord API answers every command edit with an empty command
+	session, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.State.User = &discordgo.User{ID: "application"}
+	session.Client = &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var edit discordgo.ApplicationCommand
+
+		err := json.NewDecoder(req.Body).Decode(&edit)
+		if err != nil {
+			t.Errorf("decoding the command edit: %v", err)
+		}
+
+		edits = append(edits, &edit)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    req,
+		}, nil
+	})}
+
+	bot := &botImpl{
+		botSession: session,
+		guildID:    "guild",
+		stableDiffusionAPI: &embeddingsAPI{embs: &stable_diffusion_api.EmbeddingsResponse{
+			Loaded: map[string]stable_diffusion_api.Embedding{"easynegative": {}},
+		}},
+		imagineExtCmd: &discordgo.ApplicationCommand{ID: "command", Name: "imagine_ext"},
+		imagineExtOptions: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "prompt"},
+		},
+	}
+
+	// Every model switch reloads the embeddings
+	for reload := 0; reload < 2; reload++ {
+		err = bot.reloadImagineExtEmbeddings()
+		if err != nil {
+			t.Fatalf("reloadImagineExtEmbeddings() error = %v", err)
+		}
+	}
+
+	if len(edits) != 2 {
+		t.Fatalf("command edits = %d, want 2", len(edits))
+	}
+
+	for idx, edit := range edits {
+		var names []string
+		for _, option := range edit.Options {
+			names = append(names, option.Name)
+		}
+
+		if got, want := strings.Join(names, ","), "prompt,"+extOptionEmbeddings; got != want {
+			t.Errorf("edit %d options = %s, want %s", idx+1, got, want)
+		}
+	}
+}
+
+func TestWithEmbeddingsOption(t *testing.T) {
+	prompt := &discordgo.ApplicationCommandOption{Name: "prompt"}
+	oldEmbeddings := &discordgo.ApplicationCommandOption{Name: extOptionEmbeddings}
+	newEmbeddings := &discordgo.ApplicationCommandOption{Name: extOptionEmbeddings}
+
+	options := []*discordgo.ApplicationCommandOption{prompt, oldEmbeddings}
+
+	got := withEmbeddingsOption(options, newEmbeddings)
+	if len(got) != 2 || got[0] != prompt || got[1] != newEmbeddings {
+		t.Errorf("withEmbeddingsOption() = %v, want the prompt and the new embeddings", got)
+	}
+
+	got = withEmbeddingsOption(options, nil)
+	if len(got) != 1 || got[0] != prompt {
+		t.Errorf("withEmbeddingsOption() without embeddings = %v, want the prompt only", got)
+	}
+
+	if options[1] != oldEmbeddings {
+		t.Error("withEmbeddingsOption() modified the given options")
+	}
+}
@@ -0,0 +1,77 @@
+package discord_bot
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	statsOptionPeriod = `period`
+
+	periodAll   = "all"
+	periodToday = "today"
+	periodWeek  = "week"
+	periodMonth = "month"
+)
+
+// periodOption lets the statistics commands count only the recent generations
+func periodOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        statsOptionPeriod,
+		Description: "Count only the images of this period (all)",
+		Required:    false,
+		Choices: []*discordgo.ApplicationCommandOptionChoice{
+			{Name: "All time", Value: periodAll},
+			{Name: "Today", Value: periodToday},
+			{Name: "This week", Value: periodWeek},
+			{Name: "This month", Value: periodMonth},
+		},
+	}
+}
+
+// periodStart returns the start of the period containing now in its location, weeks start on Monday.
+// The zero time stands for all time
+func periodStart(now time.Time, period string) time.Time {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	switch period {
+	case periodToday:
+		return today
+	case periodWeek:
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+
+		return today.AddDate(0, 0, -daysSinceMonday)
+	case periodMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	}
+
+	return time.Time{}
+}
+
+// periodLabel describes the period for appending to a sentence, empty for all time
+func periodLabel(period string) string {
+	switch period {
+	case periodToday:
+		return " today"
+	case periodWeek:
+		return " this week"
+	case periodMonth:
+		return " this month"
+	}
+
+	return ""
+}
+
+// interactionPeriod returns the period option of the command, periodAll when not given
+func interactionPeriod(i *discordgo.InteractionCreate) string {
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == statsOptionPeriod {
+			return opt.StringValue()
+		}
+	}
+
+	return periodAll
+}
@@ -0,0 +1,27 @@
+package discord_bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const presenceUpdateInterval = 30 * time.Second
+
+// updatePresence shows the bot version and the queue depth as the "Playing" activity until done is closed
+func (b *botImpl) updatePresence(done <-chan struct{}) {
+	for {
+		status := fmt.Sprintf("Generating images | %s | Queue: %d", b.version, len(b.imagineQueue.GetWaitingItems()))
+
+		err := b.botSession.UpdateGameStatus(0, status)
+		if err != nil {
+			log.Printf("Error updating activity status: %v", err)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-b.clock.After(presenceUpdateInterval):
+		}
+	}
+}
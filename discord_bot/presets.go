@@ -0,0 +1,104 @@
+package discord_bot
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"stable_diffusion_bot/imagine_queue"
+	"stable_diffusion_bot/repositories/settings"
+)
+
+// Preset is a known-good parameter set for the imagine_ext command
+type Preset struct {
+	Name           string  `json:"name"`
+	Sampler        string  `json:"sampler"`
+	CFGScale       float64 `json:"cfg_scale"`
+	Steps          int     `json:"steps"`
+	NegativePrompt string  `json:"negative_prompt"`
+}
+
+// Discord allows up to 25 choices per option
+const maxPresetChoices = 25
+
+//go:embed presets.json
+var builtinPresetsJSON []byte
+
+var builtinPresets []*Preset
+
+func init() {
+	err := json.Unmarshal(builtinPresetsJSON, &builtinPresets)
+	if err != nil {
+		log.Fatalf("Error parsing built-in presets: %v", err)
+	}
+}
+
+// presets returns the built-in presets followed by the custom presets of the guild
+func (b *botImpl) presets(guildID string) []*Preset {
+	presets := append([]*Preset{}, builtinPresets...)
+
+	guildSettings, err := b.imagineQueue.GetGuildSettings(guildID)
+	if err != nil {
+		log.Printf("Error getting custom presets: %v", err)
+
+		return presets
+	}
+
+	for _, setting := range guildSettings {
+		if !strings.HasPrefix(setting.Key, settings.KeyPresetPrefix) {
+			continue
+		}
+
+		preset := &Preset{}
+
+		err = json.Unmarshal([]byte(setting.Value), preset)
+		if err != nil {
+			log.Printf("Error parsing custom preset %s: %v", setting.Key, err)
+
+			continue
+		}
+
+		presets = append(presets, preset)
+	}
+
+	return presets
+}
+
+func (b *botImpl) findPreset(guildID, name string) *Preset {
+	for _, preset := range b.presets(guildID) {
+		if preset.Name == name {
+			return preset
+		}
+	}
+
+	return nil
+}
+
+func (b *botImpl) saveCustomPreset(guildID string, preset *Preset) error {
+	value, err := json.Marshal(preset)
+	if err != nil {
+		return err
+	}
+
+	return b.imagineQueue.UpdateGuildSetting(guildID, settings.KeyPresetPrefix+preset.Name, string(value))
+}
+
+// applyPreset replaces the option defaults with the preset values
+func applyPreset(options *imagine_queue.QueueItemOptions, preset *Preset) {
+	if preset.Sampler != "" {
+		options.SamplerName = preset.Sampler
+	}
+
+	if preset.CFGScale > 0 {
+		options.CfgScale = preset.CFGScale
+	}
+
+	if preset.Steps > 0 {
+		options.Steps = preset.Steps
+	}
+
+	if preset.NegativePrompt != "" {
+		options.NegativePrompt = preset.NegativePrompt
+	}
+}
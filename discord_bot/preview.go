@@ -0,0 +1,92 @@
+package discord_bot
+
+import (
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/imagine_queue"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// queuePreview queues a small preview of the options, the full generation is queued once the user approves it
+func (b *botImpl) queuePreview(s *discordgo.Session, i *discordgo.InteractionCreate, queueOptions imagine_queue.QueueItemOptions, isDM bool) {
+	if isDM {
+		respondEphemeral(s, i, "DM usage is not allowed.")
+
+		return
+	}
+
+	queueOptions.Prompt, _ = b.translatePrompt(queueOptions.Prompt)
+
+	if !b.checkPromptLength(s, i, queueOptions.Prompt, queueOptions.NegativePrompt) {
+		return
+	}
+
+	position, err := b.imagineQueue.AddImagine(&imagine_queue.QueueItem{
+		Prompt:             queueOptions.Prompt,
+		Options:            queueOptions,
+		Type:               imagine_queue.ItemTypePreview,
+		DiscordInteraction: i.Interaction,
+	})
+	if b.respondRateLimited(s, i, err) {
+		return
+	} else if err != nil {
+		log.Printf("Error adding preview to queue: %v\n", err)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("I'm sketching a preview for you. You are currently #%d in line.", position),
+		},
+	})
+	if err != nil {
+		log.Printf("Error send interaction resp: %v\n", err)
+	}
+}
+
+// processImaginePreviewAnswer queues the full generation of an approved preview, or discards it
+func (b *botImpl) processImaginePreviewAnswer(s *discordgo.Session, i *discordgo.InteractionCreate, approved bool) {
+	item := b.imagineQueue.TakePreview(i.Message.ID)
+	if item == nil {
+		respondEphemeral(s, i, "This preview is no longer available.")
+
+		return
+	}
+
+	if item.DiscordInteraction.Member != nil && item.DiscordInteraction.Member.User.ID != interactionUser(i).ID {
+		// Put it back for the user who asked for it
+		b.imagineQueue.RestorePreview(item)
+
+		respondEphemeral(s, i, "Only the user who asked for this preview can answer it.")
+
+		return
+	}
+
+	if !approved {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "The preview is discarded.",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			log.Printf("Error responding to interaction: %v", err)
+		}
+
+		return
+	}
+
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         i.Message.ID,
+		Channel:    i.ChannelID,
+		Components: []discordgo.MessageComponent{},
+	})
+	if err != nil {
+		log.Printf("Error removing preview buttons: %v", err)
+	}
+
+	b.queueImagineOptions(s, i, item.Options, false)
+}
@@ -0,0 +1,45 @@
+package discord_bot
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// memberHasAllowedRole reports whether the member may generate images. Everyone may when no roles are configured
+func (b *botImpl) memberHasAllowedRole(member *discordgo.Member) bool {
+	if len(b.allowedRoleIDs) == 0 {
+		return true
+	}
+
+	if member == nil {
+		return false
+	}
+
+	for _, roleID := range member.Roles {
+		for _, allowedRoleID := range b.allowedRoleIDs {
+			if roleID == allowedRoleID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkAllowedRole responds with the restriction when the member doesn't have any of the allowed roles
+func (b *botImpl) checkAllowedRole(s *discordgo.Session, i *discordgo.InteractionCreate) bool {
+	if b.memberHasAllowedRole(i.Member) {
+		return true
+	}
+
+	roles := make([]string, len(b.allowedRoleIDs))
+	for idx, roleID := range b.allowedRoleIDs {
+		roles[idx] = "<@&" + roleID + ">"
+	}
+
+	respondEphemeral(s, i, "Image generation on this server is limited to members with one of these roles: "+
+		strings.Join(roles, ", "))
+
+	return false
+}
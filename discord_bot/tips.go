@@ -0,0 +1,109 @@
+package discord_bot
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"stable_diffusion_bot/entities"
+)
+
+const (
+	// DefaultTipsTime is the UTC time of day when tips are posted
+	DefaultTipsTime = "09:00"
+
+	tipsTimeLayout = "15:04"
+
+	// Guild setting with the index of the last posted tip
+	tipsLastIndexKey = "tips:last_index"
+)
+
+// loadTips reads a JSON array of tip strings
+func loadTips(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []string
+
+	err = json.Unmarshal(data, &tips)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tips) == 0 {
+		return nil, errors.New("tips file has no tips")
+	}
+
+	return tips, nil
+}
+
+// nextTipTime returns the closest moment after now at the given UTC time of day
+func nextTipTime(now time.Time, timeOfDay time.Time) time.Time {
+	now = now.UTC()
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// postTips posts one tip per day to the tips channel until done is closed
+func (b *botImpl) postTips(done <-chan struct{}) {
+	for {
+		now := b.clock.Now()
+		next := nextTipTime(now, b.tipsTime)
+
+		log.Printf("Next tip of the day is scheduled at %s", next.Format(time.RFC3339))
+
+		select {
+		case <-done:
+			return
+		case <-b.clock.After(next.Sub(now)):
+			b.postNextTip()
+		}
+	}
+}
+
+// nextTipIndex returns the index of the tip after the last posted one, starting over after the last tip
+func nextTipIndex(settings []*entities.UserSetting, tipsCount int) int {
+	for _, setting := range settings {
+		if setting.Key != tipsLastIndexKey {
+			continue
+		}
+
+		lastIndex, err := strconv.Atoi(setting.Value)
+		if err == nil && lastIndex >= 0 {
+			return (lastIndex + 1) % tipsCount
+		}
+	}
+
+	return 0
+}
+
+func (b *botImpl) postNextTip() {
+	settings, err := b.imagineQueue.GetGuildSettings(b.guildID)
+	if err != nil {
+		log.Printf("Error getting guild settings: %v", err)
+	}
+
+	index := nextTipIndex(settings, len(b.tips))
+
+	_, err = b.botSession.ChannelMessageSend(b.tipsChannelID, "💡 Tip of the day: "+b.tips[index])
+	if err != nil {
+		log.Printf("Error posting tip of the day: %v", err)
+
+		return
+	}
+
+	err = b.imagineQueue.UpdateGuildSetting(b.guildID, tipsLastIndexKey, strconv.Itoa(index))
+	if err != nil {
+		log.Printf("Error saving tip of the day index: %v", err)
+	}
+}
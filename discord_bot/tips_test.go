@@ -0,0 +1,95 @@
+package discord_bot
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestNextTipIndexRotates(t *testing.T) {
+	var settings []*entities.UserSetting
+
+	// Every posted tip is saved as the last index, like postNextTip does
+	var got []int
+	for day := 0; day < 7; day++ {
+		index := nextTipIndex(settings, 3)
+		got = append(got, index)
+
+		settings = []*entities.UserSetting{{Key: tipsLastIndexKey, Value: strconv.Itoa(index)}}
+	}
+
+	want := []int{0, 1, 2, 0, 1, 2, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("tip indexes = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNextTipIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  []*entities.UserSetting
+		tipsCount int
+		want      int
+	}{
+		{name: "no settings", tipsCount: 3, want: 0},
+		{name: "other settings", settings: []*entities.UserSetting{{Key: "other", Value: "1"}}, tipsCount: 3, want: 0},
+		{name: "invalid index", settings: []*entities.UserSetting{{Key: tipsLastIndexKey, Value: "x"}}, tipsCount: 3, want: 0},
+		{name: "negative index", settings: []*entities.UserSetting{{Key: tipsLastIndexKey, Value: "-2"}}, tipsCount: 3, want: 0},
+		// The tips file may have been shortened since the index was saved
+		{name: "index past the end", settings: []*entities.UserSetting{{Key: tipsLastIndexKey, Value: "7"}}, tipsCount: 3, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTipIndex(tt.settings, tt.tipsCount); got != tt.want {
+				t.Errorf("nextTipIndex() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextTipTime(t *testing.T) {
+	timeOfDay, err := time.Parse(tipsTimeLayout, DefaultTipsTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			name: "before the time of day",
+			now:  time.Date(2024, 3, 10, 8, 30, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "at the time of day",
+			now:  time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "after the time of day",
+			now:  time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "local time",
+			now:  time.Date(2024, 3, 10, 13, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60)),
+			want: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTipTime(tt.now, timeOfDay); !got.Equal(tt.want) {
+				t.Errorf("nextTipTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
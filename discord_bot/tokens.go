@@ -0,0 +1,40 @@
+package discord_bot
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// CLIP truncates prompts at 77 tokens, the warning leaves some room for the special tokens and miscounting
+const promptTokenWarningThreshold = 70
+
+const promptTokenWarning = " (⚠️ prompt may be truncated at ~77 tokens)"
+
+// promptTokenCount asks the API for the CLIP token count and falls back to counting words when it can't answer
+func (b *botImpl) promptTokenCount(prompt string) int {
+	count, err := b.stableDiffusionAPI.CountTokens(context.Background(), prompt)
+	if err != nil {
+		log.Printf("Error counting prompt tokens: %v", err)
+
+		return len(strings.Fields(prompt))
+	}
+
+	return count
+}
+
+// truncationWarning returns the warning appended to the queued message when the prompt is likely truncated.
+// Translated prompts are counted by words: the translation already took part of the 3 seconds Discord gives
+// for the response, and the token count request could take the rest
+func (b *botImpl) truncationWarning(prompt string, translated bool) string {
+	count := len(strings.Fields(prompt))
+	if !translated {
+		count = b.promptTokenCount(prompt)
+	}
+
+	if count <= promptTokenWarningThreshold {
+		return ""
+	}
+
+	return promptTokenWarning
+}
@@ -0,0 +1,177 @@
+package discord_bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	TranslationProviderGoogle         = "google"
+	TranslationProviderLibreTranslate = "libretranslate"
+
+	googleTranslateURL = "https://translation.googleapis.com/language/translate/v2"
+	libreTranslateURL  = "https://libretranslate.com/translate"
+
+	// Interactions must be answered within 3 seconds, so translation has to be quick
+	translationTimeout = 2 * time.Second
+
+	// Prompt flag that keeps the prompt as is
+	noTranslateFlag = "--no-translate"
+)
+
+type translator struct {
+	provider string
+	apiKey   string
+	client   *http.Client
+}
+
+func newTranslator(provider, apiKey string) (*translator, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case TranslationProviderGoogle, TranslationProviderLibreTranslate:
+	default:
+		return nil, fmt.Errorf("unknown translation provider: %s", provider)
+	}
+
+	return &translator{
+		provider: provider,
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: translationTimeout},
+	}, nil
+}
+
+// needsTranslation reports whether the text contains letters outside of ASCII, suggesting it is not English
+func needsTranslation(text string) bool {
+	for _, r := range text {
+		if r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// translatePrompt returns the prompt translated to English and whether it was translated.
+// The prompt is returned as is, without the --no-translate flag, when translation is disabled or fails
+func (b *botImpl) translatePrompt(prompt string) (string, bool) {
+	if strings.Contains(prompt, noTranslateFlag) {
+		return strings.TrimSpace(strings.ReplaceAll(prompt, noTranslateFlag, "")), false
+	}
+
+	if b.translator == nil || !needsTranslation(prompt) {
+		return prompt, false
+	}
+
+	translated, err := b.translator.translate(prompt)
+	if err != nil {
+		log.Printf("Error translating prompt: %v", err)
+
+		return prompt, false
+	}
+
+	return translated, translated != prompt
+}
+
+func (t *translator) translate(text string) (string, error) {
+	switch t.provider {
+	case TranslationProviderGoogle:
+		return t.translateGoogle(text)
+	default:
+		return t.translateLibre(text)
+	}
+}
+
+func (t *translator) translateGoogle(text string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"q":      text,
+		"target": "en",
+		"format": "text",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var respStruct struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+
+	// The key is sent in a header, a query parameter would end up in the logged URL errors
+	err = t.post(googleTranslateURL, http.Header{"X-Goog-Api-Key": {t.apiKey}}, body, &respStruct)
+	if err != nil {
+		return "", err
+	}
+
+	if len(respStruct.Data.Translations) == 0 {
+		return "", errors.New("empty translation response")
+	}
+
+	return respStruct.Data.Translations[0].TranslatedText, nil
+}
+
+func (t *translator) translateLibre(text string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  "auto",
+		"target":  "en",
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var respStruct struct {
+		TranslatedText string `json:"translatedText"`
+	}
+
+	err = t.post(libreTranslateURL, nil, body, &respStruct)
+	if err != nil {
+		return "", err
+	}
+
+	return respStruct.TranslatedText, nil
+}
+
+func (t *translator) post(postURL string, header http.Header, body []byte, result interface{}) error {
+	request, err := http.NewRequest(http.MethodPost, postURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected translation API status %d: %s", response.StatusCode, respBody)
+	}
+
+	return json.Unmarshal(respBody, result)
+}
@@ -0,0 +1,144 @@
+package discord_bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	vaeOptionVAE = `vae`
+)
+
+func (b *botImpl) addImagineVAECommand() error {
+	command := b.imagineVAECommandString()
+	log.Printf("Adding command '%s'...", command)
+
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.guildID, &discordgo.ApplicationCommand{
+		Name:        command,
+		Description: "List the available VAEs or switch the loaded VAE (Manage Server permission)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        vaeOptionVAE,
+				Description: "Name of the VAE to load, Automatic or None. The VAEs are listed when empty",
+				Required:    false,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating '%s' command: %v", command, err)
+
+		return err
+	}
+
+	b.registeredCommands = append(b.registeredCommands, cmd)
+
+	return nil
+}
+
+func (b *botImpl) processImagineVAECommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		respondEphemeral(s, i, "You need the Manage Server permission to change the VAE.")
+
+		return
+	}
+
+	vae := ""
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == vaeOptionVAE {
+			vae = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	// Loading a VAE may take longer than the 3 seconds Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+
+		return
+	}
+
+	if vae == "" {
+		b.listVAEs(s, i)
+
+		return
+	}
+
+	log.Printf("Loading VAE '%s' requested by %s", vae, interactionUser(i).Username)
+
+	err = b.stableDiffusionAPI.SetVAE(context.Background(), vae)
+	if err != nil {
+		log.Printf("Error setting the VAE: %v", err)
+
+		editInteractionContent(s, i, fmt.Sprintf("Error loading `%s`. Is it listed by `/%s`?", vae, b.imagineVAECommandString()))
+
+		return
+	}
+
+	editInteractionContent(s, i, fmt.Sprintf("Loaded `%s`.", vae))
+}
+
+// listVAEs edits the deferred response to an embed with the available VAEs, marking the loaded one
+func (b *botImpl) listVAEs(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	vaes, err := b.stableDiffusionAPI.GetVAEs(context.Background())
+	if err != nil {
+		log.Printf("Error getting the VAEs: %v", err)
+
+		editInteractionContent(s, i, "Error getting the VAEs...")
+
+		return
+	}
+
+	current := ""
+
+	options, err := b.stableDiffusionAPI.GetSDOptions(context.Background())
+	if err != nil {
+		log.Printf("Error getting the current VAE: %v", err)
+	} else {
+		current = options.SDVAE
+	}
+
+	names := []string{stable_diffusion_api.VAEAutomatic, stable_diffusion_api.VAENone}
+	for _, vae := range vaes {
+		names = append(names, vae.ModelName)
+	}
+
+	var builder strings.Builder
+
+	for _, name := range names {
+		line := fmt.Sprintf("`%s`\n", name)
+		if name == current {
+			line = fmt.Sprintf("**`%s`** (loaded)\n", name)
+		}
+
+		if builder.Len()+len(line) > maxEmbedDescriptionLength {
+			break
+		}
+
+		builder.WriteString(line)
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{
+			{
+				Title:       fmt.Sprintf("Available VAEs (%d)", len(vaes)),
+				Description: builder.String(),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
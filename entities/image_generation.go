@@ -25,5 +25,9 @@ type ImageGeneration struct {
 	CfgScale          float64   `json:"cfg_scale"`
 	Steps             int       `json:"steps"`
 	Processed         bool      `json:"processed"`
+	Pinned            bool      `json:"pinned"`
+	Deleted           bool      `json:"deleted"`
+	SourceMessageID   string    `json:"source_message_id"`
+	RootMessageID     string    `json:"root_message_id"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -0,0 +1,6 @@
+package entities
+
+type NotificationPreference struct {
+	MemberID  string `json:"member_id"`
+	DMEnabled bool   `json:"dm_enabled"`
+}
@@ -3,15 +3,34 @@ package entities
 import "time"
 
 type Statistics struct {
-	ID                int64     `json:"id"`
-	ImageGenerationID int64     `json:"image_generation_id"`
-	MemberID          string    `json:"member_id"`
-	TimeMs            int64     `json:"time_ms"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                int64  `json:"id"`
+	ImageGenerationID int64  `json:"image_generation_id"`
+	MemberID          string `json:"member_id"`
+	ServerID          string `json:"server_id"`
+	TimeMs            int64  `json:"time_ms"`
+	// Time the item waited in the queue before processing
+	QueueWaitMs int64 `json:"queue_wait_ms"`
+	// Awarded for entries of the daily prompt challenge
+	BonusPoints int64     `json:"bonus_points"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type StatsByMember struct {
 	MemberID string `json:"member_id"`
 	Count    int64  `json:"count"`
 	TimeMs   int64  `json:"time_ms"`
+	// Only filled by GetStatByMember
+	BonusPoints int64 `json:"bonus_points"`
+}
+
+type GlobalStats struct {
+	Count  int64 `json:"count"`
+	TimeMs int64 `json:"time_ms"`
+}
+
+type DailyStats struct {
+	// Day in the 2006-01-02 format
+	Date        string `json:"date"`
+	Count       int64  `json:"count"`
+	TotalTimeMs int64  `json:"total_time_ms"`
 }
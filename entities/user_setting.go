@@ -0,0 +1,8 @@
+package entities
+
+type UserSetting struct {
+	GuildID  string `json:"guild_id"`
+	MemberID string `json:"member_id"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/bwmarrin/discordgo v0.26.1
+	golang.org/x/image v0.0.0-20220413100746-70e8d0d3baa9
 	modernc.org/sqlite v1.20.1
 )
 
@@ -0,0 +1,92 @@
+package health_check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"stable_diffusion_bot/repositories/statistics"
+	"stable_diffusion_bot/stable_diffusion_api"
+)
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+
+	// Probes usually time out after a few seconds, a hanging component shouldn't hang the response
+	checkTimeout = 2 * time.Second
+)
+
+type Config struct {
+	StableDiffusionAPI stable_diffusion_api.StableDiffusionAPI
+	StatisticsRepo     statistics.Repository
+}
+
+type handlerImpl struct {
+	stableDiffusionAPI stable_diffusion_api.StableDiffusionAPI
+	statisticsRepo     statistics.Repository
+}
+
+type response struct {
+	Status     string            `json:"status"`
+	Components map[string]string `json:"components"`
+}
+
+// New returns the handler of the /health endpoint, it responds with 503 when any of the components is down
+func New(cfg Config) (http.Handler, error) {
+	if cfg.StableDiffusionAPI == nil {
+		return nil, errors.New("missing stable diffusion API")
+	}
+
+	if cfg.StatisticsRepo == nil {
+		return nil, errors.New("missing statistics repo")
+	}
+
+	return &handlerImpl{
+		stableDiffusionAPI: cfg.StableDiffusionAPI,
+		statisticsRepo:     cfg.StatisticsRepo,
+	}, nil
+}
+
+func (h *handlerImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := &response{
+		Status: StatusOK,
+		Components: map[string]string{
+			"sd_api":   componentStatus("sd_api", h.stableDiffusionAPI.HealthCheck(ctx)),
+			"database": componentStatus("database", h.statisticsRepo.Ping(ctx)),
+		},
+	}
+
+	for _, status := range resp.Components {
+		if status != StatusOK {
+			resp.Status = StatusError
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if resp.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.Printf("Error writing health check response: %v", err)
+	}
+}
+
+func componentStatus(component string, err error) string {
+	if err != nil {
+		log.Printf("Health check of %s failed: %v", component, err)
+
+		return StatusError
+	}
+
+	return StatusOK
+}
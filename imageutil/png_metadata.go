@@ -0,0 +1,110 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ParametersKey is the text chunk key the AUTOMATIC1111 webui reads the generation parameters from
+const ParametersKey = "parameters"
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// ErrNotPNG is returned for images in other formats, e.g. webp samples
+var ErrNotPNG = errors.New("not a PNG image")
+
+// EmbedPNGMetadata stores params in a text chunk with ParametersKey after the image header, replacing the
+// parameters the image already has. Like the webui, text outside of Latin-1 goes to an iTXt chunk as UTF-8.
+// The image data is copied as is
+func EmbedPNGMetadata(imgBytes []byte, params string) ([]byte, error) {
+	if !bytes.HasPrefix(imgBytes, pngSignature) {
+		return nil, ErrNotPNG
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(imgBytes)+len(params)+64))
+	out.Write(pngSignature)
+
+	rest := imgBytes[len(pngSignature):]
+	embedded := false
+
+	for len(rest) > 0 {
+		// length, type, data, CRC
+		if len(rest) < 12 {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		length := binary.BigEndian.Uint32(rest[:4])
+		if uint64(len(rest)) < 12+uint64(length) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		chunkType := string(rest[4:8])
+		data := rest[8 : 8+length]
+		chunk := rest[:12+length]
+		rest = rest[12+length:]
+
+		if (chunkType == "tEXt" || chunkType == "iTXt") && bytes.HasPrefix(data, []byte(ParametersKey+"\x00")) {
+			continue
+		}
+
+		out.Write(chunk)
+
+		if chunkType == "IHDR" && !embedded {
+			writeTextChunk(out, ParametersKey, params)
+
+			embedded = true
+		}
+	}
+
+	if !embedded {
+		return nil, errors.New("missing PNG header chunk")
+	}
+
+	return out.Bytes(), nil
+}
+
+func writeTextChunk(out *bytes.Buffer, key, text string) {
+	chunkType := "tEXt"
+	data := []byte(key + "\x00")
+
+	if latin1, ok := toLatin1(text); ok {
+		data = append(data, latin1...)
+	} else {
+		chunkType = "iTXt"
+		// no compression, empty language tag and translated key
+		data = append(data, 0, 0, 0, 0)
+		data = append(data, text...)
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	out.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+
+	out.WriteString(chunkType)
+	out.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	out.Write(sum[:])
+}
+
+// toLatin1 encodes the text as ISO 8859-1, the only encoding allowed in tEXt chunks
+func toLatin1(text string) ([]byte, bool) {
+	encoded := make([]byte, 0, len(text))
+
+	for _, r := range text {
+		if r > 0xff {
+			return nil, false
+		}
+
+		encoded = append(encoded, byte(r))
+	}
+
+	return encoded, true
+}
@@ -0,0 +1,105 @@
+package imagine_queue
+
+import (
+	"context"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Cancel removes a waiting item from the queue. With position 0 it is the last item queued by the user,
+// otherwise the item at that 1-based position, which must belong to the user unless userID is empty.
+// Items that are already processing can't be cancelled
+func (q *queueImpl) Cancel(userID string, position int) error {
+	q.mu.Lock()
+
+	index := -1
+
+	if position == 0 {
+		for idx := len(q.queue) - 1; idx >= 0; idx-- {
+			if itemUserID(q.queue[idx]) == userID {
+				index = idx
+
+				break
+			}
+		}
+	} else if position > 0 && position <= len(q.queue) {
+		index = position - 1
+	}
+
+	if index < 0 {
+		q.mu.Unlock()
+
+		return ErrNoPendingItem
+	}
+
+	item := q.queue[index]
+
+	if userID != "" && itemUserID(item) != userID {
+		q.mu.Unlock()
+
+		return ErrNotItemOwner
+	}
+
+	q.queue = append(q.queue[:index], q.queue[index+1:]...)
+
+	if waiter, ok := q.waiters[item.DiscordInteraction.ID]; ok {
+		waiter <- QueueResult{InteractionID: item.DiscordInteraction.ID}
+
+		delete(q.waiters, item.DiscordInteraction.ID)
+	}
+
+	q.mu.Unlock()
+
+	go q.notifyCancelledByUser(item)
+
+	return nil
+}
+
+// Interrupt stops the generation on the server and aborts the requests of the items being processed,
+// so their partial results are discarded. It returns the number of interrupted items
+func (q *queueImpl) Interrupt(ctx context.Context) (int, error) {
+	q.mu.Lock()
+
+	var cancels []context.CancelFunc
+
+	for _, item := range q.inProgress {
+		if item.cancel != nil {
+			cancels = append(cancels, item.cancel)
+		}
+	}
+
+	q.mu.Unlock()
+
+	if len(cancels) == 0 {
+		return 0, ErrNoActiveItem
+	}
+
+	// The requests are aborted first, the interrupted generation would return its partial images otherwise
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	err := q.stableDiffusionAPI.Interrupt(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(cancels), nil
+}
+
+// notifyCancelledByUser replaces the "in line" response of the cancelled item
+func (q *queueImpl) notifyCancelledByUser(item *QueueItem) {
+	if q.botSession == nil || item.interactionExpired() {
+		return
+	}
+
+	content := "This request was cancelled."
+
+	_, err := q.botSession.InteractionResponseEdit(item.DiscordInteraction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package imagine_queue
+
+import (
+	"fmt"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+)
+
+// alwaysonScripts returns the extension scripts of the item with the ControlNet unit added when it has a control image
+func alwaysonScripts(imagine *QueueItem) (map[string]interface{}, error) {
+	if imagine.Options.ControlNetImageURL == "" {
+		return imagine.Options.AlwaysonScripts, nil
+	}
+
+	controlImage, err := DownloadImageBase64(imagine.Options.ControlNetImageURL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading the ControlNet image: %w", err)
+	}
+
+	scripts := stable_diffusion_api.ControlNetScripts(stable_diffusion_api.ControlNetOptions{
+		Image: controlImage,
+		Model: imagine.Options.ControlNetModel,
+	})
+
+	for name, args := range imagine.Options.AlwaysonScripts {
+		scripts[name] = args
+	}
+
+	return scripts, nil
+}
@@ -0,0 +1,30 @@
+package imagine_queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RateLimitError is returned by AddImagine when the user already has the maximum number of pending items
+type RateLimitError struct {
+	Pending int
+	Limit   int
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%d of %d pending items per user", e.Pending, e.Limit)
+}
+
+func (e *RateLimitError) Is(err error) bool {
+	_, ok := err.(*RateLimitError)
+	return ok
+}
+
+var (
+	// ErrNoPendingItem is returned by Cancel when there is no waiting item to cancel
+	ErrNoPendingItem = errors.New("no pending item")
+	// ErrNotItemOwner is returned by Cancel for items queued by another user
+	ErrNotItemOwner = errors.New("item belongs to another user")
+	// ErrNoActiveItem is returned by Interrupt when no item is being processed
+	ErrNoActiveItem = errors.New("no active item")
+)
@@ -0,0 +1,231 @@
+package imagine_queue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/stable_diffusion_api"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// The discordgo version in use doesn't support forum channels, so they are handled with raw API requests
+const channelTypeGuildForum discordgo.ChannelType = 15
+
+const (
+	forumTitleLength = 50
+	// Discord limits tag names to 20 characters and forums to 20 tags
+	forumTagLength = 20
+	maxForumTags   = 20
+)
+
+type forumTag struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type forumChannel struct {
+	Type          discordgo.ChannelType `json:"type"`
+	AvailableTags []forumTag            `json:"available_tags"`
+}
+
+type forumThreadMessage struct {
+	Content string `json:"content"`
+}
+
+type forumThreadStart struct {
+	Name        string             `json:"name"`
+	AppliedTags []string           `json:"applied_tags,omitempty"`
+	Message     forumThreadMessage `json:"message"`
+}
+
+// postToForum posts the finished generation as a new post of the forum channel, tagged with the model and sampler.
+// When the channel isn't a forum, the images are posted as a regular message
+func (q *queueImpl) postToForum(imagine *QueueItem, generation *entities.ImageGeneration,
+	resp *stable_diffusion_api.TextToImageResponse, gridMessage *discordgo.Message,
+) {
+	content := fmt.Sprintf("<@%s> asked me to imagine `%s`", imagine.DiscordInteraction.Member.User.ID, generation.Prompt)
+	if gridMessage != nil {
+		content += "\n" + messageLink(imagine.DiscordInteraction.GuildID, gridMessage)
+	}
+
+	files := make([]*discordgo.File, 0, len(resp.Images))
+
+	// Forums show the first image as the thumbnail of the post, so the most detailed one goes first
+	best := resp.BestImage()
+	bestIdx := -1
+
+	for idx, image := range resp.Images {
+		if idx >= len(resp.Seeds) {
+			break
+		}
+
+		decodedImage, err := base64.StdEncoding.DecodeString(image)
+		if err != nil {
+			log.Printf("Error decoding image: %v\n", err)
+
+			continue
+		}
+
+		if bestIdx < 0 && image == best {
+			bestIdx = len(files)
+		}
+
+		files = append(files, &discordgo.File{
+			ContentType: "image/png",
+			Name:        fmt.Sprintf("seed-%d-%s.png", resp.Seeds[idx], resp.Model),
+			Reader:      bytes.NewBuffer(decodedImage),
+		})
+	}
+
+	if bestIdx > 0 {
+		files[0], files[bestIdx] = files[bestIdx], files[0]
+	}
+
+	channel, err := q.getForumChannel()
+	if err != nil {
+		log.Printf("Error getting forum channel: %v", err)
+
+		return
+	}
+
+	if channel.Type != channelTypeGuildForum {
+		_, err = q.botSession.ChannelMessageSendComplex(q.forumChannelID, &discordgo.MessageSend{
+			Content: content,
+			Files:   files,
+		})
+		if err != nil {
+			log.Printf("Error posting to channel %s: %v", q.forumChannelID, err)
+		}
+
+		return
+	}
+
+	title := []rune(generation.Prompt)
+	if len(title) > forumTitleLength {
+		title = title[:forumTitleLength]
+	}
+
+	if len(title) == 0 {
+		title = []rune("Untitled")
+	}
+
+	endpoint := discordgo.EndpointChannelThreads(q.forumChannelID)
+
+	contentType, body, err := discordgo.MultipartBodyWithJSON(&forumThreadStart{
+		Name:        string(title),
+		AppliedTags: q.forumTagIDs(channel, resp.Model, generation.SamplerName),
+		Message:     forumThreadMessage{Content: content},
+	}, files)
+	if err != nil {
+		log.Printf("Error encoding forum post: %v", err)
+
+		return
+	}
+
+	_, err = q.botSession.RequestWithLockedBucket("POST", endpoint, contentType, body,
+		q.botSession.Ratelimiter.LockBucket(endpoint), 0)
+	if err != nil {
+		log.Printf("Error creating forum post: %v", err)
+	}
+}
+
+func (q *queueImpl) getForumChannel() (*forumChannel, error) {
+	endpoint := discordgo.EndpointChannel(q.forumChannelID)
+
+	body, err := q.botSession.RequestWithBucketID("GET", endpoint, nil, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := &forumChannel{}
+
+	err = json.Unmarshal(body, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+// forumTagIDs returns the IDs of the tags with the given names, creating the missing ones.
+// Tags that can't be created, e.g. without the Manage Channels permission, are skipped
+func (q *queueImpl) forumTagIDs(channel *forumChannel, names ...string) []string {
+	// Workers finishing at the same time would otherwise create the same tag twice
+	q.forumMu.Lock()
+	defer q.forumMu.Unlock()
+
+	// Tags created by another worker since the channel was fetched
+	current, err := q.getForumChannel()
+	if err == nil {
+		channel = current
+	}
+
+	tags := channel.AvailableTags
+	missing := false
+
+	for _, name := range names {
+		name = forumTagName(name)
+		if name == "" || findForumTag(tags, name) != nil || len(tags) >= maxForumTags {
+			continue
+		}
+
+		tags = append(tags, forumTag{Name: name})
+		missing = true
+	}
+
+	if missing {
+		endpoint := discordgo.EndpointChannel(q.forumChannelID)
+
+		body, err := q.botSession.RequestWithBucketID("PATCH", endpoint, map[string]interface{}{
+			"available_tags": tags,
+		}, endpoint)
+		if err != nil {
+			log.Printf("Error creating forum tags: %v", err)
+
+			tags = channel.AvailableTags
+		} else {
+			updated := &forumChannel{}
+
+			err = json.Unmarshal(body, updated)
+			if err != nil {
+				log.Printf("Error decoding forum channel: %v", err)
+			}
+
+			tags = updated.AvailableTags
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if tag := findForumTag(tags, forumTagName(name)); tag != nil && tag.ID != "" {
+			ids = append(ids, tag.ID)
+		}
+	}
+
+	return ids
+}
+
+func forumTagName(name string) string {
+	runes := []rune(name)
+	if len(runes) > forumTagLength {
+		runes = runes[:forumTagLength]
+	}
+
+	return string(runes)
+}
+
+func findForumTag(tags []forumTag, name string) *forumTag {
+	for idx := range tags {
+		if tags[idx].Name == name {
+			return &tags[idx]
+		}
+	}
+
+	return nil
+}
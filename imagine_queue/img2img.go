@@ -0,0 +1,237 @@
+package imagine_queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Discord attachments are served from its CDN, a slow download shouldn't block the worker for long
+const initImageDownloadTimeout = 30 * time.Second
+
+// Discord's upload limit without boosts, larger files can't be attachments anyway
+const maxImageDownloadSize = 25 << 20
+
+var initImageClient = &http.Client{Timeout: initImageDownloadTimeout}
+
+// DownloadImageBase64 downloads the image and returns its contents encoded in base64.
+// Responses that aren't images or exceed maxImageDownloadSize are rejected
+func DownloadImageBase64(url string) (string, error) {
+	body, err := downloadImage(url)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(body), nil
+}
+
+func downloadImage(url string) ([]byte, error) {
+	response, err := initImageClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code downloading image: %d", response.StatusCode)
+	}
+
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("unexpected content type downloading image: %q", contentType)
+	}
+
+	if response.ContentLength > maxImageDownloadSize {
+		return nil, fmt.Errorf("image is too large: %d bytes, the limit is %d", response.ContentLength, maxImageDownloadSize)
+	}
+
+	// The extra byte tells a body of exactly the limit from a larger one without Content-Length
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxImageDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > maxImageDownloadSize {
+		return nil, fmt.Errorf("image is larger than the limit of %d bytes", maxImageDownloadSize)
+	}
+
+	return body, nil
+}
+
+// resizeImage scales the image to cover width x height and crops the overflow around the center,
+// so that it isn't stretched, and encodes the result as PNG
+func resizeImage(data []byte, width, height int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// CatmullRom is the closest to Lanczos of the x/image/draw kernels
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, coverRect(src.Bounds(), width, height), draw.Src, nil)
+
+	var buf bytes.Buffer
+
+	err = png.Encode(&buf, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// coverRect returns the largest centered part of the bounds with the aspect ratio of width x height
+func coverRect(bounds image.Rectangle, width, height int) image.Rectangle {
+	boundsWidth, boundsHeight := bounds.Dx(), bounds.Dy()
+
+	// Wider than the target, the sides are cropped
+	if boundsWidth*height > boundsHeight*width {
+		cropWidth := boundsHeight * width / height
+		x := bounds.Min.X + (boundsWidth-cropWidth)/2
+
+		return image.Rect(x, bounds.Min.Y, x+cropWidth, bounds.Max.Y)
+	}
+
+	cropHeight := boundsWidth * height / width
+	y := bounds.Min.Y + (boundsHeight-cropHeight)/2
+
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+cropHeight)
+}
+
+// processImg2ImgImagine redraws the attached image following the prompt
+func (q *queueImpl) processImg2ImgImagine(ctx context.Context, imagine *QueueItem) {
+	timeStart := time.Now()
+
+	log.Printf("Processing img2img #%s: %v\n", imagine.DiscordInteraction.ID, imagine.Options.Prompt)
+
+	errorContent := "I'm sorry, but I had a problem imagining your image."
+
+	newContent := fmt.Sprintf("<@%s> asked me to reimagine their image as `%s`. Currently dreaming it up for them.",
+		imagine.DiscordInteraction.Member.User.ID, imagine.Options.Prompt)
+
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &newContent,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	} else {
+		imagine.DiscordMessageID = message.ID
+	}
+
+	resp, err := q.imageToImage(ctx, imagine)
+	if err != nil {
+		log.Printf("Error processing img2img: %v\n", err)
+		imagine.markInterrupted(err)
+
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content: &errorContent,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v", err)
+		}
+
+		return
+	}
+
+	var files []*discordgo.File
+
+	for idx, image := range resp.Images {
+		decodedImage, decodeErr := base64.StdEncoding.DecodeString(image)
+		if decodeErr != nil {
+			log.Printf("Error decoding image: %v\n", decodeErr)
+
+			continue
+		}
+
+		seed := 0
+		if idx < len(resp.Seeds) {
+			seed = resp.Seeds[idx]
+		}
+
+		files = append(files, &discordgo.File{
+			ContentType: "image/png",
+			Name:        fmt.Sprintf("seed-%d-%s.png", seed, resp.Model),
+			Reader:      bytes.NewBuffer(decodedImage),
+		})
+	}
+
+	totalTime := time.Since(timeStart).Round(time.Millisecond)
+
+	finishedContent := fmt.Sprintf("<@%s> asked me to reimagine their image as `%s` (%s)",
+		imagine.DiscordInteraction.Member.User.ID, imagine.Options.Prompt, totalTime)
+
+	_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &finishedContent,
+		Files:   files,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v\n", err)
+	}
+}
+
+func (q *queueImpl) imageToImage(ctx context.Context, imagine *QueueItem) (*stable_diffusion_api.TextToImageResponse, error) {
+	initImage, err := downloadImage(imagine.InitImageURL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading the init image: %w", err)
+	}
+
+	defaultWidth, err := q.defaultWidth()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultHeight, err := q.defaultHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	promptRes, err := extractDimensionsFromPrompt(imagine.Options.Prompt, defaultWidth, defaultHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attachments rarely match the generation size, and sending them at full size makes large payloads
+	if !imagine.Options.SkipResize {
+		initImage, err = resizeImage(initImage, promptRes.Width, promptRes.Height)
+		if err != nil {
+			return nil, fmt.Errorf("error resizing the init image: %w", err)
+		}
+	}
+
+	return q.stableDiffusionAPI.ImageToImage(ctx, &stable_diffusion_api.ImageToImageRequest{
+		InitImages: []string{base64.StdEncoding.EncodeToString(initImage)},
+		// Only applies with SkipResize, cropping keeps the aspect ratio of the image
+		ResizeMode:        stable_diffusion_api.ResizeModeCropAndResize,
+		Prompt:            promptRes.SanitizedPrompt,
+		NegativePrompt:    imagine.Options.NegativePrompt,
+		Width:             promptRes.Width,
+		Height:            promptRes.Height,
+		RestoreFaces:      imagine.Options.RestoreFaces,
+		DenoisingStrength: imagine.Options.DenoisingStrength,
+		BatchSize:         1,
+		Seed:              imagine.Options.Seed,
+		Subseed:           -1,
+		SamplerName:       q.canonicalSampler(imagine.Options.SamplerName),
+		CfgScale:          imagine.Options.CfgScale,
+		Steps:             imagine.Options.Steps,
+		NIter:             1,
+		SaveImages:        true,
+	})
+}
@@ -0,0 +1,82 @@
+package imagine_queue
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestCoverRect(t *testing.T) {
+	tests := []struct {
+		name          string
+		bounds        image.Rectangle
+		width, height int
+		want          image.Rectangle
+	}{
+		{name: "same aspect ratio", bounds: image.Rect(0, 0, 1024, 1024), width: 512, height: 512, want: image.Rect(0, 0, 1024, 1024)},
+		{name: "wider", bounds: image.Rect(0, 0, 1920, 1080), width: 512, height: 512, want: image.Rect(420, 0, 1500, 1080)},
+		{name: "taller", bounds: image.Rect(0, 0, 1000, 2000), width: 768, height: 512, want: image.Rect(0, 667, 1000, 1333)},
+		{name: "offset bounds", bounds: image.Rect(10, 10, 30, 20), width: 64, height: 64, want: image.Rect(15, 10, 25, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coverRect(tt.bounds, tt.width, tt.height); got != tt.want {
+				t.Errorf("coverRect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	// A wide image with red sides that the crop removes
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 100; y++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+
+			src.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resized, err := resizeImage(buf.Bytes(), 64, 64)
+	if err != nil {
+		t.Fatalf("resizeImage() error = %v", err)
+	}
+
+	got, format, err := image.Decode(bytes.NewReader(resized))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format != "png" {
+		t.Errorf("format = %s, want png", format)
+	}
+
+	if size := got.Bounds().Size(); size != image.Pt(64, 64) {
+		t.Errorf("size = %v, want 64x64", size)
+	}
+
+	if r, _, b, _ := got.At(2, 32).RGBA(); r > b {
+		t.Errorf("edge pixel is red, want the blue center of the image")
+	}
+}
+
+func TestResizeImageRejectsNonImages(t *testing.T) {
+	_, err := resizeImage([]byte("not an image"), 64, 64)
+	if err == nil {
+		t.Error("resizeImage() error = nil, want a decoding error")
+	}
+}
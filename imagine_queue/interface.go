@@ -1,11 +1,40 @@
 package imagine_queue
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+
+	"github.com/bwmarrin/discordgo"
+)
 
 type Queue interface {
 	AddImagine(item *QueueItem) (int, error)
+	GetQueuePosition(interactionID string) int
+	GetActiveItems() []*QueueItem
+	GetWaitingItems() []*QueueItem
+	Flush() (int, error)
+	Cancel(userID string, position int) error
+	Interrupt(ctx context.Context) (int, error)
+	TakePreview(messageID string) *QueueItem
+	RestorePreview(item *QueueItem)
+	WaitForItem(ctx context.Context, itemID string) (*QueueResult, error)
 	StartPolling(botSession *discordgo.Session)
 	GetDefaultBotWidth() (int, error)
 	GetDefaultBotHeight() (int, error)
 	UpdateDefaultDimensions(width, height int) error
+	GetDefaultTokenMergingRatio(guildID string) (*float64, error)
+	GetDefaultSampler(guildID, memberID string) (string, error)
+	GetDefaultCFGScale(guildID, memberID string) (float64, error)
+	GetDefaultSteps(guildID, memberID string) (int, error)
+	GetDefaultNegativePrompt(guildID, memberID string) (string, error)
+	GetDefaultClipSkip(guildID, memberID string) (int, error)
+	NewMemberQueueItemOptions(guildID, memberID string) QueueItemOptions
+	GetMemberSettings(guildID, memberID string) ([]*entities.UserSetting, error)
+	UpdateMemberSetting(guildID, memberID, key, value string) error
+	ResetMemberSettings(guildID, memberID string) error
+	GetGuildSettings(guildID string) ([]*entities.UserSetting, error)
+	UpdateGuildSetting(guildID, key, value string) error
+	GetDMNotifications(memberID string) (bool, error)
+	UpdateDMNotifications(memberID string, enabled bool) error
 }
@@ -0,0 +1,79 @@
+package imagine_queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Discord limit for the content of a message, the PNG info is shortened to fit next to the caption
+	maxMessageLength = 2000
+
+	pngInfoFormat = "\nCopy settings:\n```\n%s\n```"
+	truncatedMark = "..."
+)
+
+// processInterrogateImagine captions the image with CLIP and replies with the caption and,
+// for images made by the WebUI, the generation settings stored in the PNG
+func (q *queueImpl) processInterrogateImagine(ctx context.Context, imagine *QueueItem) {
+	log.Printf("Processing interrogate #%s\n", imagine.DiscordInteraction.ID)
+
+	content := "I'm sorry, but I had a problem interrogating your image."
+
+	defer func() {
+		message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v", err)
+
+			return
+		}
+
+		imagine.DiscordMessageID = message.ID
+	}()
+
+	caption, err := q.stableDiffusionAPI.Interrogate(ctx, imagine.InterrogateImage, "clip")
+	if err != nil {
+		log.Printf("Error interrogating image: %v", err)
+		imagine.markInterrupted(err)
+
+		return
+	}
+
+	imagine.Caption = caption
+
+	content = fmt.Sprintf("Caption: `%s`", caption)
+
+	pngInfo, err := q.stableDiffusionAPI.GetPNGInfo(ctx, imagine.InterrogateImage)
+	if err != nil {
+		log.Printf("Error getting PNG info: %v", err)
+		imagine.markInterrupted(err)
+
+		return
+	}
+
+	if pngInfo != nil && pngInfo.Info != "" {
+		content += formatPNGInfo(pngInfo.Info, maxMessageLength-utf8.RuneCountInString(content))
+	}
+}
+
+// formatPNGInfo returns the PNG info block, shortening the info so the block is at most maxLength characters.
+// It is empty when even a shortened info doesn't fit
+func formatPNGInfo(info string, maxLength int) string {
+	block := fmt.Sprintf(pngInfoFormat, info)
+	if utf8.RuneCountInString(block) <= maxLength {
+		return block
+	}
+
+	infoLength := maxLength - utf8.RuneCountInString(fmt.Sprintf(pngInfoFormat, truncatedMark))
+	if infoLength <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(pngInfoFormat, string([]rune(info)[:infoLength])+truncatedMark)
+}
@@ -0,0 +1,66 @@
+package imagine_queue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// livePreview is the follow-up message showing the current denoising step of a generation
+type livePreview struct {
+	q         *queueImpl
+	imagine   *QueueItem
+	messageID string
+}
+
+// update replaces the preview message with the image. The image is posted in a new message,
+// because webhook edits can only add attachments to a message, not replace them
+func (p *livePreview) update(currentImage string) {
+	// Channel messages of expired interactions are left alone, they are reserved for the status and the result
+	if currentImage == "" || p.imagine.interactionExpired() {
+		return
+	}
+
+	decodedImage, err := base64.StdEncoding.DecodeString(currentImage)
+	if err != nil {
+		log.Printf("Error decoding preview image: %v", err)
+
+		return
+	}
+
+	message, err := p.q.botSession.FollowupMessageCreate(p.imagine.followupInteraction(), true, &discordgo.WebhookParams{
+		Content: "Preview of the current step",
+		Files: []*discordgo.File{
+			{
+				ContentType: "image/png",
+				Name:        "preview.png",
+				Reader:      bytes.NewReader(decodedImage),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error sending preview: %v", err)
+
+		return
+	}
+
+	p.delete()
+
+	p.messageID = message.ID
+}
+
+// delete removes the preview message, if any
+func (p *livePreview) delete() {
+	if p.messageID == "" {
+		return
+	}
+
+	err := p.q.botSession.FollowupMessageDelete(p.imagine.followupInteraction(), p.messageID)
+	if err != nil {
+		log.Printf("Error deleting preview: %v", err)
+	}
+
+	p.messageID = ""
+}
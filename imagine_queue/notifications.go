@@ -0,0 +1,68 @@
+package imagine_queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Items queued further than this are likely to be left unattended, so their owners get a DM when done
+const notifyQueuePosition = 5
+
+// notifyCompletion sends a direct message with a link to the result to the owner of a long waiting item.
+// Delivery errors, e.g. when the user has DMs disabled, are ignored
+func (q *queueImpl) notifyCompletion(imagine *QueueItem, message *discordgo.Message) {
+	if imagine.QueuePosition <= notifyQueuePosition || message == nil || imagine.DiscordInteraction.Member == nil {
+		return
+	}
+
+	userID := imagine.DiscordInteraction.Member.User.ID
+
+	enabled, err := q.GetDMNotifications(userID)
+	if err != nil {
+		log.Printf("Error getting notification preferences: %v", err)
+
+		return
+	}
+
+	if !enabled {
+		return
+	}
+
+	channel, err := q.botSession.UserChannelCreate(userID)
+	if err != nil {
+		return
+	}
+
+	_, _ = q.botSession.ChannelMessageSend(channel.ID,
+		fmt.Sprintf("Your imagination is ready: %s", messageLink(imagine.DiscordInteraction.GuildID, message)))
+}
+
+// GetDMNotifications reports whether the member wants to be notified about finished generations, which is the default
+func (q *queueImpl) GetDMNotifications(memberID string) (bool, error) {
+	preference, err := q.notificationsRepo.GetByMemberID(context.Background(), memberID)
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return preference.DMEnabled, nil
+}
+
+func (q *queueImpl) UpdateDMNotifications(memberID string, enabled bool) error {
+	_, err := q.notificationsRepo.Upsert(context.Background(), &entities.NotificationPreference{
+		MemberID:  memberID,
+		DMEnabled: enabled,
+	})
+
+	return err
+}
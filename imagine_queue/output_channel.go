@@ -0,0 +1,33 @@
+package imagine_queue
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sendToOutputChannel posts the result to the item's output channel as a regular message and replaces
+// the interaction response, which is only seen by the requester, with a link to it
+func (q *queueImpl) sendToOutputChannel(imagine *QueueItem, content string, files []*discordgo.File, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	message, err := q.botSession.ChannelMessageSendComplex(imagine.OutputChannelID, &discordgo.MessageSend{
+		Content:    content,
+		Files:      files,
+		Components: components,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.moveGenerations(imagine, message)
+
+	doneContent := "Done: " + messageLink(imagine.DiscordInteraction.GuildID, message)
+
+	_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &doneContent,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,54 @@
+package imagine_queue
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/imageutil"
+)
+
+// generationParameters formats the generation like the webui infotext, so the saved image can be loaded
+// in the PNG Info tab. model is the "Model hash: ..., Model: ..." part of the API response
+func generationParameters(generation *entities.ImageGeneration, seed int, model string) string {
+	var builder strings.Builder
+
+	builder.WriteString(generation.Prompt)
+
+	if generation.NegativePrompt != "" {
+		builder.WriteString("\nNegative prompt: " + generation.NegativePrompt)
+	}
+
+	fmt.Fprintf(&builder, "\nSteps: %d, Sampler: %s, CFG scale: %v, Seed: %d, Size: %dx%d",
+		generation.Steps, generation.SamplerName, generation.CfgScale, seed, generation.Width, generation.Height)
+
+	if model = strings.Trim(model, ", "); model != "" {
+		builder.WriteString(", " + model)
+	}
+
+	if generation.EnableHR {
+		fmt.Fprintf(&builder, ", Denoising strength: %v", generation.DenoisingStrength)
+
+		if generation.HiresWidth > 0 && generation.HiresHeight > 0 {
+			fmt.Fprintf(&builder, ", Hires resize: %dx%d", generation.HiresWidth, generation.HiresHeight)
+		}
+	}
+
+	return builder.String()
+}
+
+// withParameters returns the image with the parameters embedded, or unchanged when it isn't a PNG
+func withParameters(image []byte, params string) []byte {
+	embedded, err := imageutil.EmbedPNGMetadata(image, params)
+	if err != nil {
+		if !errors.Is(err, imageutil.ErrNotPNG) {
+			log.Printf("Error embedding the generation parameters: %v", err)
+		}
+
+		return image
+	}
+
+	return embedded
+}
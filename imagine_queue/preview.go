@@ -0,0 +1,155 @@
+package imagine_queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Previews are small and quick, to check the composition before spending time on the full image
+const (
+	previewSize  = 128
+	previewSteps = 5
+)
+
+// processPreviewImagine generates a small image with the item's seed and keeps the item until
+// the user approves or discards it with the buttons on the preview
+func (q *queueImpl) processPreviewImagine(ctx context.Context, imagine *QueueItem) {
+	log.Printf("Processing preview #%s: %v\n", imagine.DiscordInteraction.ID, imagine.Options.Prompt)
+
+	promptRes, err := extractDimensionsFromPrompt(imagine.Options.Prompt, previewSize, previewSize)
+	if err != nil {
+		log.Printf("Error extracting dimensions from prompt: %v", err)
+
+		return
+	}
+
+	resp, err := q.stableDiffusionAPI.TextToImage(ctx, &stable_diffusion_api.TextToImageRequest{
+		Prompt:         promptRes.SanitizedPrompt,
+		NegativePrompt: imagine.Options.NegativePrompt,
+		Width:          previewSize,
+		Height:         previewSize,
+		BatchSize:      1,
+		Seed:           imagine.Options.Seed,
+		Subseed:        -1,
+		SamplerName:    q.canonicalSampler(imagine.Options.SamplerName),
+		CfgScale:       imagine.Options.CfgScale,
+		Steps:          previewSteps,
+		NIter:          1,
+		OverrideSettings: stable_diffusion_api.Txt2ImgOverrideSettings{
+			SamplesFormat: "webp",
+		},
+	})
+	if err != nil || len(resp.Images) == 0 {
+		log.Printf("Error processing preview: %v\n", err)
+		imagine.markInterrupted(err)
+
+		errorContent := "I'm sorry, but I had a problem imagining your preview."
+
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content: &errorContent,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v", err)
+		}
+
+		return
+	}
+
+	// The full image must reproduce the preview, so a random seed is fixed
+	if len(resp.Seeds) > 0 {
+		imagine.Options.Seed = resp.Seeds[0]
+	}
+
+	decodedImage, err := base64.StdEncoding.DecodeString(resp.Images[0])
+	if err != nil {
+		log.Printf("Error decoding image: %v\n", err)
+
+		return
+	}
+
+	content := fmt.Sprintf("<@%s> here is a preview of `%s`. Approve?",
+		imagine.DiscordInteraction.Member.User.ID, imagine.Options.Prompt)
+
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &content,
+		Files: []*discordgo.File{
+			{
+				ContentType: "image/png",
+				Name:        fmt.Sprintf("preview-seed-%d.png", imagine.Options.Seed),
+				Reader:      bytes.NewBuffer(decodedImage),
+			},
+		},
+		Components: &[]discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Yes",
+						Style:    discordgo.SuccessButton,
+						CustomID: "imagine_preview_yes",
+					},
+					discordgo.Button{
+						Label:    "No",
+						Style:    discordgo.SecondaryButton,
+						CustomID: "imagine_preview_no",
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v\n", err)
+
+		return
+	}
+
+	imagine.DiscordMessageID = message.ID
+
+	imagine.previewedAt = time.Now()
+
+	q.mu.Lock()
+	q.sweepPreviews()
+	q.previews[message.ID] = imagine
+	q.mu.Unlock()
+}
+
+// sweepPreviews forgets the previews nobody approved in time, q.mu must be held
+func (q *queueImpl) sweepPreviews() {
+	for messageID, item := range q.previews {
+		if time.Since(item.previewedAt) > interactionTokenLifetime {
+			delete(q.previews, messageID)
+		}
+	}
+}
+
+// TakePreview removes and returns the previewed item posted in the message, or nil if there is none or it expired
+func (q *queueImpl) TakePreview(messageID string) *QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sweepPreviews()
+
+	item, ok := q.previews[messageID]
+	if !ok {
+		return nil
+	}
+
+	delete(q.previews, messageID)
+
+	return item
+}
+
+// RestorePreview puts back a preview taken with TakePreview
+func (q *queueImpl) RestorePreview(item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.previews[item.DiscordMessageID] = item
+}
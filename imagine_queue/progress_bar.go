@@ -0,0 +1,28 @@
+package imagine_queue
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Number of blocks of the progress bar
+const progressBarWidth = 8
+
+// progressBar renders the progress in the 0-1 range as e.g. "[████░░░░] 47% — ETA 8s".
+// The ETA is left out while the API doesn't estimate it yet
+func progressBar(progress, etaSeconds float64) string {
+	progress = math.Max(0, math.Min(1, progress))
+
+	filled := int(math.Round(progress * progressBarWidth))
+
+	bar := fmt.Sprintf("[%s%s] %.0f%%",
+		strings.Repeat("█", filled), strings.Repeat("░", progressBarWidth-filled), progress*100)
+
+	if etaSeconds > 0 {
+		bar += " — ETA " + (time.Duration(etaSeconds * float64(time.Second))).Round(time.Second).String()
+	}
+
+	return bar
+}
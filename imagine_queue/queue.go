@@ -21,6 +21,8 @@ import (
 	"stable_diffusion_bot/repositories"
 	"stable_diffusion_bot/repositories/default_settings"
 	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/repositories/notification_preferences"
+	"stable_diffusion_bot/repositories/settings"
 	"stable_diffusion_bot/repositories/statistics"
 	"stable_diffusion_bot/stable_diffusion_api"
 
@@ -37,14 +39,31 @@ const (
 type queueImpl struct {
 	botSession          *discordgo.Session
 	stableDiffusionAPI  stable_diffusion_api.StableDiffusionAPI
-	queue               chan *QueueItem
-	currentImagine      *QueueItem
+	queue               []*QueueItem
+	inProgress          map[string]*QueueItem
+	waiters             map[string]chan QueueResult
+	previews            map[string]*QueueItem
 	mu                  sync.Mutex
+	workerCount         int
+	defaultModel        string
+	samplerAliases      map[string]string
+	samplerAliasesMu    sync.Mutex
+	errorChannelID      string
+	forumChannelID      string
+	progressInterval    time.Duration
+	rateLimitPerUser    int
+	livePreview         bool
+	forumMu             sync.Mutex
+	vramPressureTimer   *time.Timer
+	vramMu              sync.Mutex
 	imageGenerationRepo image_generations.Repository
 	compositeRenderer   composite_renderer.Renderer
 	defaultSettingsRepo default_settings.Repository
 	statisticsRepo      statistics.Repository
+	settingsRepo        settings.Repository
+	notificationsRepo   notification_preferences.Repository
 	botDefaultSettings  *entities.DefaultSettings
+	defaultSettingsMu   sync.RWMutex
 }
 
 type Config struct {
@@ -52,8 +71,29 @@ type Config struct {
 	ImageGenerationRepo image_generations.Repository
 	DefaultSettingsRepo default_settings.Repository
 	StatisticsRepo      statistics.Repository
+	SettingsRepo        settings.Repository
+	NotificationsRepo   notification_preferences.Repository
+	// Number of items processed in parallel, e.g. one per GPU. Defaults to 1
+	WorkerCount int
+	// Checkpoint loaded when the queue starts, the current model is kept when empty
+	DefaultModel string
+	// Channel for operational warnings such as running out of VRAM, they are only logged when empty
+	ErrorChannelID string
+	// Forum channel where every finished generation is also posted, with the model and sampler as tags.
+	// Other channel types get a regular message
+	ForumChannelID string
+	// Interval of the progress updates of the response message, DefaultProgressInterval when 0.
+	// Every update is a Discord API request, so keep it above a second
+	ProgressInterval time.Duration
+	// Maximum number of waiting and processing items per user for adding new imagine items, 0 = unlimited
+	RateLimitPerUser int
+	// Post the current denoising step of imagine generations as a follow-up message, refreshed with the progress.
+	// Every refresh downloads the image from the API and reposts it on Discord
+	LivePreview bool
 }
 
+const DefaultProgressInterval = 2 * time.Second
+
 func New(cfg Config) (Queue, error) {
 	if cfg.StableDiffusionAPI == nil {
 		return nil, errors.New("missing stable diffusion API")
@@ -71,18 +111,48 @@ func New(cfg Config) (Queue, error) {
 		return nil, errors.New("missing default statistics repository")
 	}
 
+	if cfg.SettingsRepo == nil {
+		return nil, errors.New("missing settings repository")
+	}
+
+	if cfg.NotificationsRepo == nil {
+		return nil, errors.New("missing notification preferences repository")
+	}
+
 	compositeRenderer, err := composite_renderer.New(composite_renderer.Config{})
 	if err != nil {
 		return nil, err
 	}
 
+	workerCount := cfg.WorkerCount
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	progressInterval := cfg.ProgressInterval
+	if progressInterval <= 0 {
+		progressInterval = DefaultProgressInterval
+	}
+
 	return &queueImpl{
 		stableDiffusionAPI:  cfg.StableDiffusionAPI,
 		imageGenerationRepo: cfg.ImageGenerationRepo,
-		queue:               make(chan *QueueItem, 100),
+		queue:               make([]*QueueItem, 0),
+		inProgress:          make(map[string]*QueueItem),
+		waiters:             make(map[string]chan QueueResult),
+		previews:            make(map[string]*QueueItem),
+		workerCount:         workerCount,
+		defaultModel:        cfg.DefaultModel,
+		errorChannelID:      cfg.ErrorChannelID,
+		forumChannelID:      cfg.ForumChannelID,
+		progressInterval:    progressInterval,
+		rateLimitPerUser:    cfg.RateLimitPerUser,
+		livePreview:         cfg.LivePreview,
 		compositeRenderer:   compositeRenderer,
 		defaultSettingsRepo: cfg.DefaultSettingsRepo,
 		statisticsRepo:      cfg.StatisticsRepo,
+		settingsRepo:        cfg.SettingsRepo,
+		notificationsRepo:   cfg.NotificationsRepo,
 	}, nil
 }
 
@@ -93,6 +163,15 @@ const (
 	ItemTypeReroll
 	ItemTypeUpscale
 	ItemTypeVariation
+	ItemTypeRaw
+	// Upscales all four images of a grid sequentially
+	ItemTypeBatchUpscale
+	// Small quick image waiting for approval before the full generation
+	ItemTypePreview
+	// CLIP caption of InterrogateImage
+	ItemTypeInterrogate
+	// Image generated from the image at InitImageURL
+	ItemTypeImg2Img
 )
 
 type QueueItemOptions struct {
@@ -109,6 +188,30 @@ type QueueItemOptions struct {
 	CfgScale          float64
 	Steps             int
 	Seed              int
+	// CodeFormer fidelity weight, applied only with RestoreFaces. Nil keeps the server option
+	CodeFormerWeight *float64
+	// Initial noise multiplier, 0 keeps the server default of 1.0
+	InitialNoiseMultiplier float64
+	// Extension script arguments. They aren't persisted, so the result has no buttons generating it again
+	AlwaysonScripts map[string]interface{}
+	// Control image of a ControlNet unit, downloaded by the queue processor. Not persisted either
+	ControlNetImageURL string
+	// ControlNet model applied to ControlNetImageURL
+	ControlNetModel string
+	// Hires fix at the default scale even when the size doesn't need it, DenoisingStrength applies to its second pass
+	HiresFix bool
+	// Steps of the hires second pass and its upscaler, the server defaults when empty. Not persisted
+	HiresSteps    int
+	HiresUpscaler string
+	// CLIP layers skipped from the end, 0 keeps the server option. Not persisted
+	ClipSkip int
+	// Seamlessly tileable images, not persisted either
+	Tiling bool
+	// LoRA network added to the prompt with LoRAWeight, none when empty
+	LoRAName   string
+	LoRAWeight float64
+	// Sends the img2img source image as is instead of resizing it to the generation size first. Not persisted
+	SkipResize bool
 }
 
 func NewQueueItemOptions() QueueItemOptions {
@@ -121,6 +224,7 @@ func NewQueueItemOptions() QueueItemOptions {
 		CfgScale:          DefaultCFGScale,
 		Steps:             DefaultSteps,
 		Seed:              DefaultSeed,
+		LoRAWeight:        DefaultLoRAWeight,
 	}
 }
 
@@ -131,16 +235,233 @@ type QueueItem struct {
 	Type               ItemType
 	InteractionIndex   int
 	DiscordInteraction *discordgo.Interaction
+	// ID of the bot's response message, populated by the queue processor once it starts processing the item
+	DiscordMessageID string
+	// Request sent to the API as is, used by ItemTypeRaw
+	RawRequest *stable_diffusion_api.TextToImageRequest
+	// Base64 encoded image to caption, used by ItemTypeInterrogate
+	InterrogateImage string
+	// Caption of InterrogateImage, populated by the queue processor
+	Caption string
+	// Source image of ItemTypeImg2Img, downloaded by the queue processor
+	InitImageURL string
+	// Position in line at the moment the item was queued
+	QueuePosition int
+	// Token for editing the response and sending follow-up messages, valid for interactionTokenLifetime
+	InteractionToken string
+	// Time the item was added to the queue
+	CreatedAt time.Time
+	// Time a worker picked the item up
+	StartedAt time.Time
+	// Set once the image of an ItemTypeUpscale item is upscaled, its upscale button is disabled then
+	IsUpscaled bool
+	// Channel to post the result to instead of the interaction response, which only shows the progress then
+	OutputChannelID string
+	// Message of the generation a reroll or variation is based on
+	SourceMessageID string
+	// Message of the first generation of the lineage, set by the queue from the source generation
+	RootMessageID string
+	// Set when the prompt matches the daily prompt challenge, the requester earns challengeBonusPoints
+	ChallengeEntry bool
+	// Set when an API request of the item was aborted by the shutdown or Interrupt
+	interrupted bool
+	// Aborts the API requests of the item while it's processed, guarded by the queue mutex
+	cancel context.CancelFunc
+	// Channel message showing the status once the interaction token has expired
+	fallbackMessageID string
+	// Time the preview of an ItemTypePreview item was posted, it is forgotten after interactionTokenLifetime
+	previewedAt time.Time
+}
+
+// markInterrupted records whether the API request failed because the queue is shutting down or was interrupted
+func (item *QueueItem) markInterrupted(err error) {
+	if errors.Is(err, context.Canceled) {
+		item.interrupted = true
+	}
+}
+
+// Statistics bonus points for an entry of the daily prompt challenge
+const challengeBonusPoints = 10
+
+// bonusPoints returns the statistics bonus points the item earns
+func (item *QueueItem) bonusPoints() int64 {
+	if item.ChallengeEntry {
+		return challengeBonusPoints
+	}
+
+	return 0
+}
+
+// Discord invalidates interaction tokens after 15 minutes
+const interactionTokenLifetime = 15 * time.Minute
+
+// interactionExpired reports whether the interaction token can no longer be used to post the result
+func (item *QueueItem) interactionExpired() bool {
+	createdAt, err := discordgo.SnowflakeTimestamp(item.DiscordInteraction.ID)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(createdAt) > interactionTokenLifetime
+}
+
+// queueWait returns how long the item waited in line before a worker picked it up
+func (item *QueueItem) queueWait() time.Duration {
+	return item.StartedAt.Sub(item.CreatedAt)
+}
+
+// followupInteraction returns the interaction for posting follow-up messages with the stored token
+func (item *QueueItem) followupInteraction() *discordgo.Interaction {
+	return &discordgo.Interaction{
+		AppID: item.DiscordInteraction.AppID,
+		Token: item.InteractionToken,
+	}
 }
 
 func (q *queueImpl) AddImagine(item *QueueItem) (int, error) {
-	q.queue <- item
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Upscale buttons on finished generations keep working, everything else counts against the limit
+	if q.rateLimitPerUser > 0 && item.Type != ItemTypeUpscale && item.Type != ItemTypeBatchUpscale {
+		pending := q.pendingItemsOfUser(itemUserID(item))
+		if pending >= q.rateLimitPerUser {
+			return 0, &RateLimitError{Pending: pending, Limit: q.rateLimitPerUser}
+		}
+	}
+
+	q.queue = append(q.queue, item)
 
 	linePosition := len(q.queue)
+	item.QueuePosition = linePosition
+	item.CreatedAt = time.Now()
+
+	if item.InteractionToken == "" {
+		item.InteractionToken = item.DiscordInteraction.Token
+	}
 
 	return linePosition, nil
 }
 
+// itemUserID returns the ID of the user who queued the item, in guilds and in DMs
+func itemUserID(item *QueueItem) string {
+	if item.DiscordInteraction == nil {
+		return ""
+	}
+
+	if item.DiscordInteraction.Member != nil && item.DiscordInteraction.Member.User != nil {
+		return item.DiscordInteraction.Member.User.ID
+	}
+
+	if item.DiscordInteraction.User != nil {
+		return item.DiscordInteraction.User.ID
+	}
+
+	return ""
+}
+
+// pendingItemsOfUser counts the waiting and processing items of the user, q.mu must be held
+func (q *queueImpl) pendingItemsOfUser(userID string) int {
+	pending := 0
+
+	for _, item := range q.queue {
+		if itemUserID(item) == userID {
+			pending++
+		}
+	}
+
+	for _, item := range q.inProgress {
+		if itemUserID(item) == userID {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+// GetQueuePosition returns the 1-based position of the item with the given interaction ID
+// among the waiting items, or 0 if it is not waiting
+func (q *queueImpl) GetQueuePosition(interactionID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for idx, item := range q.queue {
+		if item.DiscordInteraction.ID == interactionID {
+			return idx + 1
+		}
+	}
+
+	return 0
+}
+
+// GetWaitingItems returns a copy of the items waiting in line, in order
+func (q *queueImpl) GetWaitingItems() []*QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]*QueueItem, len(q.queue))
+	copy(items, q.queue)
+
+	return items
+}
+
+// GetActiveItems returns the items currently processed by the workers
+func (q *queueImpl) GetActiveItems() []*QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]*QueueItem, 0, len(q.inProgress))
+	for _, item := range q.inProgress {
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// Flush removes all waiting items and returns their number. Items being processed are not affected
+func (q *queueImpl) Flush() (int, error) {
+	q.mu.Lock()
+
+	flushed := q.queue
+	q.queue = make([]*QueueItem, 0)
+
+	for _, item := range flushed {
+		if waiter, ok := q.waiters[item.DiscordInteraction.ID]; ok {
+			waiter <- QueueResult{InteractionID: item.DiscordInteraction.ID}
+
+			delete(q.waiters, item.DiscordInteraction.ID)
+		}
+	}
+
+	q.mu.Unlock()
+
+	go q.notifyFlushed(flushed)
+
+	return len(flushed), nil
+}
+
+// notifyFlushed replaces the "in line" responses of the flushed items
+func (q *queueImpl) notifyFlushed(items []*QueueItem) {
+	if q.botSession == nil {
+		return
+	}
+
+	content := "This request was removed from the queue by an admin."
+
+	for _, item := range items {
+		if item.interactionExpired() {
+			continue
+		}
+
+		_, err := q.botSession.InteractionResponseEdit(item.DiscordInteraction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v", err)
+		}
+	}
+}
+
 func (q *queueImpl) StartPolling(botSession *discordgo.Session) {
 	q.botSession = botSession
 
@@ -151,43 +472,216 @@ func (q *queueImpl) StartPolling(botSession *discordgo.Session) {
 		return
 	}
 
-	q.botDefaultSettings = botDefaultSettings
+	q.setBotDefaultSettings(botDefaultSettings)
+
+	// Cancelled on shutdown, which aborts the API requests in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q.loadDefaultModel(ctx)
 
 	log.Println("Press Ctrl+C to exit")
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
 
-	stopPolling := false
+	var workers sync.WaitGroup
+
+	for worker := 1; worker <= q.workerCount; worker++ {
+		workers.Add(1)
+
+		go func(worker int) {
+			defer workers.Done()
+
+			q.runWorker(ctx, worker)
+		}(worker)
+	}
+
+	<-stop
+	cancel()
+
+	// The workers tell the requesters about their cancelled items before the bot disconnects
+	workers.Wait()
+
+	log.Printf("Polling stopped...\n")
+}
+
+// Model switching can take a while, the queue starts anyway after this time
+const modelSwitchTimeout = 60 * time.Second
+
+// loadDefaultModel switches the server to the default model if another one is loaded
+func (q *queueImpl) loadDefaultModel(ctx context.Context) {
+	if q.defaultModel == "" {
+		return
+	}
+
+	currentModel, err := q.stableDiffusionAPI.GetCurrentModel(ctx)
+	if err != nil {
+		log.Printf("Error getting current model: %v", err)
+
+		return
+	}
+
+	// The server reports the checkpoint title, which starts with its name
+	if strings.HasPrefix(currentModel, q.defaultModel) {
+		return
+	}
+
+	log.Printf("Switching model from '%s' to '%s'...", currentModel, q.defaultModel)
+
+	switched := make(chan error, 1)
+
+	go func() {
+		switched <- q.stableDiffusionAPI.SetModel(ctx, q.defaultModel)
+	}()
+
+	select {
+	case err = <-switched:
+		if err != nil {
+			log.Printf("Error switching model: %v", err)
+		} else {
+			log.Printf("Model '%s' is loaded", q.defaultModel)
+		}
+	case <-time.After(modelSwitchTimeout):
+		log.Printf("Warning: model switch is taking more than %s, starting the queue anyway", modelSwitchTimeout)
+	}
+}
+
+func (q *queueImpl) runWorker(ctx context.Context, worker int) {
+	log.Printf("Starting queue worker #%d", worker)
 
 	for {
 		select {
-		case <-stop:
-			stopPolling = true
+		case <-ctx.Done():
+			return
 		case <-time.After(1 * time.Second):
-			if q.currentImagine == nil {
-				q.pullNextInQueue()
+			for item := q.pullNextInQueue(); item != nil; item = q.pullNextInQueue() {
+				itemCtx, cancelItem := context.WithCancel(ctx)
+
+				q.mu.Lock()
+				item.cancel = cancelItem
+				q.mu.Unlock()
+
+				q.processImagine(itemCtx, item)
+
+				cancelItem()
+
+				if item.interrupted && ctx.Err() != nil {
+					q.notifyCancelled(item, cancelledContent)
+				} else if item.interrupted {
+					q.notifyCancelled(item, interruptedContent)
+				}
+
+				q.finishItem(item)
+
+				if ctx.Err() != nil {
+					return
+				}
 			}
 		}
+	}
+}
 
-		if stopPolling {
-			break
+const (
+	cancelledContent   = "Generation cancelled — bot is shutting down."
+	interruptedContent = "Generation interrupted by a moderator."
+)
+
+// notifyCancelled replaces the response of an item interrupted by the shutdown or Interrupt
+func (q *queueImpl) notifyCancelled(item *QueueItem, content string) {
+	_, err := q.editResponse(item, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	}
+}
+
+// pullNextInQueue moves the first waiting item to the in-progress set, returns nil when the queue is empty
+func (q *queueImpl) pullNextInQueue() *QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.queue) == 0 {
+		return nil
+	}
+
+	element := q.queue[0]
+	q.queue = q.queue[1:]
+
+	element.StartedAt = time.Now()
+	q.inProgress[element.DiscordInteraction.ID] = element
+
+	return element
+}
+
+func (q *queueImpl) finishItem(item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inProgress, item.DiscordInteraction.ID)
+
+	if waiter, ok := q.waiters[item.DiscordInteraction.ID]; ok {
+		waiter <- QueueResult{
+			InteractionID: item.DiscordInteraction.ID,
+			MessageID:     item.DiscordMessageID,
+			Caption:       item.Caption,
 		}
+
+		delete(q.waiters, item.DiscordInteraction.ID)
 	}
+}
 
-	log.Printf("Polling stopped...\n")
+type QueueResult struct {
+	InteractionID string
+	// ID of the message with the result, empty if the bot failed to respond
+	MessageID string
+	// Caption of an ItemTypeInterrogate item, empty for other types or if interrogation failed
+	Caption string
 }
 
-func (q *queueImpl) pullNextInQueue() {
-	if len(q.queue) > 0 {
-		element := <-q.queue
+// WaitForItem blocks until the item with the given interaction ID is processed.
+// Only one caller can wait for an item at a time
+func (q *queueImpl) WaitForItem(ctx context.Context, itemID string) (*QueueResult, error) {
+	q.mu.Lock()
 
-		q.mu.Lock()
-		defer q.mu.Unlock()
+	_, inProgress := q.inProgress[itemID]
+	waiting := false
+
+	for _, item := range q.queue {
+		if item.DiscordInteraction.ID == itemID {
+			waiting = true
+
+			break
+		}
+	}
 
-		q.currentImagine = element
+	if !inProgress && !waiting {
+		q.mu.Unlock()
 
-		q.processCurrentImagine()
+		return nil, fmt.Errorf("item %s is not in the queue", itemID)
+	}
+
+	if _, ok := q.waiters[itemID]; ok {
+		q.mu.Unlock()
+
+		return nil, fmt.Errorf("item %s is already awaited", itemID)
+	}
+
+	waiter := make(chan QueueResult, 1)
+	q.waiters[itemID] = waiter
+
+	q.mu.Unlock()
+
+	select {
+	case result := <-waiter:
+		return &result, nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		delete(q.waiters, itemID)
+		q.mu.Unlock()
+
+		return nil, ctx.Err()
 	}
 }
 
@@ -215,19 +709,34 @@ func (q *queueImpl) initializeOrGetBotDefaults() (*entities.DefaultSettings, err
 	return botDefaultSettings, nil
 }
 
+// getBotDefaultSettings returns a copy of the cached bot defaults, loading them on first use.
+// The workers read the cache while commands update it, so the cached struct is replaced, never modified
 func (q *queueImpl) getBotDefaultSettings() (*entities.DefaultSettings, error) {
-	if q.botDefaultSettings != nil {
-		return q.botDefaultSettings, nil
-	}
+	q.defaultSettingsMu.RLock()
+	cached := q.botDefaultSettings
+	q.defaultSettingsMu.RUnlock()
 
-	defaultSettings, err := q.defaultSettingsRepo.GetByMemberID(context.Background(), botID)
-	if err != nil {
-		return nil, err
+	if cached == nil {
+		defaultSettings, err := q.defaultSettingsRepo.GetByMemberID(context.Background(), botID)
+		if err != nil {
+			return nil, err
+		}
+
+		q.setBotDefaultSettings(defaultSettings)
+
+		cached = defaultSettings
 	}
 
-	q.botDefaultSettings = defaultSettings
+	settingsCopy := *cached
 
-	return defaultSettings, nil
+	return &settingsCopy, nil
+}
+
+func (q *queueImpl) setBotDefaultSettings(defaultSettings *entities.DefaultSettings) {
+	q.defaultSettingsMu.Lock()
+	defer q.defaultSettingsMu.Unlock()
+
+	q.botDefaultSettings = defaultSettings
 }
 
 func (q *queueImpl) defaultWidth() (int, error) {
@@ -262,21 +771,162 @@ func (q *queueImpl) UpdateDefaultDimensions(width, height int) error {
 		return err
 	}
 
-	defaultSettings.Width = width
-	defaultSettings.Height = height
-
-	newDefaultSettings, err := q.defaultSettingsRepo.Upsert(context.Background(), defaultSettings)
+	newDefaultSettings, err := q.defaultSettingsRepo.Upsert(context.Background(), &entities.DefaultSettings{
+		MemberID: defaultSettings.MemberID,
+		Width:    width,
+		Height:   height,
+	})
 	if err != nil {
 		return err
 	}
 
-	q.botDefaultSettings = newDefaultSettings
+	q.setBotDefaultSettings(newDefaultSettings)
 
 	log.Printf("Updated default dimensions to: %dx%d\n", width, height)
 
 	return nil
 }
 
+func (q *queueImpl) GetDefaultSampler(guildID, memberID string) (string, error) {
+	sampler, ok, err := settings.GetSampler(context.Background(), q.settingsRepo, guildID, memberID)
+	if err != nil || !ok {
+		return DefaultSampler, err
+	}
+
+	return sampler, nil
+}
+
+func (q *queueImpl) GetDefaultCFGScale(guildID, memberID string) (float64, error) {
+	cfgScale, ok, err := settings.GetCFGScale(context.Background(), q.settingsRepo, guildID, memberID)
+	if err != nil || !ok {
+		return DefaultCFGScale, err
+	}
+
+	return cfgScale, nil
+}
+
+func (q *queueImpl) GetDefaultSteps(guildID, memberID string) (int, error) {
+	steps, ok, err := settings.GetSteps(context.Background(), q.settingsRepo, guildID, memberID)
+	if err != nil || !ok {
+		return DefaultSteps, err
+	}
+
+	return steps, nil
+}
+
+func (q *queueImpl) GetDefaultNegativePrompt(guildID, memberID string) (string, error) {
+	negativePrompt, ok, err := settings.GetNegativePrompt(context.Background(), q.settingsRepo, guildID, memberID)
+	if err != nil || !ok {
+		return DefaultNegative, err
+	}
+
+	return negativePrompt, nil
+}
+
+// GetDefaultTokenMergingRatio returns the guild's ToMe ratio, or nil when the server option is used
+func (q *queueImpl) GetDefaultTokenMergingRatio(guildID string) (*float64, error) {
+	ratio, ok, err := settings.GetTokenMergingRatio(context.Background(), q.settingsRepo, guildID)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return &ratio, nil
+}
+
+// GetDefaultClipSkip returns 0 when no default is set, the server option is used then
+func (q *queueImpl) GetDefaultClipSkip(guildID, memberID string) (int, error) {
+	clipSkip, _, err := settings.GetClipSkip(context.Background(), q.settingsRepo, guildID, memberID)
+
+	return clipSkip, err
+}
+
+// NewMemberQueueItemOptions returns queue item options with the member's and guild's overrides applied
+func (q *queueImpl) NewMemberQueueItemOptions(guildID, memberID string) QueueItemOptions {
+	options := NewQueueItemOptions()
+
+	sampler, err := q.GetDefaultSampler(guildID, memberID)
+	if err != nil {
+		log.Printf("Error getting default sampler: %v", err)
+	} else {
+		options.SamplerName = sampler
+	}
+
+	cfgScale, err := q.GetDefaultCFGScale(guildID, memberID)
+	if err != nil {
+		log.Printf("Error getting default CFG scale: %v", err)
+	} else {
+		options.CfgScale = cfgScale
+	}
+
+	steps, err := q.GetDefaultSteps(guildID, memberID)
+	if err != nil {
+		log.Printf("Error getting default steps: %v", err)
+	} else {
+		options.Steps = steps
+	}
+
+	negativePrompt, err := q.GetDefaultNegativePrompt(guildID, memberID)
+	if err != nil {
+		log.Printf("Error getting default negative prompt: %v", err)
+	} else {
+		options.NegativePrompt = negativePrompt
+	}
+
+	clipSkip, err := q.GetDefaultClipSkip(guildID, memberID)
+	if err != nil {
+		log.Printf("Error getting default CLIP skip: %v", err)
+	} else {
+		options.ClipSkip = clipSkip
+	}
+
+	return options
+}
+
+func (q *queueImpl) GetMemberSettings(guildID, memberID string) ([]*entities.UserSetting, error) {
+	return q.settingsRepo.GetByMember(context.Background(), guildID, memberID)
+}
+
+func (q *queueImpl) UpdateMemberSetting(guildID, memberID, key, value string) error {
+	_, err := q.settingsRepo.Upsert(context.Background(), &entities.UserSetting{
+		GuildID:  guildID,
+		MemberID: memberID,
+		Key:      key,
+		Value:    value,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Updated setting %s for member %s: %s\n", key, memberID, value)
+
+	return nil
+}
+
+func (q *queueImpl) ResetMemberSettings(guildID, memberID string) error {
+	return q.settingsRepo.DeleteByMember(context.Background(), guildID, memberID)
+}
+
+// GetGuildSettings returns the guild-wide settings, stored under the bot member ID
+func (q *queueImpl) GetGuildSettings(guildID string) ([]*entities.UserSetting, error) {
+	return q.GetMemberSettings(guildID, settings.GuildMemberID)
+}
+
+func (q *queueImpl) UpdateGuildSetting(guildID, key, value string) error {
+	return q.UpdateMemberSetting(guildID, settings.GuildMemberID, key, value)
+}
+
+// tokenMergingOverride returns the guild's ToMe ratio to override per request, or nil to keep the server option
+func (q *queueImpl) tokenMergingOverride(guildID string) *float64 {
+	ratio, err := q.GetDefaultTokenMergingRatio(guildID)
+	if err != nil {
+		log.Printf("Error getting default token merging ratio: %v", err)
+
+		return nil
+	}
+
+	return ratio
+}
+
 type dimensionsResult struct {
 	SanitizedPrompt string
 	Width           int
@@ -292,7 +942,28 @@ func fixEmDash(prompt string) string {
 	return strings.ReplaceAll(prompt, string(emdash), string(hyphen)+string(hyphen))
 }
 
-var arRegex = regexp.MustCompile(`\s?--ar ([\d]*):([\d]*)\s?`)
+// Fractional ratios are allowed for cinematic formats, e.g. --ar 2.35:1
+var arRegex = regexp.MustCompile(`\s?--ar (\d+(?:\.\d+)?):(\d+(?:\.\d+)?)\s?`)
+
+// HasAspectRatioFlag reports whether the prompt sets the aspect ratio with --ar W:H
+func HasAspectRatioFlag(prompt string) bool {
+	return arRegex.MatchString(fixEmDash(prompt))
+}
+
+// ItemDimensions returns the size the item is generated at: its custom dimensions, or the default ones
+// adjusted by the --ar flag of the prompt
+func ItemDimensions(item *QueueItem, defaultWidth, defaultHeight int) (int, int) {
+	if item.Options.Width > 0 && item.Options.Height > 0 {
+		return item.Options.Width, item.Options.Height
+	}
+
+	promptRes, err := extractDimensionsFromPrompt(item.Prompt, defaultWidth, defaultHeight)
+	if err != nil {
+		return defaultWidth, defaultHeight
+	}
+
+	return promptRes.Width, promptRes.Height
+}
 
 func extractDimensionsFromPrompt(prompt string, width, height int) (*dimensionsResult, error) {
 	// Sanitize em dashes. Some phones will autocorrect to em dashes
@@ -305,23 +976,27 @@ func extractDimensionsFromPrompt(prompt string, width, height int) (*dimensionsR
 
 		prompt = arRegex.ReplaceAllString(prompt, "")
 
-		firstDimension, err := strconv.Atoi(arMatches[1])
+		firstDimension, err := strconv.ParseFloat(arMatches[1], 64)
 		if err != nil {
 			return nil, err
 		}
 
-		secondDimension, err := strconv.Atoi(arMatches[2])
+		secondDimension, err := strconv.ParseFloat(arMatches[2], 64)
 		if err != nil {
 			return nil, err
 		}
 
+		if firstDimension == 0 || secondDimension == 0 {
+			return nil, fmt.Errorf("invalid aspect ratio %s:%s", arMatches[1], arMatches[2])
+		}
+
 		if firstDimension > secondDimension {
-			scaledWidth := float64(height) * (float64(firstDimension) / float64(secondDimension))
+			scaledWidth := float64(height) * (firstDimension / secondDimension)
 
 			// Round up to the nearest 8
 			width = (int(scaledWidth) + 7) & (-8)
 		} else if secondDimension > firstDimension {
-			scaledHeight := float64(width) * (float64(secondDimension) / float64(firstDimension))
+			scaledHeight := float64(width) * (secondDimension / firstDimension)
 
 			// Round up to the nearest 8
 			height = (int(scaledHeight) + 7) & (-8)
@@ -348,107 +1023,164 @@ const (
 	DefaultSteps        = 20
 	DefaultSeed         = -1
 	DefaultHiRes        = true
+	DefaultLoRAWeight   = 1.0
 )
 
-func (q *queueImpl) processCurrentImagine() {
-	go func() {
-		defer func() {
-			q.mu.Lock()
-			defer q.mu.Unlock()
+func (q *queueImpl) processImagine(ctx context.Context, imagine *QueueItem) {
+	if imagine.interactionExpired() {
+		log.Printf("Interaction token of imagine #%s has expired, posting to the channel", imagine.DiscordInteraction.ID)
+	}
 
-			q.currentImagine = nil
-		}()
+	if imagine.Type == ItemTypeUpscale {
+		q.processUpscaleImagine(ctx, imagine)
 
-		if q.currentImagine.Type == ItemTypeUpscale {
-			q.processUpscaleImagine(q.currentImagine)
+		return
+	}
 
-			return
-		}
+	if imagine.Type == ItemTypeRaw {
+		q.processRawImagine(ctx, imagine)
 
-		defaultWidth, err := q.defaultWidth()
-		if err != nil {
-			log.Printf("Error getting default width: %v", err)
+		return
+	}
 
-			return
-		}
+	if imagine.Type == ItemTypeBatchUpscale {
+		q.processBatchUpscaleImagine(ctx, imagine)
 
-		defaultHeight, err := q.defaultHeight()
-		if err != nil {
-			log.Printf("Error getting default height: %v", err)
+		return
+	}
 
-			return
-		}
+	if imagine.Type == ItemTypePreview {
+		q.processPreviewImagine(ctx, imagine)
 
-		promptRes, err := extractDimensionsFromPrompt(q.currentImagine.Prompt, defaultWidth, defaultHeight)
-		if err != nil {
-			log.Printf("Error extracting dimensions from prompt: %v", err)
+		return
+	}
 
-			return
-		}
+	if imagine.Type == ItemTypeInterrogate {
+		q.processInterrogateImagine(ctx, imagine)
 
-		enableHR := false
-		hiresWidth := 0
-		hiresHeight := 0
+		return
+	}
 
-		if promptRes.Width > defaultWidth || promptRes.Height > defaultHeight {
-			enableHR = true
-			hiresWidth = promptRes.Width
-			hiresHeight = promptRes.Height
-		}
+	if imagine.Type == ItemTypeImg2Img {
+		q.processImg2ImgImagine(ctx, imagine)
+
+		return
+	}
+
+	defaultWidth, err := q.defaultWidth()
+	if err != nil {
+		log.Printf("Error getting default width: %v", err)
+
+		return
+	}
+
+	defaultHeight, err := q.defaultHeight()
+	if err != nil {
+		log.Printf("Error getting default height: %v", err)
+
+		return
+	}
+
+	promptRes, err := extractDimensionsFromPrompt(imagine.Prompt, defaultWidth, defaultHeight)
+	if err != nil {
+		log.Printf("Error extracting dimensions from prompt: %v", err)
+
+		return
+	}
+
+	baseWidth, baseHeight := defaultWidth, defaultHeight
 
-		// new generation with defaults
-		newGeneration := &entities.ImageGeneration{
-			Prompt:            promptRes.SanitizedPrompt,
-			NegativePrompt:    q.currentImagine.Options.NegativePrompt,
-			Width:             defaultWidth,
-			Height:            defaultHeight,
-			RestoreFaces:      q.currentImagine.Options.RestoreFaces,
-			EnableHR:          enableHR,
-			HiresWidth:        hiresWidth,
-			HiresHeight:       hiresHeight,
-			DenoisingStrength: q.currentImagine.Options.DenoisingStrength,
-			BatchSize:         1,
-			Seed:              q.currentImagine.Options.Seed,
-			Subseed:           -1,
-			SubseedStrength:   0,
-			SamplerName:       q.currentImagine.Options.SamplerName,
-			CfgScale:          q.currentImagine.Options.CfgScale,
-			Steps:             q.currentImagine.Options.Steps,
-			Processed:         false,
+	// Custom dimensions take precedence over the aspect ratio flag. Sizes within the default ones are
+	// generated directly, larger ones with hires fix like the aspect ratios
+	if imagine.Options.Width > 0 && imagine.Options.Height > 0 {
+		promptRes.Width = imagine.Options.Width
+		promptRes.Height = imagine.Options.Height
+
+		if promptRes.Width <= defaultWidth && promptRes.Height <= defaultHeight {
+			baseWidth, baseHeight = promptRes.Width, promptRes.Height
 		}
+	}
 
-		if q.currentImagine.Type == ItemTypeReroll || q.currentImagine.Type == ItemTypeVariation {
-			foundGeneration, err := q.getPreviousGeneration(q.currentImagine, q.currentImagine.InteractionIndex)
-			if err != nil {
-				log.Printf("Error getting prompt for reroll: %v", err)
+	enableHR := false
+	hiresWidth := 0
+	hiresHeight := 0
 
-				return
-			}
+	if imagine.Options.HiresFix {
+		enableHR = true
+	}
+
+	if promptRes.Width > defaultWidth || promptRes.Height > defaultHeight {
+		enableHR = true
+		hiresWidth = promptRes.Width
+		hiresHeight = promptRes.Height
+	}
 
-			// if we are rerolling, or generating variations, we simply replace some defaults
-			newGeneration = foundGeneration
+	// new generation with defaults
+	newGeneration := &entities.ImageGeneration{
+		Prompt:            promptRes.SanitizedPrompt,
+		NegativePrompt:    imagine.Options.NegativePrompt,
+		Width:             baseWidth,
+		Height:            baseHeight,
+		RestoreFaces:      imagine.Options.RestoreFaces,
+		EnableHR:          enableHR,
+		HiresWidth:        hiresWidth,
+		HiresHeight:       hiresHeight,
+		DenoisingStrength: imagine.Options.DenoisingStrength,
+		BatchSize:         1,
+		Seed:              imagine.Options.Seed,
+		Subseed:           -1,
+		SubseedStrength:   0,
+		SamplerName:       imagine.Options.SamplerName,
+		CfgScale:          imagine.Options.CfgScale,
+		Steps:             imagine.Options.Steps,
+		Processed:         false,
+	}
 
-			// for variations, we need random subseeds
-			newGeneration.Subseed = -1
+	// The tag stays in the stored prompt, so rerolls and variations keep the LoRA
+	if imagine.Options.LoRAName != "" {
+		newGeneration.Prompt += fmt.Sprintf(" <lora:%s:%v>", imagine.Options.LoRAName, imagine.Options.LoRAWeight)
+	}
 
-			// for reroll, we need random seed
-			if q.currentImagine.Type == ItemTypeReroll {
-				newGeneration.Seed = -1
-			}
+	if imagine.Type == ItemTypeReroll || imagine.Type == ItemTypeVariation {
+		foundGeneration, err := q.getPreviousGeneration(imagine, imagine.InteractionIndex)
+		if err != nil {
+			log.Printf("Error getting prompt for reroll: %v", err)
+
+			return
+		}
+
+		// if we are rerolling, or generating variations, we simply replace some defaults
+		newGeneration = foundGeneration
+
+		// for variations, we need random subseeds
+		newGeneration.Subseed = -1
 
-			// for variations, the subseed strength determines how much variation we get
-			if q.currentImagine.Type == ItemTypeVariation {
-				newGeneration.SubseedStrength = 0.15
-			}
+		// for reroll, we need random seed
+		if imagine.Type == ItemTypeReroll {
+			newGeneration.Seed = -1
 		}
 
-		err = q.processImagineGrid(newGeneration, q.currentImagine)
-		if err != nil {
-			log.Printf("Error processing imagine grid: %v", err)
+		// for variations, the subseed strength determines how much variation we get
+		if imagine.Type == ItemTypeVariation {
+			newGeneration.SubseedStrength = 0.15
+		}
 
-			return
+		// the source is the root of the lineage unless it was rerolled or varied itself
+		imagine.RootMessageID = foundGeneration.RootMessageID
+		if imagine.RootMessageID == "" {
+			imagine.RootMessageID = imagine.SourceMessageID
 		}
-	}()
+
+		newGeneration.SourceMessageID = imagine.SourceMessageID
+		newGeneration.RootMessageID = imagine.RootMessageID
+	}
+
+	err = q.processImagineGrid(ctx, newGeneration, imagine)
+	if err != nil {
+		log.Printf("Error processing imagine grid: %v", err)
+
+		return
+	}
 }
 
 func (q *queueImpl) getPreviousGeneration(imagine *QueueItem, sortOrder int) (*entities.ImageGeneration, error) {
@@ -473,10 +1205,15 @@ func (q *queueImpl) getPreviousGeneration(imagine *QueueItem, sortOrder int) (*e
 	return generation, nil
 }
 
-func imagineMessageContent(generation *entities.ImageGeneration, user *discordgo.User, progress float64) string {
+func messageLink(guildID string, message *discordgo.Message) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, message.ChannelID, message.ID)
+}
+
+// imagineMessageContent shows the progress bar while the progress is below 1, etaSeconds is left out when 0
+func imagineMessageContent(generation *entities.ImageGeneration, user *discordgo.User, progress, etaSeconds float64) string {
 	if progress >= 0 && progress < 1 {
-		return fmt.Sprintf("<@%s> asked me to imagine `%s`. Currently dreaming it up for them. Progress: `%.0f%%`",
-			user.ID, generation.Prompt, progress*100)
+		return fmt.Sprintf("<@%s> asked me to imagine `%s`. Currently dreaming it up for them. Progress: `%s`",
+			user.ID, generation.Prompt, progressBar(progress, etaSeconds))
 	} else {
 		return fmt.Sprintf("<@%s> asked me to imagine `%s`",
 			user.ID,
@@ -485,21 +1222,39 @@ func imagineMessageContent(generation *entities.ImageGeneration, user *discordgo
 	}
 }
 
-func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration, imagine *QueueItem) error {
+func (q *queueImpl) processImagineGrid(ctx context.Context, newGeneration *entities.ImageGeneration, imagine *QueueItem) error {
 	timeStart := time.Now()
 	log.Printf("Processing imagine #%s: %v\n", imagine.DiscordInteraction.ID, newGeneration.Prompt)
 
-	newContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, 0)
+	scripts, err := alwaysonScripts(imagine)
+	if err != nil {
+		log.Printf("Error preparing extension scripts: %v", err)
+
+		errorContent := "I'm sorry, but I couldn't download your ControlNet image."
 
-	message, err := q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+		_, editErr := q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content: &errorContent,
+		})
+		if editErr != nil {
+			log.Printf("Error editing interaction: %v", editErr)
+		}
+
+		return err
+	}
+
+	newContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, 0, 0)
+
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
 		Content: &newContent,
 	})
 	if err != nil {
 		log.Printf("Error editing interaction: %v", err)
+	} else {
+		imagine.DiscordMessageID = message.ID
 	}
 
 	newGeneration.InteractionID = imagine.DiscordInteraction.ID
-	newGeneration.MessageID = message.ID
+	newGeneration.MessageID = imagine.DiscordMessageID
 	newGeneration.MemberID = imagine.DiscordInteraction.Member.User.ID
 	newGeneration.SortOrder = 0
 
@@ -509,14 +1264,26 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 	}
 
 	generationDone := make(chan bool)
+	progressDone := make(chan struct{})
+
+	// stopProgress stops the progress updates and waits for the live preview to be deleted
+	stopProgress := func() {
+		close(generationDone)
+		<-progressDone
+	}
 
 	go func() {
+		defer close(progressDone)
+
+		preview := &livePreview{q: q, imagine: imagine}
+		defer preview.delete()
+
 		for {
 			select {
 			case <-generationDone:
 				return
-			case <-time.After(1 * time.Second):
-				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress()
+			case <-time.After(q.progressInterval):
+				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress(ctx, !q.livePreview)
 				if progressErr != nil {
 					log.Printf("Error getting current progress: %v", progressErr)
 
@@ -527,14 +1294,18 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 					continue
 				}
 
-				progressContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, progress.Progress)
+				progressContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, progress.Progress, progress.EtaRelative)
 
-				_, progressErr = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+				_, progressErr = q.editResponse(imagine, &discordgo.WebhookEdit{
 					Content: &progressContent,
 				})
 				if progressErr != nil {
 					log.Printf("Error editing interaction: %v", err)
 				}
+
+				if q.livePreview {
+					preview.update(progress.CurrentImage)
+				}
 			}
 		}
 	}()
@@ -548,7 +1319,12 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 		returnGrid = false
 	}
 
-	resp, err := q.stableDiffusionAPI.TextToImage(&stable_diffusion_api.TextToImageRequest{
+	// The hires pass upscales the tiles separately, so the seams may show again
+	if imagine.Options.Tiling && newGeneration.EnableHR {
+		log.Printf("Warning: imagine #%s uses tiling with hires fix, the result may not tile seamlessly", imagine.DiscordInteraction.ID)
+	}
+
+	resp, err := q.textToImage(ctx, &stable_diffusion_api.TextToImageRequest{
 		Prompt:            newGeneration.Prompt,
 		NegativePrompt:    newGeneration.NegativePrompt,
 		Width:             newGeneration.Width,
@@ -562,11 +1338,17 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 		Seed:              newGeneration.Seed,
 		Subseed:           newGeneration.Subseed,
 		SubseedStrength:   newGeneration.SubseedStrength,
-		SamplerName:       newGeneration.SamplerName,
+		SamplerName:       q.canonicalSampler(newGeneration.SamplerName),
 		CfgScale:          newGeneration.CfgScale,
 		Steps:             newGeneration.Steps,
 		NIter:             4,
 		SaveImages:        true,
+		AlwaysonScripts:   scripts,
+		HrUpscaler:        imagine.Options.HiresUpscaler,
+		HrSecondPassSteps: imagine.Options.HiresSteps,
+		Tiling:            imagine.Options.Tiling,
+		// Not stored with the generation, so rerolls and variations use the server default
+		InitialNoiseMultiplier: imagine.Options.InitialNoiseMultiplier,
 		OverrideSettings: stable_diffusion_api.Txt2ImgOverrideSettings{
 			GridFormat:    "webp",
 			ReturnGrid:    &returnGrid,
@@ -574,26 +1356,30 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 			// See https://github.com/AUTOMATIC1111/stable-diffusion-webui/pull/9177
 			// TODO: move to config
 			NegativeGuidanceMinimumSigma: 2,
+			TokenMergingRatio:            q.tokenMergingOverride(imagine.DiscordInteraction.GuildID),
+			CodeFormerWeight:             imagine.Options.CodeFormerWeight,
+			ClipSkip:                     imagine.Options.ClipSkip,
 		},
 	})
+	stopProgress()
+
 	if err != nil {
 		log.Printf("Error processing image: %v\n", err)
+		imagine.markInterrupted(err)
 
 		b, err := json.MarshalIndent(newGeneration, "", "\t")
 		log.Printf("req: \n%s\n%v", b, err)
 
 		errorContent := "I'm sorry, but I had a problem imagining your image."
 
-		_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
 			Content: &errorContent,
 		})
 
 		return err
 	}
 
-	generationDone <- true
-
-	finishedContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, 1)
+	finishedContent := imagineMessageContent(newGeneration, imagine.DiscordInteraction.Member.User, 1, 0)
 
 	log.Printf("Seeds: %v Subseeds:%v Time: %s", resp.Seeds, resp.Subseeds, time.Since(timeStart).Round(time.Millisecond))
 
@@ -606,6 +1392,8 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 			decodedImage, decodeErr := base64.StdEncoding.DecodeString(image)
 			if decodeErr != nil {
 				log.Printf("Error decoding image: %v\n", decodeErr)
+			} else {
+				decodedImage = withParameters(decodedImage, generationParameters(newGeneration, resp.Seeds[idx], resp.Model))
 			}
 
 			files = append(files, &discordgo.File{
@@ -677,121 +1465,200 @@ func (q *queueImpl) processImagineGrid(newGeneration *entities.ImageGeneration,
 		// statistics adds to the latest subGeneration
 		ImageGenerationID: subGeneration.ID,
 		MemberID:          imagine.DiscordInteraction.Member.User.ID,
-		TimeMs:            totalTime.Milliseconds(),
+		ServerID:          imagine.DiscordInteraction.GuildID,
+		TimeMs:            resp.GenerationTimeMs,
+		QueueWaitMs:       imagine.queueWait().Milliseconds(),
+		BonusPoints:       imagine.bonusPoints(),
 	}); err != nil {
 		log.Printf("Error updating processing time: %v", err)
 	}
 
 	finishedContent += fmt.Sprintf(" (%s)", totalTime)
 
-	_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
-		Content: &finishedContent,
-		Files:   files,
-		Components: &[]discordgo.MessageComponent{
-			discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.Button{
-						// Label is what the user will see on the button.
-						Label: "V1",
-						// Style provides coloring of the button. There are not so many styles tho.
-						Style: discordgo.SecondaryButton,
-						// Disabled allows bot to disable some buttons for users.
-						Disabled: false,
-						// CustomID is a thing telling Discord which data to send when this button will be pressed.
-						CustomID: "imagine_variation_1",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "♻️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "V2",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_variation_2",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "♻️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "V3",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_variation_3",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "♻️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "V4",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_variation_4",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "♻️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "Re-roll",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_reroll",
-						Emoji: discordgo.ComponentEmoji{
-							Name: "🎲",
-						},
+	if imagine.Type == ItemTypeVariation && imagine.DiscordInteraction.Message != nil {
+		finishedContent += fmt.Sprintf("\nVariation of image %d from %s", imagine.InteractionIndex,
+			messageLink(imagine.DiscordInteraction.GuildID, imagine.DiscordInteraction.Message))
+	}
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					// Label is what the user will see on the button.
+					Label: "V1",
+					// Style provides coloring of the button. There are not so many styles tho.
+					Style: discordgo.SecondaryButton,
+					// Disabled allows bot to disable some buttons for users.
+					Disabled: false,
+					// CustomID is a thing telling Discord which data to send when this button will be pressed.
+					CustomID: "imagine_variation_1",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "♻️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "V2",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_variation_2",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "♻️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "V3",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_variation_3",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "♻️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "V4",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_variation_4",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "♻️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "Re-roll",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_reroll",
+					Emoji: discordgo.ComponentEmoji{
+						Name: "🎲",
 					},
 				},
 			},
-			discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.Button{
-						Label:    "U1",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_upscale_1",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "⬆️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "U2",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_upscale_2",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "⬆️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "U3",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_upscale_3",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "⬆️",
-						//},
-					},
-					discordgo.Button{
-						Label:    "U4",
-						Style:    discordgo.SecondaryButton,
-						Disabled: false,
-						CustomID: "imagine_upscale_4",
-						//Emoji: discordgo.ComponentEmoji{
-						//	Name: "⬆️",
-						//},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "U1",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_upscale_1",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "⬆️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "U2",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_upscale_2",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "⬆️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "U3",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_upscale_3",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "⬆️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "U4",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_upscale_4",
+					//Emoji: discordgo.ComponentEmoji{
+					//	Name: "⬆️",
+					//},
+				},
+				discordgo.Button{
+					Label:    "Upscale All",
+					Style:    discordgo.SecondaryButton,
+					Disabled: false,
+					CustomID: "imagine_upscale_all",
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Delete",
+					Style:    discordgo.DangerButton,
+					Disabled: false,
+					CustomID: "imagine_delete",
+					Emoji: discordgo.ComponentEmoji{
+						Name: "🗑️",
 					},
 				},
 			},
 		},
-	})
-	if err != nil {
-		log.Printf("Error editing interaction: %v\n", err)
+	}
 
-		return err
+	// Extension scripts aren't persisted, and re-rolls, variations and upscales are generated again
+	// from the stored parameters, so they would lose them. Only the row with the delete button is kept
+	if len(scripts) > 0 {
+		components = components[len(components)-1:]
+	}
+
+	var finishedMessage *discordgo.Message
+
+	if imagine.OutputChannelID != "" {
+		finishedMessage, err = q.sendToOutputChannel(imagine, finishedContent, files, components)
+		if err != nil {
+			log.Printf("Error sending message to the output channel: %v\n", err)
+
+			return err
+		}
+	} else {
+		finishedMessage, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content:    &finishedContent,
+			Files:      files,
+			Components: &components,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v\n", err)
+
+			return err
+		}
+	}
+
+	q.notifyCompletion(imagine, finishedMessage)
+
+	if q.forumChannelID != "" {
+		go q.postToForum(imagine, newGeneration, resp, finishedMessage)
 	}
 
 	return nil
 }
 
+const (
+	preferredUpscaler = "R-ESRGAN 4x+"
+	fallbackUpscaler  = "4x_escale_100000_G"
+)
+
+// upscalerName picks a neural upscaler available on the server for upscaling
+func (q *queueImpl) upscalerName() string {
+	models, err := q.stableDiffusionAPI.GetRealesrganModels(context.Background())
+	if err != nil {
+		log.Printf("Error getting upscalers: %v", err)
+
+		return fallbackUpscaler
+	}
+
+	if len(models) == 0 {
+		return fallbackUpscaler
+	}
+
+	for _, model := range models {
+		if model == preferredUpscaler {
+			return model
+		}
+	}
+
+	return models[0]
+}
+
 func upscaleMessageContent(user *discordgo.User, fetchProgress, upscaleProgress float64) string {
 	if fetchProgress >= 0 && fetchProgress <= 1 && upscaleProgress < 1 {
 		if upscaleProgress == 0 {
@@ -806,9 +1673,9 @@ func upscaleMessageContent(user *discordgo.User, fetchProgress, upscaleProgress
 	}
 }
 
-func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
+func (q *queueImpl) processUpscaleImagine(ctx context.Context, imagine *QueueItem) {
 	if true {
-		q.processUpscaleImagineAlternative(q.currentImagine)
+		q.processUpscaleImagineAlternative(ctx, imagine)
 		return
 	}
 
@@ -833,11 +1700,13 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 
 	newContent := upscaleMessageContent(imagine.DiscordInteraction.Member.User, 0, 0)
 
-	_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
 		Content: &newContent,
 	})
 	if err != nil {
 		log.Printf("Error editing interaction: %v", err)
+	} else {
+		imagine.DiscordMessageID = message.ID
 	}
 
 	generationDone := make(chan bool)
@@ -851,8 +1720,8 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 			select {
 			case <-generationDone:
 				return
-			case <-time.After(1 * time.Second):
-				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress()
+			case <-time.After(q.progressInterval):
+				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress(ctx, true)
 				if progressErr != nil {
 					log.Printf("Error getting current progress: %v", progressErr)
 
@@ -874,7 +1743,7 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 
 				progressContent := upscaleMessageContent(imagine.DiscordInteraction.Member.User, fetchProgress, upscaleProgress)
 
-				_, progressErr = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+				_, progressErr = q.editResponse(imagine, &discordgo.WebhookEdit{
 					Content: &progressContent,
 				})
 				if progressErr != nil {
@@ -884,7 +1753,7 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 		}
 	}()
 
-	resp, err := q.stableDiffusionAPI.UpscaleImage(&stable_diffusion_api.UpscaleRequest{
+	resp, err := q.stableDiffusionAPI.UpscaleImage(ctx, &stable_diffusion_api.UpscaleRequest{
 		ResizeMode:      0,
 		UpscalingResize: 2,
 		Upscaler1:       "ESRGAN_4x",
@@ -914,10 +1783,11 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 	})
 	if err != nil {
 		log.Printf("Error processing image upscale: %v\n", err)
+		imagine.markInterrupted(err)
 
 		errorContent := "I'm sorry, but I had a problem upscaling your image."
 
-		_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
 			Content: &errorContent,
 		})
 
@@ -941,7 +1811,7 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 	finishedContent := fmt.Sprintf("<@%s> asked me to upscale their image. Here's the result:",
 		imagine.DiscordInteraction.Member.User.ID)
 
-	_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+	_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
 		Content: &finishedContent,
 		Files: []*discordgo.File{
 			{
@@ -958,7 +1828,7 @@ func (q *queueImpl) processUpscaleImagine(imagine *QueueItem) {
 	}
 }
 
-func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
+func (q *queueImpl) processUpscaleImagineAlternative(ctx context.Context, imagine *QueueItem) {
 	timeStart := time.Now()
 
 	interactionID := imagine.DiscordInteraction.ID
@@ -978,15 +1848,20 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 		return
 	}
 
-	log.Printf("Found generation: %v", generation)
+	// Every grid stores the seeds of its own images, so upscaling a variation grid keeps
+	// the variation's seed and subseed instead of going back to the original image
+	log.Printf("Found generation: %v, Seed: %d, Subseed: %d (%.2f)",
+		generation, generation.Seed, generation.Subseed, generation.SubseedStrength)
 
 	newContent := upscaleMessageContent(imagine.DiscordInteraction.Member.User, 0, 0)
 
-	_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
 		Content: &newContent,
 	})
 	if err != nil {
 		log.Printf("Error editing interaction: %v", err)
+	} else {
+		imagine.DiscordMessageID = message.ID
 	}
 
 	generationDone := make(chan bool)
@@ -1000,8 +1875,8 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 			select {
 			case <-generationDone:
 				return
-			case <-time.After(1 * time.Second):
-				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress()
+			case <-time.After(q.progressInterval):
+				progress, progressErr := q.stableDiffusionAPI.GetCurrentProgress(ctx, true)
 				if progressErr != nil {
 					log.Printf("Error getting current progress: %v", progressErr)
 
@@ -1023,7 +1898,7 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 
 				progressContent := upscaleMessageContent(imagine.DiscordInteraction.Member.User, fetchProgress, upscaleProgress)
 
-				_, progressErr = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+				_, progressErr = q.editResponse(imagine, &discordgo.WebhookEdit{
 					Content: &progressContent,
 				})
 				if progressErr != nil {
@@ -1033,43 +1908,14 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 		}
 	}()
 
-	//generation.EnableHR = true
-	const hiresCoeff = 2
-	//// Round up to the nearest 8
-	generation.HiresWidth = (int(float32(generation.HiresWidth)*hiresCoeff) + 7) & (-8)
-	generation.HiresHeight = (int(float32(generation.HiresHeight)*hiresCoeff) + 7) & (-8)
-
-	resp, err := q.stableDiffusionAPI.TextToImage(&stable_diffusion_api.TextToImageRequest{
-		Prompt:         generation.Prompt,
-		NegativePrompt: generation.NegativePrompt,
-		Width:          generation.Width,
-		Height:         generation.Height,
-		RestoreFaces:   generation.RestoreFaces,
-		EnableHR:       true,
-		//HrScale:           2,
-		HrUpscaler:        "4x_escale_100000_G",
-		HRResizeX:         generation.HiresWidth,
-		HRResizeY:         generation.HiresHeight,
-		DenoisingStrength: generation.DenoisingStrength,
-		BatchSize:         generation.BatchSize,
-		Seed:              generation.Seed,
-		Subseed:           generation.Subseed,
-		SubseedStrength:   generation.SubseedStrength,
-		SamplerName:       generation.SamplerName,
-		CfgScale:          generation.CfgScale,
-		Steps:             generation.Steps,
-		NIter:             1,
-		SaveImages:        true,
-		OverrideSettings: stable_diffusion_api.Txt2ImgOverrideSettings{
-			SamplesFormat: "webp",
-		},
-	})
+	resp, err := q.textToImage(ctx, q.upscaleRequest(imagine.DiscordInteraction.GuildID, generation))
 	if err != nil {
 		log.Printf("Error processing image upscale: %v\n", err)
+		imagine.markInterrupted(err)
 
 		errorContent := "I'm sorry, but I had a problem upscaling your image."
 
-		_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
 			Content: &errorContent,
 		})
 
@@ -1091,7 +1937,9 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 	if _, err = q.statisticsRepo.AddProcessingTime(context.Background(), &entities.Statistics{
 		ImageGenerationID: generation.ID,
 		MemberID:          imagine.DiscordInteraction.Member.User.ID,
-		TimeMs:            totalTime.Milliseconds(),
+		ServerID:          imagine.DiscordInteraction.GuildID,
+		TimeMs:            resp.GenerationTimeMs,
+		QueueWaitMs:       imagine.queueWait().Milliseconds(),
 	}); err != nil {
 		log.Printf("Error updating processing time: %v", err)
 	}
@@ -1101,7 +1949,7 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 
 	finishedContent := fmt.Sprintf("<@%s> asked me to upscale their image (%s):", imagine.DiscordInteraction.Member.User.ID, totalTime)
 
-	_, err = q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, &discordgo.WebhookEdit{
+	_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
 		Content: &finishedContent,
 		Files: []*discordgo.File{
 			{
@@ -1116,4 +1964,228 @@ func (q *queueImpl) processUpscaleImagineAlternative(imagine *QueueItem) {
 
 		return
 	}
+
+	imagine.IsUpscaled = true
+
+	q.disableUpscaleButtons(imagine, imagine.InteractionIndex)
+}
+
+// canonicalSampler returns the sampler name known to the server, so that names from older API versions keep working
+func (q *queueImpl) canonicalSampler(name string) string {
+	q.samplerAliasesMu.Lock()
+	defer q.samplerAliasesMu.Unlock()
+
+	if q.samplerAliases == nil {
+		aliases, err := q.stableDiffusionAPI.GetSamplerAliases(context.Background())
+		if err != nil {
+			log.Printf("Error getting sampler aliases: %v", err)
+
+			return name
+		}
+
+		q.samplerAliases = aliases
+	}
+
+	if canonical, ok := q.samplerAliases[name]; ok {
+		return canonical
+	}
+
+	return name
+}
+
+// upscaleRequest regenerates the image with the hires fix at twice the resolution
+func (q *queueImpl) upscaleRequest(guildID string, generation *entities.ImageGeneration) *stable_diffusion_api.TextToImageRequest {
+	//generation.EnableHR = true
+	const hiresCoeff = 2
+	//// Round up to the nearest 8
+	generation.HiresWidth = (int(float32(generation.HiresWidth)*hiresCoeff) + 7) & (-8)
+	generation.HiresHeight = (int(float32(generation.HiresHeight)*hiresCoeff) + 7) & (-8)
+
+	return &stable_diffusion_api.TextToImageRequest{
+		Prompt:         generation.Prompt,
+		NegativePrompt: generation.NegativePrompt,
+		Width:          generation.Width,
+		Height:         generation.Height,
+		RestoreFaces:   generation.RestoreFaces,
+		EnableHR:       true,
+		//HrScale:           2,
+		HrUpscaler:        q.upscalerName(),
+		HRResizeX:         generation.HiresWidth,
+		HRResizeY:         generation.HiresHeight,
+		DenoisingStrength: generation.DenoisingStrength,
+		BatchSize:         generation.BatchSize,
+		Seed:              generation.Seed,
+		Subseed:           generation.Subseed,
+		SubseedStrength:   generation.SubseedStrength,
+		SamplerName:       q.canonicalSampler(generation.SamplerName),
+		CfgScale:          generation.CfgScale,
+		Steps:             generation.Steps,
+		NIter:             1,
+		SaveImages:        true,
+		OverrideSettings: stable_diffusion_api.Txt2ImgOverrideSettings{
+			SamplesFormat:     "webp",
+			TokenMergingRatio: q.tokenMergingOverride(guildID),
+		},
+	}
+}
+
+// processBatchUpscaleImagine upscales all images of a grid one by one, posting each result as a follow-up message,
+// since the interaction response is the grid message itself
+func (q *queueImpl) processBatchUpscaleImagine(ctx context.Context, imagine *QueueItem) {
+	if imagine.DiscordInteraction.Message == nil {
+		return
+	}
+
+	messageID := imagine.DiscordInteraction.Message.ID
+	userID := imagine.DiscordInteraction.Member.User.ID
+
+	stats := make([]*entities.Statistics, 0, 4)
+	upscaled := make([]int, 0, 4)
+
+	defer func() {
+		if _, err := q.statisticsRepo.AddProcessingTimeBatch(context.Background(), stats); err != nil {
+			log.Printf("Error updating processing time: %v", err)
+		}
+	}()
+
+	for idx := 1; idx <= 4; idx++ {
+		timeStart := time.Now()
+
+		log.Printf("Upscaling image: %v, Message: %v, Upscale Index: %d",
+			imagine.DiscordInteraction.ID, messageID, idx)
+
+		generation, err := q.imageGenerationRepo.GetByMessageAndSort(context.Background(), messageID, idx)
+		if err != nil {
+			log.Printf("Error getting image generation: %v", err)
+
+			continue
+		}
+
+		resp, err := q.textToImage(ctx, q.upscaleRequest(imagine.DiscordInteraction.GuildID, generation))
+		if err == nil && len(resp.Images) == 0 {
+			err = errors.New("no images in the response")
+		}
+
+		if err != nil {
+			log.Printf("Error processing image upscale: %v\n", err)
+			imagine.markInterrupted(err)
+
+			_, err = q.sendFollowup(imagine, &discordgo.WebhookParams{
+				Content: fmt.Sprintf("I'm sorry, but I had a problem upscaling image %d.", idx),
+			})
+			if err != nil {
+				log.Printf("Error sending message: %v", err)
+			}
+
+			continue
+		}
+
+		decodedImage, decodeErr := base64.StdEncoding.DecodeString(resp.Images[0])
+		if decodeErr != nil {
+			log.Printf("Error decoding image: %v\n", decodeErr)
+
+			continue
+		}
+
+		totalTime := time.Since(timeStart).Round(time.Millisecond)
+
+		stats = append(stats, &entities.Statistics{
+			ImageGenerationID: generation.ID,
+			MemberID:          userID,
+			ServerID:          imagine.DiscordInteraction.GuildID,
+			TimeMs:            resp.GenerationTimeMs,
+			QueueWaitMs:       imagine.queueWait().Milliseconds(),
+		})
+
+		_, err = q.sendFollowup(imagine, &discordgo.WebhookParams{
+			Content: fmt.Sprintf("<@%s> asked me to upscale image %d of %s (%s):", userID, idx,
+				messageLink(imagine.DiscordInteraction.GuildID, imagine.DiscordInteraction.Message), totalTime),
+			Files: []*discordgo.File{
+				{
+					ContentType: "image/png",
+					Name:        fmt.Sprintf("seed-%d-%s.png", generation.Seed, resp.Model),
+					Reader:      bytes.NewBuffer(decodedImage),
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error sending message: %v\n", err)
+
+			continue
+		}
+
+		upscaled = append(upscaled, idx)
+	}
+
+	q.disableUpscaleButtons(imagine, upscaled...)
+}
+
+func (q *queueImpl) processRawImagine(ctx context.Context, imagine *QueueItem) {
+	timeStart := time.Now()
+
+	log.Printf("Processing raw imagine #%s: %v\n", imagine.DiscordInteraction.ID, imagine.RawRequest.Prompt)
+
+	newContent := fmt.Sprintf("<@%s> asked me to imagine `%s`. Currently dreaming it up for them.",
+		imagine.DiscordInteraction.Member.User.ID, imagine.RawRequest.Prompt)
+
+	message, err := q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &newContent,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v", err)
+	} else {
+		imagine.DiscordMessageID = message.ID
+	}
+
+	resp, err := q.textToImage(ctx, imagine.RawRequest)
+	if err != nil {
+		log.Printf("Error processing raw image: %v\n", err)
+		imagine.markInterrupted(err)
+
+		errorContent := "I'm sorry, but I had a problem imagining your image."
+
+		_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+			Content: &errorContent,
+		})
+		if err != nil {
+			log.Printf("Error editing interaction: %v", err)
+		}
+
+		return
+	}
+
+	var files []*discordgo.File
+
+	for idx, image := range resp.Images {
+		decodedImage, decodeErr := base64.StdEncoding.DecodeString(image)
+		if decodeErr != nil {
+			log.Printf("Error decoding image: %v\n", decodeErr)
+
+			continue
+		}
+
+		seed := 0
+		if idx < len(resp.Seeds) {
+			seed = resp.Seeds[idx]
+		}
+
+		files = append(files, &discordgo.File{
+			ContentType: "image/png",
+			Name:        fmt.Sprintf("seed-%d-%s.png", seed, resp.Model),
+			Reader:      bytes.NewBuffer(decodedImage),
+		})
+	}
+
+	totalTime := time.Since(timeStart).Round(time.Millisecond)
+
+	finishedContent := fmt.Sprintf("<@%s> asked me to imagine `%s` (%s)",
+		imagine.DiscordInteraction.Member.User.ID, imagine.RawRequest.Prompt, totalTime)
+
+	_, err = q.editResponse(imagine, &discordgo.WebhookEdit{
+		Content: &finishedContent,
+		Files:   files,
+	})
+	if err != nil {
+		log.Printf("Error editing interaction: %v\n", err)
+	}
 }
@@ -0,0 +1,87 @@
+package imagine_queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+// memoryDefaultSettingsRepo keeps the default settings in memory
+type memoryDefaultSettingsRepo struct {
+	mu       sync.Mutex
+	settings map[string]entities.DefaultSettings
+}
+
+func (repo *memoryDefaultSettingsRepo) Upsert(ctx context.Context, setting *entities.DefaultSettings) (*entities.DefaultSettings, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.settings[setting.MemberID] = *setting
+
+	return setting, nil
+}
+
+func (repo *memoryDefaultSettingsRepo) GetByMemberID(ctx context.Context, memberID string) (*entities.DefaultSettings, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	setting := repo.settings[memberID]
+
+	return &setting, nil
+}
+
+// TestDefaultDimensionsConcurrentAccess updates the default dimensions while other goroutines read them,
+// like the workers do. Run with -race
+func TestDefaultDimensionsConcurrentAccess(t *testing.T) {
+	q := &queueImpl{defaultSettingsRepo: &memoryDefaultSettingsRepo{settings: map[string]entities.DefaultSettings{
+		botID: {MemberID: botID, Width: initializedWidth, Height: initializedHeight},
+	}}}
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for n := 0; n < 100; n++ {
+				width, err := q.GetDefaultBotWidth()
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				height, err := q.GetDefaultBotHeight()
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				if width == 0 || height == 0 {
+					t.Errorf("default dimensions = %dx%d, want non-zero", width, height)
+
+					return
+				}
+			}
+		}()
+	}
+
+	for n := 0; n < 100; n++ {
+		err := q.UpdateDefaultDimensions(512+8*(n%2), 768)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wg.Wait()
+
+	width, err := q.GetDefaultBotWidth()
+	if err != nil || width != 520 {
+		t.Errorf("GetDefaultBotWidth() = %d, %v, want the last update 520", width, err)
+	}
+}
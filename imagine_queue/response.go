@@ -0,0 +1,104 @@
+package imagine_queue
+
+import (
+	"context"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// editResponse replaces the interaction response of the item. Interaction tokens expire after
+// interactionTokenLifetime, so items that waited longer are answered with regular channel messages
+// sent with the bot token: status updates edit one message, and results are posted as a new one
+func (q *queueImpl) editResponse(imagine *QueueItem, edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	if !imagine.interactionExpired() {
+		return q.botSession.InteractionResponseEdit(imagine.DiscordInteraction, edit)
+	}
+
+	channelID := imagine.DiscordInteraction.ChannelID
+
+	if len(edit.Files) == 0 && imagine.fallbackMessageID != "" {
+		messageEdit := discordgo.NewMessageEdit(channelID, imagine.fallbackMessageID)
+		messageEdit.Content = edit.Content
+
+		if edit.Components != nil {
+			messageEdit.Components = *edit.Components
+		}
+
+		if edit.Embeds != nil {
+			messageEdit.Embeds = *edit.Embeds
+		}
+
+		return q.botSession.ChannelMessageEditComplex(messageEdit)
+	}
+
+	send := &discordgo.MessageSend{
+		Files:           edit.Files,
+		AllowedMentions: edit.AllowedMentions,
+	}
+
+	if edit.Content != nil {
+		send.Content = *edit.Content
+	}
+
+	if edit.Components != nil {
+		send.Components = *edit.Components
+	}
+
+	if edit.Embeds != nil {
+		send.Embeds = *edit.Embeds
+	}
+
+	message, err := q.botSession.ChannelMessageSendComplex(channelID, send)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(edit.Files) == 0 {
+		imagine.fallbackMessageID = message.ID
+
+		return message, nil
+	}
+
+	q.moveGenerations(imagine, message)
+
+	// The status message is replaced by the result
+	if imagine.fallbackMessageID != "" {
+		err = q.botSession.ChannelMessageDelete(channelID, imagine.fallbackMessageID)
+		if err != nil {
+			log.Printf("Error deleting message: %v", err)
+		}
+
+		imagine.fallbackMessageID = ""
+	}
+
+	return message, nil
+}
+
+// sendFollowup sends a followup message to the interaction of the item, or a channel message when it has expired
+func (q *queueImpl) sendFollowup(imagine *QueueItem, params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	if !imagine.interactionExpired() {
+		return q.botSession.FollowupMessageCreate(imagine.followupInteraction(), true, params)
+	}
+
+	return q.botSession.ChannelMessageSendComplex(imagine.DiscordInteraction.ChannelID, &discordgo.MessageSend{
+		Content:         params.Content,
+		Files:           params.Files,
+		Components:      params.Components,
+		Embeds:          params.Embeds,
+		AllowedMentions: params.AllowedMentions,
+	})
+}
+
+// moveGenerations points the generations of the item to the message the result was posted with,
+// because the buttons of the result look up the generations by the message they are attached to
+func (q *queueImpl) moveGenerations(imagine *QueueItem, message *discordgo.Message) {
+	if imagine.DiscordMessageID != "" && imagine.DiscordMessageID != message.ID {
+		err := q.imageGenerationRepo.UpdateMessageID(context.Background(), imagine.DiscordMessageID, message.ID)
+		if err != nil {
+			log.Printf("Error updating image generation message: %v", err)
+		}
+	}
+
+	imagine.DiscordMessageID = message.ID
+}
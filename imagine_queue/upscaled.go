@@ -0,0 +1,64 @@
+package imagine_queue
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// disableUpscaleButtons disables the upscale buttons of the images in the grid message the item was created from,
+// so that the same image isn't upscaled twice
+func (q *queueImpl) disableUpscaleButtons(imagine *QueueItem, indexes ...int) {
+	if imagine.DiscordInteraction.Message == nil || len(indexes) == 0 {
+		return
+	}
+
+	// The message is fetched again, the interaction has the components from the time of the click
+	// and other images of the grid may have been upscaled since
+	gridMessage, err := q.botSession.ChannelMessage(imagine.DiscordInteraction.ChannelID, imagine.DiscordInteraction.Message.ID)
+	if err != nil {
+		log.Printf("Error getting grid message: %v", err)
+
+		return
+	}
+
+	customIDs := make(map[string]bool, len(indexes))
+	for _, idx := range indexes {
+		customIDs[fmt.Sprintf("imagine_upscale_%d", idx)] = true
+	}
+
+	components := make([]discordgo.MessageComponent, 0, len(gridMessage.Components))
+
+	for _, component := range gridMessage.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			components = append(components, component)
+
+			continue
+		}
+
+		newRow := discordgo.ActionsRow{}
+
+		for _, rowComponent := range row.Components {
+			if button, isButton := rowComponent.(*discordgo.Button); isButton && customIDs[button.CustomID] {
+				disabled := *button
+				disabled.Disabled = true
+				rowComponent = disabled
+			}
+
+			newRow.Components = append(newRow.Components, rowComponent)
+		}
+
+		components = append(components, newRow)
+	}
+
+	_, err = q.botSession.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         gridMessage.ID,
+		Channel:    gridMessage.ChannelID,
+		Components: components,
+	})
+	if err != nil {
+		log.Printf("Error disabling upscale buttons: %v", err)
+	}
+}
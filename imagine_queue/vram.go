@@ -0,0 +1,98 @@
+package imagine_queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"stable_diffusion_bot/stable_diffusion_api"
+)
+
+// After running out of VRAM, images are generated one at a time for this long
+const vramPressureDuration = 10 * time.Minute
+
+// isOutOfMemory reports whether the API error was caused by the server running out of VRAM
+func isOutOfMemory(err error) bool {
+	message := strings.ToLower(err.Error())
+
+	return strings.Contains(message, "out of memory") || strings.Contains(message, "cuda error")
+}
+
+// underVRAMPressure reports whether the server ran out of VRAM in the last vramPressureDuration
+func (q *queueImpl) underVRAMPressure() bool {
+	q.vramMu.Lock()
+	defer q.vramMu.Unlock()
+
+	return q.vramPressureTimer != nil
+}
+
+// startVRAMPressure lowers the batch size to 1 for vramPressureDuration, extending the period
+// if the server is already under pressure
+func (q *queueImpl) startVRAMPressure() {
+	q.vramMu.Lock()
+	defer q.vramMu.Unlock()
+
+	if q.vramPressureTimer != nil {
+		q.vramPressureTimer.Reset(vramPressureDuration)
+
+		return
+	}
+
+	q.vramPressureTimer = time.AfterFunc(vramPressureDuration, func() {
+		q.vramMu.Lock()
+		q.vramPressureTimer = nil
+		q.vramMu.Unlock()
+
+		log.Println("VRAM pressure subsided")
+	})
+}
+
+// textToImage generates the images, splitting the batch into single image iterations while the server
+// is short on VRAM. A batch failing with an out of memory error is retried once with batch size 1,
+// single images can't be split further, so their error is returned as is
+func (q *queueImpl) textToImage(ctx context.Context, req *stable_diffusion_api.TextToImageRequest) (*stable_diffusion_api.TextToImageResponse, error) {
+	if req.BatchSize > 1 && q.underVRAMPressure() {
+		req = singleImageBatches(req)
+	}
+
+	resp, err := q.stableDiffusionAPI.TextToImage(ctx, req)
+	if err == nil || req.BatchSize <= 1 || !isOutOfMemory(err) {
+		return resp, err
+	}
+
+	log.Printf("Warning: the server ran out of VRAM, retrying with batch size 1 for the next %s", vramPressureDuration)
+
+	q.startVRAMPressure()
+	q.sendErrorAlert(fmt.Sprintf("Warning: the server ran out of VRAM, batch size is lowered to 1 for %s", vramPressureDuration))
+
+	return q.stableDiffusionAPI.TextToImage(ctx, singleImageBatches(req))
+}
+
+// singleImageBatches returns a copy of the request generating the same number of images one at a time
+func singleImageBatches(req *stable_diffusion_api.TextToImageRequest) *stable_diffusion_api.TextToImageRequest {
+	single := *req
+
+	if single.NIter < 1 {
+		single.NIter = 1
+	}
+
+	if single.BatchSize > 1 {
+		single.NIter *= single.BatchSize
+		single.BatchSize = 1
+	}
+
+	return &single
+}
+
+func (q *queueImpl) sendErrorAlert(message string) {
+	if q.errorChannelID == "" || q.botSession == nil {
+		return
+	}
+
+	_, err := q.botSession.ChannelMessageSend(q.errorChannelID, message)
+	if err != nil {
+		log.Printf("Error sending alert: %v", err)
+	}
+}
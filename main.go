@@ -3,25 +3,67 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"stable_diffusion_bot/databases/sqlite"
 	"stable_diffusion_bot/discord_bot"
+	"stable_diffusion_bot/health_check"
 	"stable_diffusion_bot/imagine_queue"
 	"stable_diffusion_bot/repositories/default_settings"
 	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/repositories/notification_preferences"
+	"stable_diffusion_bot/repositories/settings"
 	"stable_diffusion_bot/repositories/statistics"
 	"stable_diffusion_bot/stable_diffusion_api"
 )
 
+// Set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 // Bot parameters
 var (
-	guildID            = flag.String("guild", "", "Guild ID. If not passed - bot registers commands globally")
-	botToken           = flag.String("token", "", "Bot access token")
-	apiHost            = flag.String("host", "", "Host for the Automatic1111 API")
-	imagineCommand     = flag.String("imagine", "imagine", "Imagine command name. Default is \"imagine\"")
-	removeCommandsFlag = flag.Bool("remove", false, "Delete all commands when bot exits")
-	devModeFlag        = flag.Bool("dev", false, "Start in development mode, using \"dev_\" prefixed commands instead")
+	guildID             = flag.String("guild", "", "Guild ID. If not passed - bot registers commands globally")
+	devGuildID          = flag.String("dev-guild", "", "Guild ID for registering commands in development mode. Defaults to the guild flag")
+	botToken            = flag.String("token", "", "Bot access token")
+	apiHost             = flag.String("host", "", "Host for the Automatic1111 API, a comma-separated list to distribute the generations over several instances")
+	imagineCommand      = flag.String("imagine", "imagine", "Imagine command name. Default is \"imagine\"")
+	removeCommandsFlag  = flag.Bool("remove", false, "Delete all commands when bot exits")
+	apiUserAgent        = flag.String("user-agent", stable_diffusion_api.DefaultUserAgent, "User-Agent header sent to the Automatic1111 API")
+	apiKey              = flag.String("api-key", "", "API key sent to the Automatic1111 API in the X-Api-Key header")
+	apiUsername         = flag.String("api-username", "", "Username of the --api-auth flag of the Automatic1111 API")
+	apiPassword         = flag.String("api-password", "", "Password of the --api-auth flag of the Automatic1111 API")
+	apiBearerToken      = flag.String("api-bearer-token", "", "Bearer token sent to the Automatic1111 API, e.g. for a reverse proxy")
+	devModeFlag         = flag.Bool("dev", false, "Start in development mode, using \"dev_\" prefixed commands instead")
+	workerCount         = flag.Int("workers", 1, "Number of queue items processed in parallel, e.g. on multi-GPU systems")
+	maxPromptLength     = flag.Int("max-prompt-length", 500, "Maximum prompt length in characters, 0 = unlimited")
+	rawBlacklist        = flag.String("raw-blacklist", "", "Comma separated override settings not allowed in the raw command. Output directory options by default")
+	translationProvider = flag.String("translation-provider", "", "Translate non-English prompts to English with \"google\" or \"libretranslate\", disabled by default")
+	translationAPIKey   = flag.String("translation-api-key", "", "API key of the translation provider")
+	defaultModel        = flag.String("model", "", "Checkpoint to load on start if another one is loaded, e.g. \"v1-5-pruned-emaonly.safetensors\"")
+	tipsChannelID       = flag.String("tips-channel", "", "Channel ID for posting a tip of the day, disabled by default")
+	tipsFile            = flag.String("tips-file", "", "Path to a JSON array of tips, required with the tips-channel flag")
+	tipsTime            = flag.String("tips-time", discord_bot.DefaultTipsTime, "UTC time of day for posting tips")
+	latencyThreshold    = flag.Int("latency-threshold", discord_bot.DefaultLatencyAlertThresholdMs, "P99 interaction response latency in ms that triggers a warning")
+	errorChannelID      = flag.String("error-channel", "", "Channel ID for operational warnings, they are only logged by default")
+	apiProxy            = flag.String("proxy", "", "HTTP or SOCKS5 proxy URL for the Automatic1111 API, e.g. socks5://127.0.0.1:1080")
+	apiTimeout          = flag.Duration("api-timeout", 10*time.Minute, "Timeout of Automatic1111 API requests, 0 = no timeout")
+	apiMaxRetries       = flag.Int("api-retries", 3, "Retries of Automatic1111 API requests failed with a connection error")
+	rateLimitPerUser    = flag.Int("rate-limit-per-user", 0, "Maximum number of pending imagine items per user, 0 = unlimited")
+	progressInterval    = flag.Duration("progress-interval", imagine_queue.DefaultProgressInterval, "Interval of the progress bar updates during generation")
+	livePreview         = flag.Bool("live-preview", false, "Post the current step of /imagine generations with the progress updates, disabled by default")
+	forumChannelID      = flag.String("forum-channel", "", "Forum channel ID where finished generations are also posted, disabled by default")
+	outputChannelID     = flag.String("output-channel", "", "Channel ID where imagine results are posted instead of the command's channel")
+	challengeChannelID  = flag.String("challenge-channel", "", "Channel ID for the daily prompt challenge, disabled by default")
+	healthAddr          = flag.String("health-addr", "", "Address for the HTTP /health endpoint, e.g. \":8080\", disabled by default")
+	civitAIAPIKey       = flag.String("civitai-api-key", "", "CivitAI API key for looking up the loaded model, optional")
+	commandCooldowns    = flag.String("cooldowns", "", "Comma separated per-user command cooldowns, e.g. \"imagine=30s,imagine_ext=1m\"")
+	allowedRoles        = flag.String("allowed-roles", "", "Comma separated role IDs allowed to generate images, everyone by default")
+	allowedChannels     = flag.String("allowed-channels", "", "Comma separated channel IDs where the commands can be used, all channels by default")
+	maxNegativeLength   = flag.Int("max-negative-prompt-length", 1000, "Maximum negative prompt length in characters, 0 = unlimited")
 )
 
 func main() {
@@ -60,12 +102,36 @@ func main() {
 	}
 
 	stableDiffusionAPI, err := stable_diffusion_api.New(stable_diffusion_api.Config{
-		Host: *apiHost,
+		Hosts:           strings.Split(*apiHost, ","),
+		DevelopmentMode: devMode,
+		UserAgent:       *apiUserAgent,
+		APIKey:          *apiKey,
+		Username:        *apiUsername,
+		Password:        *apiPassword,
+		BearerToken:     *apiBearerToken,
+		ProxyURL:        *apiProxy,
+		RequestTimeout:  *apiTimeout,
+		MaxRetries:      *apiMaxRetries,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create Stable Diffusion API: %v", err)
 	}
 
+	systemInfo, err := stableDiffusionAPI.GetSystemInfo(context.Background())
+	if err != nil {
+		log.Printf("Failed to get Stable Diffusion system info: %v", err)
+	} else {
+		log.Printf("Stable Diffusion system info: GPU: %s, VRAM: %d/%d MB free, Torch: %s, Python: %s, xformers: %t",
+			systemInfo.GpuName, systemInfo.VramFree>>20, systemInfo.VramTotal>>20,
+			systemInfo.TorchVersion, systemInfo.PythonVersion, systemInfo.XformersEnabled)
+	}
+
+	if stableDiffusionAPI.WebUIAvailable(context.Background()) {
+		log.Printf("Stable Diffusion web UI is accessible at %s", *apiHost)
+	} else {
+		log.Printf("Stable Diffusion web UI is not accessible, only the API is used")
+	}
+
 	ctx := context.Background()
 
 	sqliteDB, err := sqlite.New(ctx, dbFilePrefix)
@@ -88,25 +154,105 @@ func main() {
 		log.Fatalf("Failed to create statistics repository: %v", err)
 	}
 
+	settingsRepo, err := settings.NewRepository(&settings.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create settings repository: %v", err)
+	}
+
+	notificationsRepo, err := notification_preferences.NewRepository(&notification_preferences.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create notification preferences repository: %v", err)
+	}
+
+	if *healthAddr != "" {
+		healthHandler, healthErr := health_check.New(health_check.Config{
+			StableDiffusionAPI: stableDiffusionAPI,
+			StatisticsRepo:     statisticsRepo,
+		})
+		if healthErr != nil {
+			log.Fatalf("Failed to create health check: %v", healthErr)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/health", healthHandler)
+
+		go func() {
+			log.Printf("Serving the health check on %s", *healthAddr)
+
+			serveErr := http.ListenAndServe(*healthAddr, mux)
+			if serveErr != nil {
+				log.Printf("Health check server stopped: %v", serveErr)
+			}
+		}()
+	}
+
 	imagineQueue, err := imagine_queue.New(imagine_queue.Config{
 		StableDiffusionAPI:  stableDiffusionAPI,
 		ImageGenerationRepo: generationRepo,
 		DefaultSettingsRepo: defaultSettingsRepo,
 		StatisticsRepo:      statisticsRepo,
+		SettingsRepo:        settingsRepo,
+		NotificationsRepo:   notificationsRepo,
+		WorkerCount:         *workerCount,
+		DefaultModel:        *defaultModel,
+		ErrorChannelID:      *errorChannelID,
+		ForumChannelID:      *forumChannelID,
+		ProgressInterval:    *progressInterval,
+		RateLimitPerUser:    *rateLimitPerUser,
+		LivePreview:         *livePreview,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create imagine queue: %v", err)
 	}
 
+	var rawOverrideBlacklist []string
+	if rawBlacklist != nil && *rawBlacklist != "" {
+		rawOverrideBlacklist = strings.Split(*rawBlacklist, ",")
+	}
+
+	var allowedRoleIDs []string
+	if *allowedRoles != "" {
+		allowedRoleIDs = strings.Split(*allowedRoles, ",")
+	}
+
+	var allowedChannelIDs []string
+	if *allowedChannels != "" {
+		allowedChannelIDs = strings.Split(*allowedChannels, ",")
+	}
+
+	cooldowns, err := parseCommandCooldowns(*commandCooldowns)
+	if err != nil {
+		log.Fatalf("Invalid cooldowns flag: %v", err)
+	}
+
 	bot, err := discord_bot.New(discord_bot.Config{
-		DevelopmentMode:    devMode,
-		BotToken:           *botToken,
-		GuildID:            *guildID,
-		ImagineQueue:       imagineQueue,
-		ImagineCommand:     *imagineCommand,
-		RemoveCommands:     removeCommands,
-		StableDiffusionAPI: stableDiffusionAPI,
-		StatisticsRepo:     statisticsRepo,
+		DevelopmentMode:         devMode,
+		BotToken:                *botToken,
+		GuildID:                 *guildID,
+		DevelopmentGuildID:      *devGuildID,
+		ImagineQueue:            imagineQueue,
+		ImagineCommand:          *imagineCommand,
+		RemoveCommands:          removeCommands,
+		StableDiffusionAPI:      stableDiffusionAPI,
+		StatisticsRepo:          statisticsRepo,
+		ImageGenerationRepo:     generationRepo,
+		MaxPromptLength:         *maxPromptLength,
+		MaxNegativePromptLength: *maxNegativeLength,
+		RawOverrideBlacklist:    rawOverrideBlacklist,
+		TranslationProvider:     *translationProvider,
+		TranslationAPIKey:       *translationAPIKey,
+		TipsChannelID:           *tipsChannelID,
+		TipsFile:                *tipsFile,
+		TipsTime:                *tipsTime,
+		LatencyAlertThresholdMs: *latencyThreshold,
+		ErrorChannelID:          *errorChannelID,
+		CommandCooldowns:        cooldowns,
+		Version:                 version,
+		OutputChannelID:         *outputChannelID,
+		ChallengeChannelID:      *challengeChannelID,
+		CivitAIAPIKey:           *civitAIAPIKey,
+		AllowedRoleIDs:          allowedRoleIDs,
+		AllowedChannelIDs:       allowedChannelIDs,
 	})
 	if err != nil {
 		log.Fatalf("Error creating Discord bot: %v", err)
@@ -116,3 +262,28 @@ func main() {
 
 	log.Println("Gracefully shutting down.")
 }
+
+// parseCommandCooldowns parses "name=duration" pairs separated by commas
+func parseCommandCooldowns(value string) (map[string]time.Duration, error) {
+	cooldowns := make(map[string]time.Duration)
+
+	if value == "" {
+		return cooldowns, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, durationString, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("expected command=duration, got %q", pair)
+		}
+
+		duration, err := time.ParseDuration(durationString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cooldown of %s: %w", name, err)
+		}
+
+		cooldowns[name] = duration
+	}
+
+	return cooldowns, nil
+}
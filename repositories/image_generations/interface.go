@@ -10,4 +10,8 @@ type Repository interface {
 	Create(ctx context.Context, generation *entities.ImageGeneration) (*entities.ImageGeneration, error)
 	GetByMessage(ctx context.Context, messageID string) (*entities.ImageGeneration, error)
 	GetByMessageAndSort(ctx context.Context, messageID string, sortOrder int) (*entities.ImageGeneration, error)
+	SetPinned(ctx context.Context, messageID string, pinned bool) error
+	MarkDeleted(ctx context.Context, messageID string) error
+	UpdateMessageID(ctx context.Context, oldMessageID, newMessageID string) error
+	GetGenerationLineage(ctx context.Context, messageID string) ([]*entities.ImageGeneration, error)
 }
@@ -7,18 +7,38 @@ import (
 
 	"stable_diffusion_bot/clock"
 	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
 )
 
 const insertGenerationQuery string = `
-INSERT INTO image_generations (interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO image_generations (interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, source_message_id, root_message_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 `
 
 const getGenerationByMessageID string = `
-SELECT id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, created_at FROM image_generations WHERE message_id = ?;
+SELECT id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, pinned, deleted, source_message_id, root_message_id, created_at FROM image_generations WHERE message_id = ?;
 `
 
 const getGenerationByMessageIDAndSortOrder string = `
-SELECT id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, created_at FROM image_generations WHERE message_id = ? AND sort_order = ?;
+SELECT id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, pinned, deleted, source_message_id, root_message_id, created_at FROM image_generations WHERE message_id = ? AND sort_order = ?;
+`
+
+const setPinnedByMessageID string = `
+UPDATE image_generations SET pinned = ? WHERE message_id = ?;
+`
+
+const markDeletedByMessageID string = `
+UPDATE image_generations SET deleted = 1 WHERE message_id = ?;
+`
+
+const updateMessageID string = `
+UPDATE image_generations SET message_id = ? WHERE message_id = ?;
+`
+
+// Selects the first generation of every message of the lineage, the lineage is identified by its root message
+const getGenerationLineage string = `
+SELECT id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, hires_width, hires_height, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, pinned, deleted, source_message_id, root_message_id, created_at FROM image_generations
+WHERE id IN (SELECT MIN(id) FROM image_generations WHERE message_id = ? OR root_message_id = ? GROUP BY message_id)
+ORDER BY id;
 `
 
 type sqliteRepo struct {
@@ -51,7 +71,8 @@ func (repo *sqliteRepo) Create(ctx context.Context, generation *entities.ImageGe
 		generation.NegativePrompt, generation.Width, generation.Height, generation.RestoreFaces,
 		generation.EnableHR, generation.HiresWidth, generation.HiresHeight, generation.DenoisingStrength,
 		generation.BatchSize, generation.Seed, generation.Subseed,
-		generation.SubseedStrength, generation.SamplerName, generation.CfgScale, generation.Steps, generation.Processed, generation.CreatedAt)
+		generation.SubseedStrength, generation.SamplerName, generation.CfgScale, generation.Steps, generation.Processed,
+		generation.SourceMessageID, generation.RootMessageID, generation.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +95,8 @@ func (repo *sqliteRepo) GetByMessage(ctx context.Context, messageID string) (*en
 		&generation.NegativePrompt, &generation.Width, &generation.Height, &generation.RestoreFaces,
 		&generation.EnableHR, &generation.HiresWidth, &generation.HiresHeight, &generation.DenoisingStrength,
 		&generation.BatchSize, &generation.Seed, &generation.Subseed,
-		&generation.SubseedStrength, &generation.SamplerName, &generation.CfgScale, &generation.Steps, &generation.Processed, &generation.CreatedAt)
+		&generation.SubseedStrength, &generation.SamplerName, &generation.CfgScale, &generation.Steps, &generation.Processed, &generation.Pinned, &generation.Deleted,
+		&generation.SourceMessageID, &generation.RootMessageID, &generation.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -90,10 +112,77 @@ func (repo *sqliteRepo) GetByMessageAndSort(ctx context.Context, messageID strin
 		&generation.NegativePrompt, &generation.Width, &generation.Height, &generation.RestoreFaces,
 		&generation.EnableHR, &generation.HiresWidth, &generation.HiresHeight, &generation.DenoisingStrength,
 		&generation.BatchSize, &generation.Seed, &generation.Subseed,
-		&generation.SubseedStrength, &generation.SamplerName, &generation.CfgScale, &generation.Steps, &generation.Processed, &generation.CreatedAt)
+		&generation.SubseedStrength, &generation.SamplerName, &generation.CfgScale, &generation.Steps, &generation.Processed, &generation.Pinned, &generation.Deleted,
+		&generation.SourceMessageID, &generation.RootMessageID, &generation.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
 	return &generation, nil
 }
+
+func (repo *sqliteRepo) SetPinned(ctx context.Context, messageID string, pinned bool) error {
+	_, err := repo.dbConn.ExecContext(ctx, setPinnedByMessageID, pinned, messageID)
+
+	return err
+}
+
+// MarkDeleted soft deletes the generations of the message, keeping the records for statistics
+func (repo *sqliteRepo) MarkDeleted(ctx context.Context, messageID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, markDeletedByMessageID, messageID)
+
+	return err
+}
+
+// UpdateMessageID moves the generations to another message, e.g. when the result is posted separately from the response
+func (repo *sqliteRepo) UpdateMessageID(ctx context.Context, oldMessageID, newMessageID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, updateMessageID, newMessageID, oldMessageID)
+
+	return err
+}
+
+// GetGenerationLineage returns the first generation of every message descending from the root of the message's
+// lineage, ordered by creation. The root comes first
+func (repo *sqliteRepo) GetGenerationLineage(ctx context.Context, messageID string) ([]*entities.ImageGeneration, error) {
+	generation, err := repo.GetByMessage(ctx, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NewNotFoundError("generation")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rootMessageID := generation.RootMessageID
+	if rootMessageID == "" {
+		rootMessageID = generation.MessageID
+	}
+
+	rows, err := repo.dbConn.QueryContext(ctx, getGenerationLineage, rootMessageID, rootMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	lineage := make([]*entities.ImageGeneration, 0)
+
+	for rows.Next() {
+		var generation entities.ImageGeneration
+
+		err = rows.Scan(
+			&generation.ID, &generation.InteractionID, &generation.MessageID, &generation.MemberID, &generation.SortOrder, &generation.Prompt,
+			&generation.NegativePrompt, &generation.Width, &generation.Height, &generation.RestoreFaces,
+			&generation.EnableHR, &generation.HiresWidth, &generation.HiresHeight, &generation.DenoisingStrength,
+			&generation.BatchSize, &generation.Seed, &generation.Subseed,
+			&generation.SubseedStrength, &generation.SamplerName, &generation.CfgScale, &generation.Steps, &generation.Processed, &generation.Pinned, &generation.Deleted,
+			&generation.SourceMessageID, &generation.RootMessageID, &generation.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		lineage = append(lineage, &generation)
+	}
+
+	return lineage, rows.Err()
+}
@@ -0,0 +1,12 @@
+package notification_preferences
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, preference *entities.NotificationPreference) (*entities.NotificationPreference, error)
+	GetByMemberID(ctx context.Context, memberID string) (*entities.NotificationPreference, error)
+}
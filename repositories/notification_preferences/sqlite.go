@@ -0,0 +1,63 @@
+package notification_preferences
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertPreference string = `
+INSERT OR REPLACE INTO notification_preferences (member_id, dm_enabled) VALUES (?, ?);
+`
+
+const getPreferenceByMemberID string = `
+SELECT member_id, dm_enabled FROM notification_preferences WHERE member_id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Upsert(ctx context.Context, preference *entities.NotificationPreference) (*entities.NotificationPreference, error) {
+	_, err := repo.dbConn.ExecContext(ctx, upsertPreference, preference.MemberID, preference.DMEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return preference, nil
+}
+
+func (repo *sqliteRepo) GetByMemberID(ctx context.Context, memberID string) (*entities.NotificationPreference, error) {
+	var preference entities.NotificationPreference
+
+	err := repo.dbConn.QueryRowContext(ctx, getPreferenceByMemberID, memberID).Scan(&preference.MemberID, &preference.DMEnabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("notification preference for member ID %s", memberID))
+		}
+
+		return nil, err
+	}
+
+	return &preference, nil
+}
@@ -0,0 +1,36 @@
+package settings
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+// GuildMemberID is the member ID under which guild-wide settings are stored
+const GuildMemberID = "bot"
+
+// Known setting keys
+const (
+	KeySampler  = "sampler"
+	KeyCFGScale = "cfg_scale"
+	KeySteps    = "steps"
+	// Replaces the default negative prompt
+	KeyNegativePrompt = "negative_prompt"
+	// CLIP layers skipped from the end, the server option is kept when not set
+	KeyClipSkip = "clip_skip"
+	// Guild-wide token merging (ToMe) ratio, 0 disables it and the server option is kept when not set or negative
+	KeyTokenMergingRatio = "token_merging_ratio"
+
+	// Guild timezone as an IANA name, e.g. Europe/Moscow
+	KeyTimezone = "timezone"
+
+	// Prefix of guild-wide custom imagine_ext presets, followed by the preset name
+	KeyPresetPrefix = "preset:"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, setting *entities.UserSetting) (*entities.UserSetting, error)
+	Get(ctx context.Context, guildID, memberID, key string) (*entities.UserSetting, error)
+	GetByMember(ctx context.Context, guildID, memberID string) ([]*entities.UserSetting, error)
+	DeleteByMember(ctx context.Context, guildID, memberID string) error
+}
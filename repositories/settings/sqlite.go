@@ -0,0 +1,102 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertSetting string = `
+INSERT OR REPLACE INTO user_settings (guild_id, member_id, key, value) VALUES (?, ?, ?, ?);
+`
+
+const getSetting string = `
+SELECT guild_id, member_id, key, value FROM user_settings WHERE guild_id = ? AND member_id = ? AND key = ?;
+`
+
+const getSettingsByMember string = `
+SELECT guild_id, member_id, key, value FROM user_settings WHERE guild_id = ? AND member_id = ? ORDER BY key;
+`
+
+const deleteSettingsByMember string = `
+DELETE FROM user_settings WHERE guild_id = ? AND member_id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Upsert(ctx context.Context, setting *entities.UserSetting) (*entities.UserSetting, error) {
+	_, err := repo.dbConn.ExecContext(ctx, upsertSetting, setting.GuildID, setting.MemberID, setting.Key, setting.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return setting, nil
+}
+
+func (repo *sqliteRepo) Get(ctx context.Context, guildID, memberID, key string) (*entities.UserSetting, error) {
+	var setting entities.UserSetting
+
+	err := repo.dbConn.QueryRowContext(ctx, getSetting, guildID, memberID, key).
+		Scan(&setting.GuildID, &setting.MemberID, &setting.Key, &setting.Value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("setting %s for member ID %s", key, memberID))
+		}
+
+		return nil, err
+	}
+
+	return &setting, nil
+}
+
+func (repo *sqliteRepo) GetByMember(ctx context.Context, guildID, memberID string) ([]*entities.UserSetting, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, getSettingsByMember, guildID, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var result []*entities.UserSetting
+
+	for rows.Next() {
+		var setting entities.UserSetting
+
+		err = rows.Scan(&setting.GuildID, &setting.MemberID, &setting.Key, &setting.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &setting)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *sqliteRepo) DeleteByMember(ctx context.Context, guildID, memberID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteSettingsByMember, guildID, memberID)
+
+	return err
+}
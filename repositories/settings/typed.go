@@ -0,0 +1,120 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+// lookup returns the member's own value for the key, falling back to the guild-wide value.
+// ok is false when neither is set
+func lookup(ctx context.Context, repo Repository, guildID, memberID, key string) (value string, ok bool, err error) {
+	for _, id := range []string{memberID, GuildMemberID} {
+		setting, err := repo.Get(ctx, guildID, id, key)
+		if err != nil {
+			if errors.Is(err, &repositories.NotFoundError{}) {
+				continue
+			}
+
+			return "", false, err
+		}
+
+		return setting.Value, true, nil
+	}
+
+	return "", false, nil
+}
+
+func set(ctx context.Context, repo Repository, guildID, memberID, key, value string) error {
+	_, err := repo.Upsert(ctx, &entities.UserSetting{
+		GuildID:  guildID,
+		MemberID: memberID,
+		Key:      key,
+		Value:    value,
+	})
+
+	return err
+}
+
+// GetSampler returns the member's sampler, or the guild's one when the member has none
+func GetSampler(ctx context.Context, repo Repository, guildID, memberID string) (string, bool, error) {
+	return lookup(ctx, repo, guildID, memberID, KeySampler)
+}
+
+// SetSampler sets the member's sampler, or the guild's one for GuildMemberID
+func SetSampler(ctx context.Context, repo Repository, guildID, memberID, sampler string) error {
+	return set(ctx, repo, guildID, memberID, KeySampler, sampler)
+}
+
+// GetCFGScale returns the member's CFG scale, or the guild's one when the member has none
+func GetCFGScale(ctx context.Context, repo Repository, guildID, memberID string) (float64, bool, error) {
+	value, ok, err := lookup(ctx, repo, guildID, memberID, KeyCFGScale)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	cfgScale, err := strconv.ParseFloat(value, 64)
+
+	return cfgScale, err == nil, err
+}
+
+func SetCFGScale(ctx context.Context, repo Repository, guildID, memberID string, cfgScale float64) error {
+	return set(ctx, repo, guildID, memberID, KeyCFGScale, strconv.FormatFloat(cfgScale, 'f', -1, 64))
+}
+
+// GetSteps returns the member's number of steps, or the guild's one when the member has none
+func GetSteps(ctx context.Context, repo Repository, guildID, memberID string) (int, bool, error) {
+	return lookupInt(ctx, repo, guildID, memberID, KeySteps)
+}
+
+func SetSteps(ctx context.Context, repo Repository, guildID, memberID string, steps int) error {
+	return set(ctx, repo, guildID, memberID, KeySteps, strconv.Itoa(steps))
+}
+
+// GetNegativePrompt returns the member's negative prompt, or the guild's one when the member has none
+func GetNegativePrompt(ctx context.Context, repo Repository, guildID, memberID string) (string, bool, error) {
+	return lookup(ctx, repo, guildID, memberID, KeyNegativePrompt)
+}
+
+func SetNegativePrompt(ctx context.Context, repo Repository, guildID, memberID, negativePrompt string) error {
+	return set(ctx, repo, guildID, memberID, KeyNegativePrompt, negativePrompt)
+}
+
+// GetClipSkip returns the member's CLIP skip, or the guild's one when the member has none
+func GetClipSkip(ctx context.Context, repo Repository, guildID, memberID string) (int, bool, error) {
+	return lookupInt(ctx, repo, guildID, memberID, KeyClipSkip)
+}
+
+func SetClipSkip(ctx context.Context, repo Repository, guildID, memberID string, clipSkip int) error {
+	return set(ctx, repo, guildID, memberID, KeyClipSkip, strconv.Itoa(clipSkip))
+}
+
+// GetTokenMergingRatio returns the guild's ToMe ratio, a negative ratio counts as not set
+func GetTokenMergingRatio(ctx context.Context, repo Repository, guildID string) (float64, bool, error) {
+	value, ok, err := lookup(ctx, repo, guildID, GuildMemberID, KeyTokenMergingRatio)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	ratio, err := strconv.ParseFloat(value, 64)
+
+	return ratio, err == nil && ratio >= 0, err
+}
+
+func SetTokenMergingRatio(ctx context.Context, repo Repository, guildID string, ratio float64) error {
+	return set(ctx, repo, guildID, GuildMemberID, KeyTokenMergingRatio, strconv.FormatFloat(ratio, 'f', -1, 64))
+}
+
+func lookupInt(ctx context.Context, repo Repository, guildID, memberID, key string) (int, bool, error) {
+	value, ok, err := lookup(ctx, repo, guildID, memberID, key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	number, err := strconv.Atoi(value)
+
+	return number, err == nil, err
+}
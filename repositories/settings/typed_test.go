@@ -0,0 +1,141 @@
+package settings
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"stable_diffusion_bot/databases/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite.New creates the database file in the working directory
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if chdirErr := os.Chdir(wd); chdirErr != nil {
+			t.Fatal(chdirErr)
+		}
+	}()
+
+	db, err := sqlite.New(context.Background(), "test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	repo, err := NewRepository(&Config{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func TestGetSamplerFallsBackToGuild(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	_, ok, err := GetSampler(ctx, repo, "guild", "member")
+	if err != nil || ok {
+		t.Fatalf("GetSampler() without settings = _, %v, %v, want not set", ok, err)
+	}
+
+	err = SetSampler(ctx, repo, "guild", GuildMemberID, "Euler a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampler, ok, err := GetSampler(ctx, repo, "guild", "member")
+	if err != nil || !ok || sampler != "Euler a" {
+		t.Errorf("GetSampler() = %q, %v, %v, want the guild sampler", sampler, ok, err)
+	}
+
+	err = SetSampler(ctx, repo, "guild", "member", "DPM++ 2M Karras")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampler, ok, err = GetSampler(ctx, repo, "guild", "member")
+	if err != nil || !ok || sampler != "DPM++ 2M Karras" {
+		t.Errorf("GetSampler() = %q, %v, %v, want the member sampler", sampler, ok, err)
+	}
+
+	// Other guilds don't see the settings
+	_, ok, err = GetSampler(ctx, repo, "other guild", "member")
+	if err != nil || ok {
+		t.Errorf("GetSampler() in another guild = _, %v, %v, want not set", ok, err)
+	}
+}
+
+func TestTypedSettingsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	if err := SetCFGScale(ctx, repo, "guild", "member", 7.5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetSteps(ctx, repo, "guild", "member", 30); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetClipSkip(ctx, repo, "guild", GuildMemberID, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfgScale, ok, err := GetCFGScale(ctx, repo, "guild", "member"); err != nil || !ok || cfgScale != 7.5 {
+		t.Errorf("GetCFGScale() = %v, %v, %v, want 7.5", cfgScale, ok, err)
+	}
+
+	if steps, ok, err := GetSteps(ctx, repo, "guild", "member"); err != nil || !ok || steps != 30 {
+		t.Errorf("GetSteps() = %v, %v, %v, want 30", steps, ok, err)
+	}
+
+	if clipSkip, ok, err := GetClipSkip(ctx, repo, "guild", "member"); err != nil || !ok || clipSkip != 2 {
+		t.Errorf("GetClipSkip() = %v, %v, %v, want the guild's 2", clipSkip, ok, err)
+	}
+}
+
+func TestGetTokenMergingRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratio  float64
+		wantOK bool
+	}{
+		// 0 disables token merging, it is not the same as not set
+		{name: "disabled", ratio: 0, wantOK: true},
+		{name: "set", ratio: 0.3, wantOK: true},
+		{name: "server option", ratio: -1, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newTestRepository(t)
+
+			err := SetTokenMergingRatio(ctx, repo, "guild", tt.ratio)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ratio, ok, err := GetTokenMergingRatio(ctx, repo, "guild")
+			if err != nil || ok != tt.wantOK || (ok && ratio != tt.ratio) {
+				t.Errorf("GetTokenMergingRatio() = %v, %v, %v, want %v, %v", ratio, ok, err, tt.ratio, tt.wantOK)
+			}
+		})
+	}
+}
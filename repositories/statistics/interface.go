@@ -2,11 +2,29 @@ package statistics
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"stable_diffusion_bot/entities"
 )
 
 type Repository interface {
 	AddProcessingTime(ctx context.Context, stat *entities.Statistics) (int64, error)
-	GetStatByMember(ctx context.Context, memberID string) (*entities.StatsByMember, error)
+	AddProcessingTimeBatch(ctx context.Context, stats []*entities.Statistics) (int64, error)
+	// GetStatByMember sums up the generations of the member since the given time, all of them for the zero time
+	GetStatByMember(ctx context.Context, memberID string, since time.Time) (*entities.StatsByMember, error)
+	GetGlobalStats(ctx context.Context) (*entities.GlobalStats, error)
+	ExportCSV(ctx context.Context, w io.Writer) error
+	// GetTopNByCount returns the members with the most generations since the given time, all of them for the zero time.
+	// serverID filters by server when not empty
+	GetTopNByCount(ctx context.Context, serverID string, n int, since time.Time) ([]*entities.StatsByMember, error)
+	// GetHourlyDistribution counts the generations by hour of day in UTC, serverID filters by server when not empty
+	GetHourlyDistribution(ctx context.Context, serverID string) ([24]int64, error)
+	// GetMostActiveHour returns the hour of day in UTC with the most generations, serverID filters by server when not empty
+	GetMostActiveHour(ctx context.Context, serverID string) (int, error)
+	GetDailyStats(ctx context.Context, serverID string, days int) ([]*entities.DailyStats, error)
+	GetAverageQueueWaitTime(ctx context.Context, serverID string) (time.Duration, error)
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) error
+	GetQueueWaitPercentiles(ctx context.Context, serverID string, percentiles ...float64) ([]time.Duration, error)
 }
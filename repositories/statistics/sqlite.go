@@ -3,12 +3,57 @@ package statistics
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"stable_diffusion_bot/clock"
 	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
 )
 
+const getStatByMemberQuery string = `
+SELECT
+    IFNULL(s.member_id, '')   AS member_id,
+	SUM(
+		(SELECT COUNT(*) FROM image_generations WHERE interaction_id = ig.interaction_id AND member_id = ig.member_id)
+	) AS count,
+    IFNULL(SUM(time_ms), 0) AS time_ms,
+    IFNULL(SUM(bonus_points), 0) AS bonus_points
+FROM statistics s
+INNER JOIN image_generations AS ig
+    ON ig.id = s.image_generation_id
+WHERE s.member_id = ? AND s.created_at >= ?`
+
+const getGlobalStatsQuery string = `
+SELECT
+	IFNULL(SUM(
+		(SELECT COUNT(*) FROM image_generations WHERE interaction_id = ig.interaction_id AND member_id = ig.member_id)
+	), 0) AS count,
+    IFNULL(SUM(time_ms), 0) AS time_ms
+FROM statistics s
+INNER JOIN image_generations AS ig
+    ON ig.id = s.image_generation_id`
+
+// Matches the start of the stored created_at, see getHourlyDistributionQuery
+const sinceFormat = "2006-01-02 15:04:05"
+
+// sinceParam returns the lower bound of created_at for the time in the bot's local time,
+// which is how created_at is stored. The zero time matches all rows
+func sinceParam(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+
+	return since.Local().Format(sinceFormat)
+}
+
 type sqliteRepo struct {
 	dbConn *sql.DB
 	clock  clock.Clock
@@ -18,6 +63,8 @@ type Config struct {
 	DB *sql.DB
 }
 
+// NewRepository expects a database opened by sqlite.New: the statistics table and its later columns
+// are created by the numbered migrations in databases/sqlite, which run once per database version
 func NewRepository(cfg *Config) (Repository, error) {
 	if cfg.DB == nil {
 		return nil, errors.New("missing DB parameter")
@@ -34,20 +81,75 @@ func NewRepository(cfg *Config) (Repository, error) {
 func (repo *sqliteRepo) AddProcessingTime(ctx context.Context, stat *entities.Statistics) (int64, error) {
 	stat.CreatedAt = repo.clock.Now()
 
-	res, err := repo.dbConn.ExecContext(ctx, `INSERT INTO statistics (image_generation_id, member_id, time_ms, created_at) VALUES (?,?,?,?)`,
-		stat.ImageGenerationID, stat.MemberID, stat.TimeMs, stat.CreatedAt)
+	res, err := repo.dbConn.ExecContext(ctx, `INSERT INTO statistics (image_generation_id, member_id, server_id, time_ms, queue_wait_ms, bonus_points, created_at) VALUES (?,?,?,?,?,?,?)`,
+		stat.ImageGenerationID, stat.MemberID, stat.ServerID, stat.TimeMs, stat.QueueWaitMs, stat.BonusPoints, stat.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// AddProcessingTimeBatch inserts all statistics in a single statement, so either all or none of them are stored
+func (repo *sqliteRepo) AddProcessingTimeBatch(ctx context.Context, stats []*entities.Statistics) (int64, error) {
+	if len(stats) == 0 {
+		return 0, nil
+	}
+
+	now := repo.clock.Now()
+
+	placeholders := make([]string, 0, len(stats))
+	args := make([]interface{}, 0, len(stats)*7)
+
+	for _, stat := range stats {
+		stat.CreatedAt = now
+
+		placeholders = append(placeholders, "(?,?,?,?,?,?,?)")
+		args = append(args, stat.ImageGenerationID, stat.MemberID, stat.ServerID, stat.TimeMs, stat.QueueWaitMs, stat.BonusPoints, stat.CreatedAt)
+	}
+
+	res, err := repo.dbConn.ExecContext(ctx,
+		`INSERT INTO statistics (image_generation_id, member_id, server_id, time_ms, queue_wait_ms, bonus_points, created_at) VALUES `+strings.Join(placeholders, ","),
+		args...)
 	if err != nil {
 		return 0, err
 	}
+
 	return res.RowsAffected()
 }
 
-func (repo *sqliteRepo) GetStatByMember(ctx context.Context, memberID string) (*entities.StatsByMember, error) {
+func (repo *sqliteRepo) GetStatByMember(ctx context.Context, memberID string, since time.Time) (*entities.StatsByMember, error) {
 	var result entities.StatsByMember
 
-	err := repo.dbConn.QueryRowContext(ctx, `
+	err := repo.dbConn.QueryRowContext(ctx, getStatByMemberQuery, memberID, sinceParam(since)).
+		Scan(&result.MemberID, &result.Count, &result.TimeMs, &result.BonusPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.MemberID == "" {
+		return nil, nil
+	}
+
+	return &result, nil
+}
+
+// GetGlobalStats sums up the generations of all members on all servers
+func (repo *sqliteRepo) GetGlobalStats(ctx context.Context) (*entities.GlobalStats, error) {
+	var result entities.GlobalStats
+
+	err := repo.dbConn.QueryRowContext(ctx, getGlobalStatsQuery).Scan(&result.Count, &result.TimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (repo *sqliteRepo) GetTopNByCount(ctx context.Context, serverID string, n int, since time.Time) ([]*entities.StatsByMember, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, `
 SELECT
-    IFNULL(s.member_id, '')   AS member_id,
+    s.member_id,
 	SUM(
 		(SELECT COUNT(*) FROM image_generations WHERE interaction_id = ig.interaction_id AND member_id = ig.member_id)
 	) AS count,
@@ -55,15 +157,342 @@ SELECT
 FROM statistics s
 INNER JOIN image_generations AS ig
     ON ig.id = s.image_generation_id
-WHERE s.member_id = ?`, memberID).
-		Scan(&result.MemberID, &result.Count, &result.TimeMs)
+WHERE (? = '' OR s.server_id = ?) AND s.created_at >= ?
+GROUP BY s.member_id
+ORDER BY count DESC
+LIMIT ?`, serverID, serverID, sinceParam(since), n)
 	if err != nil {
 		return nil, err
 	}
 
-	if result.MemberID == "" {
-		return nil, nil
+	defer rows.Close()
+
+	var result []*entities.StatsByMember
+
+	for rows.Next() {
+		var stat entities.StatsByMember
+
+		err = rows.Scan(&stat.MemberID, &stat.Count, &stat.TimeMs)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &stat)
 	}
 
-	return &result, nil
+	return result, rows.Err()
+}
+
+// The driver stores times as "2006-01-02 15:04:05.999999999 -0700 MST", which SQLite date functions
+// can't parse, so the hour and the UTC offset are cut out of the string
+const getHourlyDistributionQuery string = `
+SELECT
+    CAST(substr(created_at, 12, 2) AS INTEGER) AS hour,
+    substr(created_at, instr(substr(created_at, 12), ' ') + 12, 5) AS utc_offset,
+    COUNT(*) AS count
+FROM statistics
+WHERE ? = '' OR server_id = ?
+GROUP BY hour, utc_offset`
+
+// GetHourlyDistribution counts the statistics rows by hour of day in UTC
+func (repo *sqliteRepo) GetHourlyDistribution(ctx context.Context, serverID string) ([24]int64, error) {
+	var result [24]int64
+
+	rows, err := repo.dbConn.QueryContext(ctx, getHourlyDistributionQuery, serverID, serverID)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var hour int
+
+		var utcOffset string
+
+		var count int64
+
+		err = rows.Scan(&hour, &utcOffset, &count)
+		if err != nil {
+			return result, err
+		}
+
+		offset, parseErr := time.Parse("-0700", utcOffset)
+		if parseErr != nil {
+			return result, fmt.Errorf("unexpected created_at UTC offset %q: %w", utcOffset, parseErr)
+		}
+
+		_, offsetSeconds := offset.Zone()
+		utcHour := ((hour-offsetSeconds/3600)%24 + 24) % 24
+
+		result[utcHour] += count
+	}
+
+	return result, rows.Err()
+}
+
+// GetMostActiveHour returns the hour of day in UTC with the most generations over all time.
+// created_at isn't in a format SQLite date functions understand, so the hourly distribution is used
+func (repo *sqliteRepo) GetMostActiveHour(ctx context.Context, serverID string) (int, error) {
+	distribution, err := repo.GetHourlyDistribution(ctx, serverID)
+	if err != nil {
+		return 0, err
+	}
+
+	peakHour := -1
+
+	var peakCount int64
+
+	for hour, count := range distribution {
+		if count > peakCount {
+			peakHour = hour
+			peakCount = count
+		}
+	}
+
+	if peakHour < 0 {
+		return 0, repositories.NewNotFoundError(fmt.Sprintf("statistics for server ID %s", serverID))
+	}
+
+	return peakHour, nil
+}
+
+const dailyStatsDateFormat = "2006-01-02"
+
+// GetDailyStats returns the statistics of the last days, today included, oldest first.
+// Days without generations are returned with zero counts
+func (repo *sqliteRepo) GetDailyStats(ctx context.Context, serverID string, days int) ([]*entities.DailyStats, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("invalid number of days: %d", days)
+	}
+
+	now := repo.clock.Now()
+	firstDay := now.AddDate(0, 0, -(days - 1)).Format(dailyStatsDateFormat)
+
+	// created_at starts with the date in the bot's local time, see getHourlyDistributionQuery
+	rows, err := repo.dbConn.QueryContext(ctx, `
+SELECT
+    substr(created_at, 1, 10) AS day,
+    COUNT(*) AS count,
+    IFNULL(SUM(time_ms), 0) AS time_ms
+FROM statistics
+WHERE substr(created_at, 1, 10) >= ? AND (? = '' OR server_id = ?)
+GROUP BY day`, firstDay, serverID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	byDate := make(map[string]*entities.DailyStats, days)
+
+	for rows.Next() {
+		var stat entities.DailyStats
+
+		err = rows.Scan(&stat.Date, &stat.Count, &stat.TotalTimeMs)
+		if err != nil {
+			return nil, err
+		}
+
+		byDate[stat.Date] = &stat
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*entities.DailyStats, 0, days)
+
+	for offset := days - 1; offset >= 0; offset-- {
+		date := now.AddDate(0, 0, -offset).Format(dailyStatsDateFormat)
+
+		stat, ok := byDate[date]
+		if !ok {
+			stat = &entities.DailyStats{Date: date}
+		}
+
+		result = append(result, stat)
+	}
+
+	return result, nil
+}
+
+// Queue wait times are computed over the latest rows, so they reflect the current load
+const queueWaitSampleSize = 1000
+
+const getQueueWaitSampleQuery string = `
+SELECT queue_wait_ms
+FROM statistics
+WHERE queue_wait_ms IS NOT NULL AND (? = '' OR server_id = ?)
+ORDER BY id DESC
+LIMIT ?`
+
+// GetAverageQueueWaitTime returns the average queue wait of the latest generations, serverID filters by server when not empty
+func (repo *sqliteRepo) GetAverageQueueWaitTime(ctx context.Context, serverID string) (time.Duration, error) {
+	var averageMs float64
+
+	err := repo.dbConn.QueryRowContext(ctx, `SELECT IFNULL(AVG(queue_wait_ms), 0) FROM (`+getQueueWaitSampleQuery+`)`,
+		serverID, serverID, queueWaitSampleSize).
+		Scan(&averageMs)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(averageMs * float64(time.Millisecond)), nil
+}
+
+// GetQueueWaitPercentiles returns the queue wait percentiles, e.g. 50 and 95, of the latest generations.
+// They are zero when there are no generations
+func (repo *sqliteRepo) GetQueueWaitPercentiles(ctx context.Context, serverID string, percentiles ...float64) ([]time.Duration, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, getQueueWaitSampleQuery, serverID, serverID, queueWaitSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sample := make([]int64, 0, queueWaitSampleSize)
+
+	for rows.Next() {
+		var waitMs int64
+
+		err = rows.Scan(&waitMs)
+		if err != nil {
+			return nil, err
+		}
+
+		sample = append(sample, waitMs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sample, func(i, j int) bool { return sample[i] < sample[j] })
+
+	result := make([]time.Duration, len(percentiles))
+
+	if len(sample) == 0 {
+		return result, nil
+	}
+
+	for idx, percentile := range percentiles {
+		// Nearest rank
+		rank := int(math.Ceil(percentile/100*float64(len(sample)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+
+		if rank >= len(sample) {
+			rank = len(sample) - 1
+		}
+
+		result[idx] = time.Duration(sample[rank]) * time.Millisecond
+	}
+
+	return result, nil
+}
+
+const exportFlushRows = 1000
+
+// ExportCSV streams all statistics rows with their image generation parameters as RFC 4180 CSV
+func (repo *sqliteRepo) ExportCSV(ctx context.Context, w io.Writer) error {
+	rows, err := repo.dbConn.QueryContext(ctx, `
+SELECT
+    s.id,
+    s.image_generation_id,
+    s.member_id,
+    s.server_id,
+    s.time_ms,
+    s.created_at,
+    IFNULL(ig.prompt, ''),
+    IFNULL(ig.negative_prompt, ''),
+    IFNULL(ig.width, 0),
+    IFNULL(ig.height, 0),
+    IFNULL(ig.sampler_name, ''),
+    IFNULL(ig.cfg_scale, 0),
+    IFNULL(ig.steps, 0),
+    IFNULL(ig.seed, 0)
+FROM statistics s
+LEFT JOIN image_generations AS ig
+    ON ig.id = s.image_generation_id
+ORDER BY s.id`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	writer := csv.NewWriter(w)
+
+	err = writer.Write([]string{
+		"id", "image_generation_id", "member_id", "server_id", "time_ms", "created_at",
+		"prompt", "negative_prompt", "width", "height", "sampler_name", "cfg_scale", "steps", "seed",
+	})
+	if err != nil {
+		return err
+	}
+
+	rowCount := 0
+
+	for rows.Next() {
+		var (
+			stat           entities.Statistics
+			prompt         string
+			negativePrompt string
+			width          int
+			height         int
+			samplerName    string
+			cfgScale       float64
+			steps          int
+			seed           int
+		)
+
+		err = rows.Scan(&stat.ID, &stat.ImageGenerationID, &stat.MemberID, &stat.ServerID, &stat.TimeMs, &stat.CreatedAt,
+			&prompt, &negativePrompt, &width, &height, &samplerName, &cfgScale, &steps, &seed)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write([]string{
+			strconv.FormatInt(stat.ID, 10),
+			strconv.FormatInt(stat.ImageGenerationID, 10),
+			stat.MemberID,
+			stat.ServerID,
+			strconv.FormatInt(stat.TimeMs, 10),
+			stat.CreatedAt.Format(time.RFC3339),
+			prompt,
+			negativePrompt,
+			strconv.Itoa(width),
+			strconv.Itoa(height),
+			samplerName,
+			strconv.FormatFloat(cfgScale, 'f', -1, 64),
+			strconv.Itoa(steps),
+			strconv.Itoa(seed),
+		})
+		if err != nil {
+			return err
+		}
+
+		rowCount++
+		if rowCount%exportFlushRows == 0 {
+			writer.Flush()
+
+			if err = writer.Error(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func (repo *sqliteRepo) Ping(ctx context.Context) error {
+	return repo.dbConn.PingContext(ctx)
 }
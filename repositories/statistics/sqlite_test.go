@@ -0,0 +1,257 @@
+package statistics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"stable_diffusion_bot/databases/sqlite"
+	"stable_diffusion_bot/entities"
+)
+
+const (
+	benchMembers   = 1000
+	benchBatchSize = 1000
+)
+
+// BenchmarkGetStatByMember measures the member statistics query as the statistics table grows:
+//
+//	go test -run '^$' -bench GetStatByMember ./repositories/statistics/
+func BenchmarkGetStatByMember(b *testing.B) {
+	for _, rows := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			ctx := context.Background()
+
+			db := openTestDB(b)
+			seedStatistics(b, db, rows)
+
+			assertMemberIndexUsed(b, db)
+
+			repo, err := NewRepository(&Config{DB: db})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				stat, err := repo.GetStatByMember(ctx, benchMemberID(n), time.Time{})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if stat == nil {
+					b.Fatalf("no statistics for member %s", benchMemberID(n))
+				}
+			}
+		})
+	}
+}
+
+// openTestDB creates a migrated database in a temporary directory, sqlite.New places the file
+// in the working directory
+func openTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	err = os.Chdir(tb.TempDir())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	defer func() {
+		if chdirErr := os.Chdir(wd); chdirErr != nil {
+			tb.Fatal(chdirErr)
+		}
+	}()
+
+	db, err := sqlite.New(context.Background(), "bench_")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	// Durability doesn't matter for a throwaway database, and seeding a million rows is slow without it
+	_, err = db.Exec(`PRAGMA synchronous = OFF`)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+func benchMemberID(n int) string {
+	return fmt.Sprintf("member-%d", n%benchMembers)
+}
+
+// seedStatistics inserts the image generations and one statistics row for each of them,
+// spread evenly across benchMembers members
+func seedStatistics(b *testing.B, db *sql.DB, rows int) {
+	b.Helper()
+
+	ctx := context.Background()
+	createdAt := time.Now()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	//nolint
+	defer tx.Rollback()
+
+	insertGeneration, err := tx.PrepareContext(ctx, `
+INSERT INTO image_generations (id, interaction_id, message_id, member_id, sort_order, prompt, negative_prompt, width, height, restore_faces, enable_hr, denoising_strength, batch_size, seed, subseed, subseed_strength, sampler_name, cfg_scale, steps, processed, created_at)
+VALUES (?, ?, ?, ?, 0, 'prompt', '', 512, 512, 0, 0, 0, 1, -1, -1, 0, 'Euler a', 7, 20, 1, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for id := 1; id <= rows; id++ {
+		interactionID := fmt.Sprintf("interaction-%d", id)
+
+		_, err = insertGeneration.ExecContext(ctx, id, interactionID, interactionID, benchMemberID(id), createdAt)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	repo, err := NewRepository(&Config{DB: db})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	batch := make([]*entities.Statistics, 0, benchBatchSize)
+
+	for id := 1; id <= rows; id++ {
+		batch = append(batch, &entities.Statistics{
+			ImageGenerationID: int64(id),
+			MemberID:          benchMemberID(id),
+			TimeMs:            int64(1000 + id%5000),
+		})
+
+		if len(batch) == benchBatchSize || id == rows {
+			_, err = repo.AddProcessingTimeBatch(ctx, batch)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			batch = batch[:0]
+		}
+	}
+
+	_, err = db.ExecContext(ctx, `ANALYZE`)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// assertMemberIndexUsed fails the benchmark if the statistics table is scanned instead of
+// searched through member_id_idx
+func assertMemberIndexUsed(b *testing.B, db *sql.DB) {
+	b.Helper()
+
+	planRows, err := db.Query(`EXPLAIN QUERY PLAN `+getStatByMemberQuery, benchMemberID(0), "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer planRows.Close()
+
+	var plan []string
+
+	for planRows.Next() {
+		var id, parent, notUsed int
+
+		var detail string
+
+		err = planRows.Scan(&id, &parent, &notUsed, &detail)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		plan = append(plan, detail)
+	}
+
+	if err = planRows.Err(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, detail := range plan {
+		if strings.Contains(detail, "member_id_idx") {
+			return
+		}
+	}
+
+	b.Fatalf("GetStatByMember doesn't use member_id_idx, query plan:\n%s", strings.Join(plan, "\n"))
+}
+
+func TestAddProcessingTimeBatchIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	db := openTestDB(t)
+
+	// Rejects the second row of the batch, after the first one was inserted
+	_, err := db.ExecContext(ctx, `
+CREATE TRIGGER reject_member BEFORE INSERT ON statistics
+WHEN NEW.member_id = 'rejected'
+BEGIN
+	SELECT RAISE(ABORT, 'rejected member');
+END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewRepository(&Config{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.AddProcessingTimeBatch(ctx, []*entities.Statistics{
+		{ImageGenerationID: 1, MemberID: "accepted", TimeMs: 1000},
+		{ImageGenerationID: 2, MemberID: "rejected", TimeMs: 1000},
+		{ImageGenerationID: 3, MemberID: "accepted", TimeMs: 1000},
+	})
+	if err == nil {
+		t.Fatal("AddProcessingTimeBatch() error = nil, want the trigger error")
+	}
+
+	var count int
+
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM statistics`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("statistics rows after the failed batch = %d, want 0", count)
+	}
+
+	added, err := repo.AddProcessingTimeBatch(ctx, []*entities.Statistics{
+		{ImageGenerationID: 1, MemberID: "accepted", TimeMs: 1000},
+		{ImageGenerationID: 3, MemberID: "accepted", TimeMs: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if added != 2 {
+		t.Errorf("AddProcessingTimeBatch() = %d, want 2", added)
+	}
+}
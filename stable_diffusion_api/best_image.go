@@ -0,0 +1,141 @@
+package stable_diffusion_api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ImageHeuristic scores an image, the image with the highest score is considered the best
+type ImageHeuristic int
+
+const (
+	// HeuristicFirst always picks the first image
+	HeuristicFirst ImageHeuristic = iota
+	// HeuristicVariance picks the image with the highest luminance variance, a proxy for detail
+	HeuristicVariance
+	// HeuristicCenterSharpness picks the image with the sharpest center, measured by the Laplacian variance
+	// of the central crop
+	HeuristicCenterSharpness
+)
+
+// BestImage returns the most detailed image by luminance variance
+func (resp *TextToImageResponse) BestImage() string {
+	return resp.BestImageBy(HeuristicVariance)
+}
+
+// BestImageBy returns the image with the highest score by the heuristic. Images that can't be decoded
+// (only PNG and JPEG are supported) are skipped, Images[0] is returned when none of them can be scored
+func (resp *TextToImageResponse) BestImageBy(heuristic ImageHeuristic) string {
+	if len(resp.Images) == 0 {
+		return ""
+	}
+
+	if heuristic == HeuristicFirst {
+		return resp.Images[0]
+	}
+
+	best := resp.Images[0]
+	bestScore := -1.0
+
+	for _, encoded := range resp.Images {
+		img, err := decodeBase64Image(encoded)
+		if err != nil {
+			continue
+		}
+
+		var score float64
+
+		switch heuristic {
+		case HeuristicCenterSharpness:
+			score = laplacianVariance(img, centerCrop(img.Bounds()))
+		default:
+			score = luminanceVariance(img)
+		}
+
+		if score > bestScore {
+			best = encoded
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+func decodeBase64Image(encoded string) (image.Image, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(decoded))
+
+	return img, err
+}
+
+// luminance returns the pixel brightness in the 0..255 range
+func luminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+func luminanceVariance(img image.Image) float64 {
+	bounds := img.Bounds()
+
+	var sum, sumSquares, count float64
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			l := luminance(img, x, y)
+			sum += l
+			sumSquares += l * l
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	mean := sum / count
+
+	return sumSquares/count - mean*mean
+}
+
+// centerCrop returns the central half of the bounds
+func centerCrop(bounds image.Rectangle) image.Rectangle {
+	dx := bounds.Dx() / 4
+	dy := bounds.Dy() / 4
+
+	return image.Rect(bounds.Min.X+dx, bounds.Min.Y+dy, bounds.Max.X-dx, bounds.Max.Y-dy)
+}
+
+// laplacianVariance computes the variance of the Laplacian of the luminance inside the rectangle,
+// higher values mean more edges
+func laplacianVariance(img image.Image, rect image.Rectangle) float64 {
+	bounds := img.Bounds()
+	rect = rect.Intersect(bounds.Inset(1))
+
+	var sum, sumSquares, count float64
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			laplacian := luminance(img, x-1, y) + luminance(img, x+1, y) +
+				luminance(img, x, y-1) + luminance(img, x, y+1) - 4*luminance(img, x, y)
+			sum += laplacian
+			sumSquares += laplacian * laplacian
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	mean := sum / count
+
+	return sumSquares/count - mean*mean
+}
@@ -0,0 +1,75 @@
+package stable_diffusion_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// ControlNet extension https://github.com/Mikubill/sd-webui-controlnet
+const controlNetScript = "controlnet"
+
+type jsonControlNetModelsResponse struct {
+	ModelList []string `json:"model_list"`
+}
+
+// GetControlNetModels returns the models of the ControlNet extension, none when the extension isn't installed
+func (api *apiImpl) GetControlNetModels(ctx context.Context) ([]string, error) {
+	body, status, err := api.get(ctx, "/controlnet/model_list")
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	respStruct := &jsonControlNetModelsResponse{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return respStruct.ModelList, nil
+}
+
+type ControlNetOptions struct {
+	// Base64 encoded control image
+	Image string
+	// Model title as returned by GetControlNetModels
+	Model string
+	// Preprocessor applied to Image, "none" when it's already a control map like a depth map or a pose
+	Module string
+}
+
+// ControlNetScripts returns the alwayson_scripts value enabling a single ControlNet unit
+func ControlNetScripts(opts ControlNetOptions) map[string]interface{} {
+	module := opts.Module
+	if module == "" {
+		module = "none"
+	}
+
+	return map[string]interface{}{
+		controlNetScript: map[string]interface{}{
+			"args": []interface{}{
+				map[string]interface{}{
+					"enabled":       true,
+					"input_image":   opts.Image,
+					"model":         opts.Model,
+					"module":        module,
+					"weight":        1.0,
+					"pixel_perfect": true,
+				},
+			},
+		},
+	}
+}
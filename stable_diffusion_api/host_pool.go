@@ -0,0 +1,173 @@
+package stable_diffusion_api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Connection errors in a row that take a host out of the rotation
+	hostFailureThreshold = 3
+	// Time a failing host stays out of the rotation before it gets requests again
+	hostCooldown = 30 * time.Second
+)
+
+type poolHost struct {
+	url string
+	// scheme and host of url, to match the requests passing through hostPoolTransport
+	origin string
+
+	failures      int
+	disabledUntil time.Time
+}
+
+// hostPool distributes the requests over the webui instances round-robin, skipping the failing ones
+type hostPool struct {
+	hosts   []*poolHost
+	counter atomic.Uint32
+
+	mu sync.Mutex
+	// host that got the latest generation request, asked for the progress
+	generationHost *poolHost
+}
+
+func newHostPool(hosts []string) (*hostPool, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("missing host")
+	}
+
+	pool := &hostPool{}
+
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.TrimSpace(host), "/")
+		if host == "" {
+			return nil, errors.New("missing host")
+		}
+
+		hostURL, err := url.Parse(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host %s: %w", host, err)
+		}
+
+		pool.hosts = append(pool.hosts, &poolHost{
+			url:    host,
+			origin: hostURL.Scheme + "://" + hostURL.Host,
+		})
+	}
+
+	pool.generationHost = pool.hosts[0]
+
+	return pool, nil
+}
+
+// next returns the next host in the rotation. When all hosts are failing it's returned anyway
+func (p *hostPool) next() string {
+	return p.pick().url
+}
+
+// nextGeneration returns the next host and remembers it for the progress requests
+func (p *hostPool) nextGeneration() string {
+	host := p.pick()
+
+	p.mu.Lock()
+	p.generationHost = host
+	p.mu.Unlock()
+
+	return host.url
+}
+
+// progress returns the host of the latest generation. With several workers it may be another running generation
+func (p *hostPool) progress() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.generationHost.url
+}
+
+// all returns every host, for the requests changing the server state
+func (p *hostPool) all() []string {
+	urls := make([]string, len(p.hosts))
+
+	for i, host := range p.hosts {
+		urls[i] = host.url
+	}
+
+	return urls
+}
+
+func (p *hostPool) pick() *poolHost {
+	now := time.Now()
+	first := p.hosts[(p.counter.Add(1)-1)%uint32(len(p.hosts))]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	host := first
+
+	for i := 1; host.disabledUntil.After(now) && i < len(p.hosts); i++ {
+		host = p.hosts[(p.counter.Add(1)-1)%uint32(len(p.hosts))]
+	}
+
+	if host.disabledUntil.After(now) {
+		return first
+	}
+
+	return host
+}
+
+// report counts the connection errors of the request URL's host, a response resets the count
+func (p *hostPool) report(requestURL *url.URL, err error) {
+	origin := requestURL.Scheme + "://" + requestURL.Host
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, host := range p.hosts {
+		if host.origin != origin {
+			continue
+		}
+
+		if err == nil {
+			if host.failures >= hostFailureThreshold {
+				log.Printf("API host %s is back in the rotation", host.url)
+			}
+
+			host.failures = 0
+
+			continue
+		}
+
+		host.failures++
+
+		// After the cooldown a single failure is enough to take the host out again
+		if host.failures >= hostFailureThreshold && len(p.hosts) > 1 {
+			host.disabledUntil = time.Now().Add(hostCooldown)
+
+			log.Printf("API host %s failed %d times in a row, removed from the rotation for %v", host.url, host.failures, hostCooldown)
+		}
+	}
+}
+
+// hostPoolTransport reports the result of every request to the pool
+type hostPoolTransport struct {
+	inner http.RoundTripper
+	pool  *hostPool
+}
+
+func (t *hostPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+
+	// Cancelled requests say nothing about the host
+	if req.Context().Err() == nil {
+		t.pool.report(req.URL, err)
+	}
+
+	return resp, err
+}
@@ -0,0 +1,81 @@
+package stable_diffusion_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ResizeMode is how the webui fits the init images into the requested width and height
+type ResizeMode int
+
+const (
+	// ResizeModeJustResize stretches the image to the requested size
+	ResizeModeJustResize ResizeMode = iota
+	// ResizeModeCropAndResize scales the image to cover the requested size and crops the overflow
+	ResizeModeCropAndResize
+	// ResizeModeResizeAndFill scales the image to fit the requested size and fills the empty space
+	ResizeModeResizeAndFill
+)
+
+type ImageToImageRequest struct {
+	// Base64 encoded source images
+	InitImages        []string   `json:"init_images"`
+	ResizeMode        ResizeMode `json:"resize_mode"`
+	Prompt            string     `json:"prompt"`
+	NegativePrompt    string     `json:"negative_prompt"`
+	Width             int        `json:"width"`
+	Height            int        `json:"height"`
+	RestoreFaces      bool       `json:"restore_faces"`
+	DenoisingStrength float64    `json:"denoising_strength"`
+	BatchSize         int        `json:"batch_size"`
+	Seed              int        `json:"seed"`
+	Subseed           int        `json:"subseed"`
+	SubseedStrength   float64    `json:"subseed_strength"`
+	SamplerName       string     `json:"sampler_name"`
+	CfgScale          float64    `json:"cfg_scale"`
+	Steps             int        `json:"steps"`
+	NIter             int        `json:"n_iter"`
+
+	// Save sample images AND grid copies to output dir
+	SaveImages       bool                    `json:"save_images"`
+	OverrideSettings Txt2ImgOverrideSettings `json:"override_settings"`
+}
+
+// Validate checks the request, the API fails with a server error on these instead of a validation error
+func (req *ImageToImageRequest) Validate() error {
+	if len(req.InitImages) == 0 {
+		return errors.New("missing init image")
+	}
+
+	if req.Steps < 1 {
+		return fmt.Errorf("invalid steps count: %d", req.Steps)
+	}
+
+	if req.DenoisingStrength < 0 || req.DenoisingStrength > 1 {
+		return fmt.Errorf("invalid denoising strength: %v", req.DenoisingStrength)
+	}
+
+	return nil
+}
+
+func (api *apiImpl) ImageToImage(ctx context.Context, req *ImageToImageRequest) (*TextToImageResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+
+	err := req.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	postURL := api.hosts.nextGeneration() + "/sdapi/v1/img2img"
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.generate(ctx, postURL, jsonData)
+}
@@ -1,8 +1,33 @@
 package stable_diffusion_api
 
+import "context"
+
 type StableDiffusionAPI interface {
-	TextToImage(req *TextToImageRequest) (*TextToImageResponse, error)
-	UpscaleImage(upscaleReq *UpscaleRequest) (*UpscaleResponse, error)
-	GetCurrentProgress() (*ProgressResponse, error)
-	GetEmbeddings() (*EmbeddingsResponseMinimal, error)
+	TextToImage(ctx context.Context, req *TextToImageRequest) (*TextToImageResponse, error)
+	ImageToImage(ctx context.Context, req *ImageToImageRequest) (*TextToImageResponse, error)
+	UpscaleImage(ctx context.Context, upscaleReq *UpscaleRequest) (*UpscaleResponse, error)
+	GetCurrentProgress(ctx context.Context, skipImage bool) (*ProgressResponse, error)
+	Interrupt(ctx context.Context) error
+	GetEmbeddings(ctx context.Context) (*EmbeddingsResponseMinimal, error)
+	GetEmbeddingsFull(ctx context.Context) (*EmbeddingsResponse, error)
+	GetSDOptions(ctx context.Context) (*SDOptions, error)
+	SetSDOptions(ctx context.Context, options *SDOptions) error
+	GetCurrentModel(ctx context.Context) (string, error)
+	SetModel(ctx context.Context, model string) error
+	GetModels(ctx context.Context) ([]*SDModel, error)
+	GetVAEs(ctx context.Context) ([]*VAEInfo, error)
+	SetVAE(ctx context.Context, name string) error
+	GetExtensions(ctx context.Context) ([]*Extension, error)
+	GetPNGInfo(ctx context.Context, imageBase64 string) (*PNGInfoResponse, error)
+	Interrogate(ctx context.Context, imageBase64, model string) (string, error)
+	GetUpscalers(ctx context.Context) ([]*Upscaler, error)
+	GetRealesrganModels(ctx context.Context) ([]string, error)
+	GetControlNetModels(ctx context.Context) ([]string, error)
+	GetLoRAModels(ctx context.Context) ([]*LoRAModel, error)
+	GetSystemInfo(ctx context.Context) (*SystemInfo, error)
+	GetSamplers(ctx context.Context) ([]*SamplerInfo, error)
+	GetSamplerAliases(ctx context.Context) (map[string]string, error)
+	CountTokens(ctx context.Context, prompt string) (int, error)
+	HealthCheck(ctx context.Context) error
+	WebUIAvailable(ctx context.Context) bool
 }
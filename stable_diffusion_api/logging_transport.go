@@ -0,0 +1,95 @@
+package stable_diffusion_api
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+)
+
+const maxLoggedBodyLength = 512
+
+// loggingTransport logs every request and response passing through the inner transport.
+// Only the beginning of the bodies is read for the log, the rest is streamed as is
+type loggingTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+
+	if req.Body != nil {
+		var err error
+
+		// The caller's request must not be modified
+		req = req.Clone(req.Context())
+
+		original := req.Body
+
+		reqBody, req.Body, err = peekBody(original)
+		if err != nil {
+			_ = original.Close()
+
+			return nil, err
+		}
+	}
+
+	log.Printf("API request: %s %s\nHeaders: %v\nBody: %s", req.Method, req.URL, redactedHeaders(req.Header), truncateBody(reqBody))
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		log.Printf("API request error: %s %s: %v", req.Method, req.URL, err)
+
+		return nil, err
+	}
+
+	respBody, body, err := peekBody(resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+
+		return nil, err
+	}
+
+	resp.Body = body
+
+	log.Printf("API response: %s %s: %s\nHeaders: %v\nBody: %s", req.Method, req.URL, resp.Status, resp.Header, truncateBody(respBody))
+
+	return resp, nil
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// peekBody reads up to maxLoggedBodyLength + 1 bytes of the body, the extra byte tells whether it was truncated.
+// The returned body yields the whole content again and closes the original one
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	prefix, err := io.ReadAll(io.LimitReader(body, maxLoggedBodyLength+1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return prefix, &peekedBody{Reader: io.MultiReader(bytes.NewReader(prefix), body), Closer: body}, nil
+}
+
+func truncateBody(body []byte) string {
+	if len(body) > maxLoggedBodyLength {
+		return string(body[:maxLoggedBodyLength]) + "..."
+	}
+
+	return string(body)
+}
+
+// redactedHeaders returns a copy of the headers without the credentials
+func redactedHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+
+	for _, name := range []string{"Authorization", "X-Api-Key"} {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[redacted]")
+		}
+	}
+
+	return redacted
+}
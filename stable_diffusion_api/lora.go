@@ -0,0 +1,40 @@
+package stable_diffusion_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+type LoRAModel struct {
+	// File name without the extension, used in the prompt tag
+	Name string `json:"name"`
+	// Alias from the model metadata, the same as Name when not set
+	Alias string `json:"alias"`
+}
+
+// GetLoRAModels returns the LoRA networks of the server. The endpoint is missing, so an error is returned,
+// when the built-in Lora extension is disabled
+func (api *apiImpl) GetLoRAModels(ctx context.Context) ([]*LoRAModel, error) {
+	body, status, err := api.get(ctx, "/sdapi/v1/loras")
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	var resp []*LoRAModel
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,75 @@
+package stable_diffusion_api
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures the API client created by NewWithOptions
+type Option func(api *apiImpl)
+
+// WithTimeout limits the duration of every request, including reading the response.
+// Keep it above the longest expected generation time
+func WithTimeout(timeout time.Duration) Option {
+	return func(api *apiImpl) {
+		api.client.Timeout = timeout
+	}
+}
+
+// WithBasicAuth authenticates requests as with the --api-auth webui flag
+func WithBasicAuth(username, password string) Option {
+	return func(api *apiImpl) {
+		api.username = username
+		api.password = password
+	}
+}
+
+// WithBearerToken sends the token in the Authorization header, e.g. for a reverse proxy
+func WithBearerToken(token string) Option {
+	return func(api *apiImpl) {
+		api.bearerToken = token
+	}
+}
+
+// WithAPIKey sends the key in the X-Api-Key header
+func WithAPIKey(key string) Option {
+	return func(api *apiImpl) {
+		api.apiKey = key
+	}
+}
+
+// WithUserAgent replaces DefaultUserAgent
+func WithUserAgent(userAgent string) Option {
+	return func(api *apiImpl) {
+		api.userAgent = userAgent
+	}
+}
+
+// WithMaxRetries retries requests that couldn't connect to the server up to n times, with exponential backoff.
+// Only dial errors are retried: after a connection is made, the server may have received the request,
+// and retrying it could generate twice
+func WithMaxRetries(n int) Option {
+	return func(api *apiImpl) {
+		api.client.Transport = &retryTransport{inner: api.client.Transport, maxRetries: n}
+	}
+}
+
+// NewWithOptions creates the API client with defaults adjusted by the options, as an alternative to New
+func NewWithOptions(host string, opts ...Option) (StableDiffusionAPI, error) {
+	pool, err := newHostPool([]string{host})
+	if err != nil {
+		return nil, err
+	}
+
+	api := &apiImpl{
+		hosts:     pool,
+		client:    &http.Client{Transport: &hostPoolTransport{inner: http.DefaultTransport, pool: pool}},
+		userAgent: DefaultUserAgent,
+	}
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	return api, nil
+}
@@ -0,0 +1,66 @@
+package stable_diffusion_api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RegionalPrompterExtension is the name of the regional prompter extension repository
+// https://github.com/hako-mikan/sd-webui-regional-prompter
+const RegionalPrompterExtension = "sd-webui-regional-prompter"
+
+const regionalPrompterScript = "Regional Prompter"
+
+// HasExtension reports whether the extension with the given name is installed and enabled
+func HasExtension(extensions []*Extension, name string) bool {
+	for _, extension := range extensions {
+		if extension.Enabled && strings.EqualFold(extension.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type RegionalPrompterOptions struct {
+	// Split the image into rows (top/bottom) instead of columns (left/right)
+	Rows bool
+	// Region size ratios, e.g. "1,1"
+	Ratios string
+	// Weight of the base prompt, used only when UseBase is true
+	BaseRatio float64
+	UseBase   bool
+}
+
+// RegionalPrompterScripts returns the alwayson_scripts value enabling the regional prompter in matrix mode.
+// Regions in the prompt are separated by BREAK, the base prompt by ADDBASE.
+func RegionalPrompterScripts(opts RegionalPrompterOptions) map[string]interface{} {
+	split := "Columns"
+	if opts.Rows {
+		split = "Rows"
+	}
+
+	return map[string]interface{}{
+		regionalPrompterScript: map[string]interface{}{
+			"args": []interface{}{
+				true,     // active
+				false,    // debug
+				"Matrix", // mode
+				split,    // matrix split mode
+				"Mask",   // mask mode
+				"Prompt", // prompt mode
+				opts.Ratios,
+				strconv.FormatFloat(opts.BaseRatio, 'f', -1, 64),
+				opts.UseBase,
+				false,       // use common prompt
+				false,       // use common negative prompt
+				"Attention", // calculation mode
+				false,       // not change AND
+				"0",         // LoRA text encoder
+				"0",         // LoRA U-Net
+				"0",         // threshold
+				"",          // mask
+			},
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package stable_diffusion_api
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Delay before the first retry, doubled for every further attempt
+const retryBaseDelay = 500 * time.Millisecond
+
+// retryTransport repeats requests that failed to connect to the server, e.g. while the webui restarts.
+// Other errors may happen after the server received the request, so they are returned as is
+type retryTransport struct {
+	inner      http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+
+	for attempt := 1; err != nil && isDialError(err) && attempt <= t.maxRetries; attempt++ {
+		// Every attempt gets its own copy, the caller's request must not be modified
+		retry := req.Clone(req.Context())
+
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return nil, err
+			}
+
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+
+			retry.Body = body
+		}
+
+		log.Printf("API request %s %s failed: %v, retrying (%d/%d)", req.Method, req.URL, err, attempt, t.maxRetries)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(retryBaseDelay << (attempt - 1)):
+		}
+
+		resp, err = t.inner.RoundTrip(retry)
+	}
+
+	return resp, err
+}
+
+// isDialError reports whether the request failed while connecting, before anything was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
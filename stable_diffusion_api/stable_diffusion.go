@@ -2,35 +2,139 @@ package stable_diffusion_api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const DefaultUserAgent = "stable-diffusion-discord-bot/1.0"
+
 type apiImpl struct {
-	host string
+	hosts       *hostPool
+	client      *http.Client
+	userAgent   string
+	apiKey      string
+	username    string
+	password    string
+	bearerToken string
 }
 
 type Config struct {
 	Host string
+	// Further webui instances, e.g. one per GPU. Requests are distributed round-robin over Host and Hosts
+	Hosts []string
+	// Log every API request and response in full
+	DevelopmentMode bool
+	// User-Agent header sent with every request, DefaultUserAgent when empty
+	UserAgent string
+	// API key sent in the X-Api-Key header, for deployments behind an API key gateway.
+	// It is sent along with basic auth or the bearer token, which are mutually exclusive
+	APIKey string
+	// Credentials of the --api-auth webui flag, sent as basic auth when both are set
+	Username string
+	Password string
+	// Token sent in the Authorization header, for deployments behind a reverse proxy
+	BearerToken string
+	// Proxy for API requests, e.g. "http://proxy:3128" or "socks5://proxy:1080".
+	// The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used when empty
+	ProxyURL string
+	// Limit for every request including reading the response, no limit when 0.
+	// Keep it above the longest expected generation time
+	RequestTimeout time.Duration
+	// Retries of requests failed with a connection error, with exponential backoff from retryBaseDelay
+	MaxRetries int
 }
 
 func New(cfg Config) (StableDiffusionAPI, error) {
-	if cfg.Host == "" {
-		return nil, errors.New("missing host")
+	hosts := cfg.Hosts
+
+	if cfg.Host != "" {
+		hosts = append([]string{cfg.Host}, hosts...)
 	}
 
-	// remove trailing slash
-	if cfg.Host[len(cfg.Host)-1:] == "/" {
-		cfg.Host = cfg.Host[:len(cfg.Host)-1]
+	pool, err := newHostPool(hosts)
+	if err != nil {
+		return nil, err
 	}
 
-	return &apiImpl{
-		host: cfg.Host,
-	}, nil
+	if cfg.Username != "" && cfg.Password != "" && cfg.BearerToken != "" {
+		return nil, errors.New("basic auth and bearer token are mutually exclusive")
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+
+		proxyTransport := http.DefaultTransport.(*http.Transport).Clone()
+		proxyTransport.Proxy = http.ProxyURL(proxyURL)
+
+		transport = proxyTransport
+	}
+
+	if cfg.DevelopmentMode {
+		transport = &loggingTransport{inner: transport}
+	}
+
+	transport = &hostPoolTransport{inner: transport, pool: pool}
+
+	if cfg.MaxRetries > 0 {
+		transport = &retryTransport{inner: transport, maxRetries: cfg.MaxRetries}
+	}
+
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultUserAgent
+	}
+
+	api := &apiImpl{
+		hosts:     pool,
+		client:    &http.Client{Transport: transport, Timeout: cfg.RequestTimeout},
+		userAgent: cfg.UserAgent,
+		apiKey:    cfg.APIKey,
+	}
+
+	if cfg.Username != "" && cfg.Password != "" {
+		api.username = cfg.Username
+		api.password = cfg.Password
+	} else {
+		api.bearerToken = cfg.BearerToken
+	}
+
+	return api, nil
+}
+
+// newRequest creates a request with the headers common to all API calls
+func (api *apiImpl) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("User-Agent", api.userAgent)
+
+	if api.apiKey != "" {
+		request.Header.Set("X-Api-Key", api.apiKey)
+	}
+
+	if api.username != "" {
+		request.SetBasicAuth(api.username, api.password)
+	} else if api.bearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+api.bearerToken)
+	}
+
+	return request, nil
 }
 
 type jsonTextToImageResponse struct {
@@ -49,6 +153,9 @@ type TextToImageResponse struct {
 	Seeds    []int    `json:"seeds"`
 	Subseeds []int    `json:"subseeds"`
 	Model    string   `json:"model"`
+	// GenerationTimeMs is measured from sending the request to parsing the response, so it includes
+	// the network overhead, the API doesn't report the time spent on the server
+	GenerationTimeMs int64 `json:"generation_time_ms"`
 }
 
 type Txt2ImgOverrideSettings struct {
@@ -59,6 +166,12 @@ type Txt2ImgOverrideSettings struct {
 	SamplesFormat string `json:"samples_format,omitempty"`
 	// new option since 04/29/2023 https://github.com/AUTOMATIC1111/stable-diffusion-webui/pull/9177
 	NegativeGuidanceMinimumSigma float32 `json:"s_min_uncond,omitempty"`
+	// Token merging (ToMe) ratio, 0 disables merging. Nil leaves the server option untouched
+	TokenMergingRatio *float64 `json:"token_merging_ratio,omitempty"`
+	// CodeFormer fidelity weight used by restore faces, 0 = maximum quality enhancement, 1 = maximum fidelity
+	CodeFormerWeight *float64 `json:"code_former_weight,omitempty"`
+	// CLIP layers skipped from the end, 0 keeps the server option
+	ClipSkip int `json:"CLIP_stop_at_last_layers,omitempty"`
 
 	// this is in blacklist. See stable-diffusion-webui/modules/shared.py:124:restricted_opts
 	OutdirTxt2ImgSamples string `json:"outdir_txt2img_samples,omitempty"`
@@ -85,34 +198,83 @@ type TextToImageRequest struct {
 	CfgScale          float64 `json:"cfg_scale"`
 	Steps             int     `json:"steps"`
 	NIter             int     `json:"n_iter"`
+	// Amount of initial noise, 1.0 by default. Lower values give less varied but more stable images
+	InitialNoiseMultiplier float64 `json:"initial_noise_multiplier,omitempty"`
+	// Seamlessly tileable image, e.g. for textures
+	Tiling bool `json:"tiling"`
 
 	// Save sample images AND grid copies to output dir
 	SaveImages       bool                    `json:"save_images"`
 	OverrideSettings Txt2ImgOverrideSettings `json:"override_settings"`
+	// Arguments for scripts of extensions, keyed by script title
+	AlwaysonScripts map[string]interface{} `json:"alwayson_scripts,omitempty"`
+}
+
+const defaultHrScale = 2.0
+
+// Validate checks the request and fills hires fix parameters the API would otherwise default silently
+func (req *TextToImageRequest) Validate() error {
+	if req.Steps < 1 {
+		return fmt.Errorf("invalid steps count: %d", req.Steps)
+	}
+
+	if req.BatchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", req.BatchSize)
+	}
+
+	if req.NIter < 1 {
+		return fmt.Errorf("invalid iterations count: %d", req.NIter)
+	}
+
+	if req.EnableHR {
+		if req.HrScale == 0 {
+			log.Printf("Warning: hires fix is enabled without hr_scale, using %v", defaultHrScale)
+
+			req.HrScale = defaultHrScale
+		}
+
+		if req.HrSecondPassSteps == 0 {
+			log.Printf("Warning: hires fix is enabled without hr_second_pass_steps, using %d", req.Steps/2)
+
+			req.HrSecondPassSteps = req.Steps / 2
+		}
+	}
+
+	return nil
 }
 
-func (api *apiImpl) TextToImage(req *TextToImageRequest) (*TextToImageResponse, error) {
+func (api *apiImpl) TextToImage(ctx context.Context, req *TextToImageRequest) (*TextToImageResponse, error) {
 	if req == nil {
 		return nil, errors.New("missing request")
 	}
 
-	postURL := api.host + "/sdapi/v1/txt2img"
+	err := req.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	postURL := api.hosts.nextGeneration() + "/sdapi/v1/txt2img"
 
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := http.NewRequest("POST", postURL, bytes.NewBuffer(jsonData))
+	return api.generate(ctx, postURL, jsonData)
+}
+
+// generate posts the request to the txt2img or img2img endpoint, their responses have the same format
+func (api *apiImpl) generate(ctx context.Context, postURL string, jsonData []byte) (*TextToImageResponse, error) {
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
-	client := &http.Client{}
+	startTime := time.Now()
 
-	response, err := client.Do(request)
+	response, err := api.client.Do(request)
 	if err != nil {
 		log.Printf("API URL: %s", postURL)
 		log.Printf("Error with API Request: %s", string(jsonData))
@@ -122,7 +284,36 @@ func (api *apiImpl) TextToImage(req *TextToImageRequest) (*TextToImageResponse,
 
 	defer response.Body.Close()
 
-	body, _ := io.ReadAll(response.Body)
+	// Responses with the base64 images are large, so the connection may drop mid-transfer
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Error reading API response after %d bytes: %v", len(body), err)
+
+		return nil, err
+	}
+
+	if response.ContentLength >= 0 && response.ContentLength != int64(len(body)) {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Truncated API response: got %d bytes of %d", len(body), response.ContentLength)
+
+		return nil, fmt.Errorf("truncated API response: got %d bytes of %d", len(body), response.ContentLength)
+	}
+
+	// Failed generations, e.g. out of VRAM, come with the reason in the body
+	if response.StatusCode != http.StatusOK {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, fmt.Errorf("unexpected API status %d: %s", response.StatusCode, body)
+	}
+
+	if !json.Valid(body) {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Invalid JSON in API response of %d bytes", len(body))
+
+		return nil, fmt.Errorf("invalid JSON in API response of %d bytes", len(body))
+	}
 
 	respStruct := &jsonTextToImageResponse{}
 
@@ -149,6 +340,8 @@ func (api *apiImpl) TextToImage(req *TextToImageRequest) (*TextToImageResponse,
 		Seeds:    infoStruct.AllSeeds,
 		Subseeds: infoStruct.AllSubseeds,
 		Model:    extractModel(respStruct.Info),
+
+		GenerationTimeMs: time.Since(startTime).Milliseconds(),
 	}, nil
 }
 
@@ -160,29 +353,60 @@ func extractModel(infoJson string) string {
 	return string(modelRegex.Find([]byte(infoJson)))
 }
 
+// Resize modes of the extras API
+const (
+	ResizeModeMultiplier = 0
+	ResizeModeDimensions = 1
+)
+
 type UpscaleRequest struct {
-	ResizeMode         int                 `json:"resize_mode"`
-	UpscalingResize    int                 `json:"upscaling_resize"`
-	Upscaler1          string              `json:"upscaler1"`
-	TextToImageRequest *TextToImageRequest `json:"text_to_image_request"`
+	ResizeMode      int `json:"resize_mode"`
+	UpscalingResize int `json:"upscaling_resize"`
+	// Exact target dimensions, used instead of the UpscalingResize multiplier when set
+	UpscalingResizeWidth  int                 `json:"upscaling_resize_w"`
+	UpscalingResizeHeight int                 `json:"upscaling_resize_h"`
+	Upscaler1             string              `json:"upscaler1"`
+	TextToImageRequest    *TextToImageRequest `json:"text_to_image_request"`
+}
+
+// Validate checks the target dimensions and switches to ResizeModeDimensions when they are set
+func (req *UpscaleRequest) Validate() error {
+	if req.UpscalingResizeWidth == 0 && req.UpscalingResizeHeight == 0 {
+		return nil
+	}
+
+	if req.UpscalingResizeWidth <= 0 || req.UpscalingResizeHeight <= 0 {
+		return fmt.Errorf("invalid upscale dimensions: %dx%d", req.UpscalingResizeWidth, req.UpscalingResizeHeight)
+	}
+
+	req.ResizeMode = ResizeModeDimensions
+
+	return nil
 }
 
 type upscaleJSONRequest struct {
-	ResizeMode      int    `json:"resize_mode"`
-	UpscalingResize int    `json:"upscaling_resize"`
-	Upscaler1       string `json:"upscaler_1"`
-	Image           string `json:"image"`
+	ResizeMode            int    `json:"resize_mode"`
+	UpscalingResize       int    `json:"upscaling_resize"`
+	UpscalingResizeWidth  int    `json:"upscaling_resize_w,omitempty"`
+	UpscalingResizeHeight int    `json:"upscaling_resize_h,omitempty"`
+	Upscaler1             string `json:"upscaler_1"`
+	Image                 string `json:"image"`
 }
 
 type UpscaleResponse struct {
 	Image string `json:"image"`
 }
 
-func (api *apiImpl) UpscaleImage(upscaleReq *UpscaleRequest) (*UpscaleResponse, error) {
+func (api *apiImpl) UpscaleImage(ctx context.Context, upscaleReq *UpscaleRequest) (*UpscaleResponse, error) {
 	if upscaleReq == nil {
 		return nil, errors.New("missing request")
 	}
 
+	err := upscaleReq.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	textToImageReq := upscaleReq.TextToImageRequest
 
 	if textToImageReq == nil {
@@ -191,35 +415,35 @@ func (api *apiImpl) UpscaleImage(upscaleReq *UpscaleRequest) (*UpscaleResponse,
 
 	textToImageReq.NIter = 1
 
-	regeneratedImage, err := api.TextToImage(textToImageReq)
+	regeneratedImage, err := api.TextToImage(ctx, textToImageReq)
 	if err != nil {
 		return nil, err
 	}
 
 	jsonReq := &upscaleJSONRequest{
-		ResizeMode:      upscaleReq.ResizeMode,
-		UpscalingResize: upscaleReq.UpscalingResize,
-		Upscaler1:       upscaleReq.Upscaler1,
-		Image:           regeneratedImage.Images[0],
+		ResizeMode:            upscaleReq.ResizeMode,
+		UpscalingResize:       upscaleReq.UpscalingResize,
+		UpscalingResizeWidth:  upscaleReq.UpscalingResizeWidth,
+		UpscalingResizeHeight: upscaleReq.UpscalingResizeHeight,
+		Upscaler1:             upscaleReq.Upscaler1,
+		Image:                 regeneratedImage.Images[0],
 	}
 
-	postURL := api.host + "/sdapi/v1/extra-single-image"
+	postURL := api.hosts.nextGeneration() + "/sdapi/v1/extra-single-image"
 
 	jsonData, err := json.Marshal(jsonReq)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := http.NewRequest("POST", postURL, bytes.NewBuffer(jsonData))
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
-	client := &http.Client{}
-
-	response, err := client.Do(request)
+	response, err := api.client.Do(request)
 	if err != nil {
 		log.Printf("API URL: %s", postURL)
 		log.Printf("Error with API Request: %s", string(jsonData))
@@ -244,22 +468,30 @@ func (api *apiImpl) UpscaleImage(upscaleReq *UpscaleRequest) (*UpscaleResponse,
 	return respStruct, nil
 }
 
+type ProgressState struct {
+	Job           string `json:"job"`
+	JobCount      int    `json:"job_count"`
+	SamplingStep  int    `json:"sampling_step"`
+	SamplingSteps int    `json:"sampling_steps"`
+}
+
 type ProgressResponse struct {
-	Progress    float64 `json:"progress"`
-	EtaRelative float64 `json:"eta_relative"`
+	Progress    float64       `json:"progress"`
+	EtaRelative float64       `json:"eta_relative"`
+	State       ProgressState `json:"state"`
+	// Base64 encoded image of the current denoising step, empty when skipped or not generated yet
+	CurrentImage string `json:"current_image"`
 }
 
-func (api *apiImpl) GetCurrentProgress() (*ProgressResponse, error) {
-	getURL := api.host + "/sdapi/v1/progress"
+func (api *apiImpl) GetCurrentProgress(ctx context.Context, skipImage bool) (*ProgressResponse, error) {
+	getURL := api.hosts.progress() + "/sdapi/v1/progress?skip_current_image=" + strconv.FormatBool(skipImage)
 
-	request, err := http.NewRequest("GET", getURL, bytes.NewBuffer([]byte{}))
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
 	if err != nil {
 		return nil, err
 	}
 
-	client := &http.Client{}
-
-	response, err := client.Do(request)
+	response, err := api.client.Do(request)
 	if err != nil {
 		log.Printf("API URL: %s", getURL)
 		log.Printf("Error with API Request: %v", err)
@@ -284,6 +516,35 @@ func (api *apiImpl) GetCurrentProgress() (*ProgressResponse, error) {
 	return respStruct, nil
 }
 
+// Interrupt stops the current generation of every host. The interrupted request still returns the images
+// generated so far
+func (api *apiImpl) Interrupt(ctx context.Context) error {
+	for _, host := range api.hosts.all() {
+		postURL := host + "/sdapi/v1/interrupt"
+
+		request, err := api.newRequest(ctx, "POST", postURL, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := api.client.Do(request)
+		if err != nil {
+			log.Printf("API URL: %s", postURL)
+			log.Printf("Error with API Request: %v", err)
+
+			return err
+		}
+
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+		}
+	}
+
+	return nil
+}
+
 type Embedding struct {
 	// The number of steps that were used to train this embedding, if available
 	//Step int `json:"step"`
@@ -313,17 +574,15 @@ type EmbeddingsResponseRaw struct {
 	Skipped map[string]json.RawMessage
 }
 
-func (api *apiImpl) GetEmbeddings() (*EmbeddingsResponseMinimal, error) {
-	getURL := api.host + "/sdapi/v1/embeddings"
+func (api *apiImpl) GetEmbeddings(ctx context.Context) (*EmbeddingsResponseMinimal, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/embeddings"
 
-	request, err := http.NewRequest("GET", getURL, bytes.NewBuffer([]byte{}))
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
 	if err != nil {
 		return nil, err
 	}
 
-	client := &http.Client{}
-
-	response, err := client.Do(request)
+	response, err := api.client.Do(request)
 	if err != nil {
 		log.Printf("API URL: %s", getURL)
 		log.Printf("Error with API Request: %v", err)
@@ -347,3 +606,469 @@ func (api *apiImpl) GetEmbeddings() (*EmbeddingsResponseMinimal, error) {
 
 	return resp, nil
 }
+
+// GetEmbeddingsFull returns the embeddings with the checkpoints they were trained on
+func (api *apiImpl) GetEmbeddingsFull(ctx context.Context) (*EmbeddingsResponse, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/embeddings"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &EmbeddingsResponse{}
+
+	err = json.Unmarshal(body, resp)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type SDOptions struct {
+	// Token merging (ToMe) ratio. 0.0 disables merging, 0.5 is the maximum merger
+	TokenMergingRatio float64 `json:"token_merging_ratio"`
+	// Title of the loaded checkpoint, e.g. "v1-5-pruned-emaonly.safetensors [6ce0161689]"
+	SDModelCheckpoint string `json:"sd_model_checkpoint,omitempty"`
+	// Name of the loaded VAE, VAEAutomatic or VAENone
+	SDVAE string `json:"sd_vae,omitempty"`
+}
+
+func (api *apiImpl) GetSDOptions(ctx context.Context) (*SDOptions, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/options"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	respStruct := &SDOptions{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return respStruct, nil
+}
+
+// SetSDOptions updates the global server options. All fields of SDOptions are sent,
+// so callers should start from the result of GetSDOptions.
+func (api *apiImpl) SetSDOptions(ctx context.Context, options *SDOptions) error {
+	if options == nil {
+		return errors.New("missing options")
+	}
+
+	return api.postOptions(ctx, options)
+}
+
+// GetCurrentModel returns the title of the loaded checkpoint
+func (api *apiImpl) GetCurrentModel(ctx context.Context) (string, error) {
+	options, err := api.GetSDOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return options.SDModelCheckpoint, nil
+}
+
+// SetModel loads the checkpoint with the given title or name. The call blocks until the model is loaded
+func (api *apiImpl) SetModel(ctx context.Context, model string) error {
+	if model == "" {
+		return errors.New("missing model")
+	}
+
+	return api.postOptions(ctx, map[string]string{"sd_model_checkpoint": model})
+}
+
+// postOptions sends the options to every host, so they all generate with the same model and settings
+func (api *apiImpl) postOptions(ctx context.Context, options interface{}) error {
+	jsonData, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	for _, host := range api.hosts.all() {
+		err = api.postOptionsTo(ctx, host, jsonData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (api *apiImpl) postOptionsTo(ctx context.Context, host string, jsonData []byte) error {
+	postURL := host + "/sdapi/v1/options"
+
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Error with API Request: %s", string(jsonData))
+
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+type Extension struct {
+	Name    string `json:"name"`
+	Remote  string `json:"remote"`
+	Branch  string `json:"branch"`
+	Version string `json:"version"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (api *apiImpl) GetExtensions(ctx context.Context) ([]*Extension, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/extensions"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	var resp []*Extension
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type pngInfoJSONRequest struct {
+	Image string `json:"image"`
+}
+
+type PNGInfoResponse struct {
+	// Generation parameters in the A1111 infotext format
+	Info  string            `json:"info"`
+	Items map[string]string `json:"items"`
+}
+
+func (api *apiImpl) GetPNGInfo(ctx context.Context, imageBase64 string) (*PNGInfoResponse, error) {
+	if imageBase64 == "" {
+		return nil, errors.New("missing image")
+	}
+
+	postURL := api.hosts.next() + "/sdapi/v1/png-info"
+
+	jsonData, err := json.Marshal(&pngInfoJSONRequest{Image: imageBase64})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	respStruct := &PNGInfoResponse{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return respStruct, nil
+}
+
+type interrogateJSONRequest struct {
+	Image string `json:"image"`
+	// clip or deepdanbooru
+	Model string `json:"model"`
+}
+
+type interrogateJSONResponse struct {
+	Caption string `json:"caption"`
+}
+
+func (api *apiImpl) Interrogate(ctx context.Context, imageBase64, model string) (string, error) {
+	if imageBase64 == "" {
+		return "", errors.New("missing image")
+	}
+
+	postURL := api.hosts.next() + "/sdapi/v1/interrogate"
+
+	jsonData, err := json.Marshal(&interrogateJSONRequest{Image: imageBase64, Model: model})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	respStruct := &interrogateJSONResponse{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return "", err
+	}
+
+	return respStruct.Caption, nil
+}
+
+type Upscaler struct {
+	Name      string  `json:"name"`
+	ModelName string  `json:"model_name"`
+	ModelPath string  `json:"model_path"`
+	ModelURL  string  `json:"model_url"`
+	Scale     float64 `json:"scale"`
+}
+
+func (api *apiImpl) GetUpscalers(ctx context.Context) ([]*Upscaler, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/upscalers"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	var resp []*Upscaler
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type SDModel struct {
+	// Name with the short hash, e.g. "v1-5-pruned-emaonly.safetensors [6ce0161689]"
+	Title     string `json:"title"`
+	ModelName string `json:"model_name"`
+	Hash      string `json:"hash"`
+	Filename  string `json:"filename"`
+}
+
+// GetModels returns the checkpoints available on the server
+func (api *apiImpl) GetModels(ctx context.Context) ([]*SDModel, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/sd-models"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	var resp []*SDModel
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// GetRealesrganModels returns only the neural (R-ESRGAN and ESRGAN) upscalers
+func (api *apiImpl) GetRealesrganModels(ctx context.Context) ([]string, error) {
+	upscalers, err := api.GetUpscalers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []string
+
+	for _, upscaler := range upscalers {
+		if strings.HasPrefix(upscaler.Name, "R-ESRGAN") || strings.HasPrefix(upscaler.Name, "ESRGAN") {
+			models = append(models, upscaler.Name)
+		}
+	}
+
+	return models, nil
+}
+
+type SamplerInfo struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// GetSamplers returns the samplers available on the server
+func (api *apiImpl) GetSamplers(ctx context.Context) ([]*SamplerInfo, error) {
+	getURL := api.hosts.next() + "/sdapi/v1/samplers"
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	var samplers []*SamplerInfo
+
+	err = json.Unmarshal(body, &samplers)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return samplers, nil
+}
+
+// GetSamplerAliases maps sampler names and their aliases (e.g. "k_euler") to the canonical sampler names
+func (api *apiImpl) GetSamplerAliases(ctx context.Context) (map[string]string, error) {
+	samplers, err := api.GetSamplers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+
+	for _, sampler := range samplers {
+		aliases[sampler.Name] = sampler.Name
+
+		for _, alias := range sampler.Aliases {
+			aliases[alias] = sampler.Name
+		}
+	}
+
+	return aliases, nil
+}
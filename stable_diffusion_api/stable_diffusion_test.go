@@ -0,0 +1,181 @@
+package stable_diffusion_api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingServer answers every request with an empty JSON object and sends the request headers to the channel
+func recordingServer(t *testing.T) (*httptest.Server, <-chan http.Header) {
+	t.Helper()
+
+	headers := make(chan http.Header, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, headers
+}
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want map[string]string
+	}{
+		{
+			name: "default user agent",
+			want: map[string]string{"User-Agent": DefaultUserAgent, "X-Api-Key": "", "Authorization": ""},
+		},
+		{
+			name: "custom user agent",
+			cfg:  Config{UserAgent: "my-bot/2.0"},
+			want: map[string]string{"User-Agent": "my-bot/2.0"},
+		},
+		{
+			name: "api key",
+			cfg:  Config{APIKey: "secret"},
+			want: map[string]string{"User-Agent": DefaultUserAgent, "X-Api-Key": "secret", "Authorization": ""},
+		},
+		{
+			name: "bearer token",
+			cfg:  Config{BearerToken: "token"},
+			want: map[string]string{"Authorization": "Bearer token"},
+		},
+		{
+			name: "basic auth",
+			cfg:  Config{Username: "user", Password: "pass"},
+			// base64 of user:pass
+			want: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, headers := recordingServer(t)
+
+			cfg := tt.cfg
+			cfg.Host = server.URL
+
+			api, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			_, err = api.GetEmbeddings(context.Background())
+			if err != nil {
+				t.Fatalf("GetEmbeddings() error = %v", err)
+			}
+
+			got := <-headers
+
+			for name, want := range tt.want {
+				if value := got.Get(name); value != want {
+					t.Errorf("header %s = %q, want %q", name, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRejectsBasicAuthWithBearerToken(t *testing.T) {
+	_, err := New(Config{Host: "http://localhost", Username: "user", Password: "pass", BearerToken: "token"})
+	if err == nil {
+		t.Error("New() error = nil, want an error for mutually exclusive auth modes")
+	}
+}
+
+func TestAPIKeyOnEveryRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "api key only", cfg: Config{APIKey: "secret"}},
+		{name: "api key with basic auth", cfg: Config{APIKey: "secret", Username: "user", Password: "pass"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, headers := recordingServer(t)
+
+			cfg := tt.cfg
+			cfg.Host = server.URL
+
+			api, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			requests := map[string]func() error{
+				"GET": func() error {
+					_, err := api.GetEmbeddings(context.Background())
+
+					return err
+				},
+				"POST": func() error {
+					return api.SetModel(context.Background(), "model.safetensors")
+				},
+			}
+
+			for method, request := range requests {
+				if err := request(); err != nil {
+					t.Fatalf("%s request error = %v", method, err)
+				}
+
+				got := <-headers
+
+				if value := got.Get("X-Api-Key"); value != "secret" {
+					t.Errorf("%s request X-Api-Key = %q, want %q", method, value, "secret")
+				}
+
+				_, _, hasBasicAuth := (&http.Request{Header: got}).BasicAuth()
+				if wantBasicAuth := cfg.Username != ""; hasBasicAuth != wantBasicAuth {
+					t.Errorf("%s request has basic auth = %v, want %v", method, hasBasicAuth, wantBasicAuth)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	proxied := make(chan string, 1)
+
+	// A forward proxy receives the absolute URL of the target in the request line
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	// The webui is never reached directly, the host only has to be a valid URL
+	api, err := New(Config{Host: "http://webui.invalid:7860", ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, err = api.GetEmbeddings(context.Background())
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+
+	want := "http://webui.invalid:7860/sdapi/v1/embeddings"
+	if got := <-proxied; got != want {
+		t.Errorf("proxied URL = %q, want %q", got, want)
+	}
+}
+
+func TestInvalidProxyURL(t *testing.T) {
+	_, err := New(Config{Host: "http://localhost:7860", ProxyURL: "://invalid"})
+	if err == nil {
+		t.Error("New() error = nil, want an error for the invalid proxy URL")
+	}
+}
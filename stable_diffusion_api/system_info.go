@@ -0,0 +1,153 @@
+package stable_diffusion_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+type SystemInfo struct {
+	GpuName string
+	// VRAM in bytes, 0 when unknown
+	VramTotal       int64
+	VramFree        int64
+	TorchVersion    string
+	PythonVersion   string
+	XformersEnabled bool
+}
+
+type jsonSystemInfo struct {
+	Version struct {
+		Python string `json:"python"`
+		Torch  string `json:"torch"`
+	} `json:"version"`
+	GPU struct {
+		Device string `json:"device"`
+	} `json:"gpu"`
+	Memory struct {
+		GPU struct {
+			Total int64 `json:"total"`
+			Free  int64 `json:"free"`
+		} `json:"gpu"`
+	} `json:"memory"`
+	CrossAttention string `json:"crossattention"`
+}
+
+type jsonCmdFlags struct {
+	Xformers bool `json:"xformers"`
+}
+
+// GetSystemInfo returns the server environment. Servers without /sdapi/v1/system-info only report
+// the xformers flag from /sdapi/v1/cmd-flags
+func (api *apiImpl) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
+	body, status, err := api.get(ctx, "/sdapi/v1/system-info")
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return api.getSystemInfoFromCmdFlags(ctx)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	respStruct := &jsonSystemInfo{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return &SystemInfo{
+		GpuName:         respStruct.GPU.Device,
+		VramTotal:       respStruct.Memory.GPU.Total,
+		VramFree:        respStruct.Memory.GPU.Free,
+		TorchVersion:    respStruct.Version.Torch,
+		PythonVersion:   respStruct.Version.Python,
+		XformersEnabled: respStruct.CrossAttention == "xformers",
+	}, nil
+}
+
+func (api *apiImpl) getSystemInfoFromCmdFlags(ctx context.Context) (*SystemInfo, error) {
+	body, status, err := api.get(ctx, "/sdapi/v1/cmd-flags")
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	respStruct := &jsonCmdFlags{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return &SystemInfo{XformersEnabled: respStruct.Xformers}, nil
+}
+
+// get requests the API path, returning the body and the status code
+func (api *apiImpl) get(ctx context.Context, path string) ([]byte, int, error) {
+	getURL := api.hosts.next() + path
+
+	request, err := api.newRequest(ctx, "GET", getURL, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", getURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return nil, 0, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, response.StatusCode, nil
+}
+
+// WebUIAvailable reports whether the web UI is served next to the API. It isn't when the webui is started
+// with --nowebui, the API works as usual then
+func (api *apiImpl) WebUIAvailable(ctx context.Context) bool {
+	_, status, err := api.get(ctx, "/")
+	if err != nil || status == http.StatusNotFound {
+		log.Printf("SD running in API-only mode")
+
+		return false
+	}
+
+	return true
+}
+
+// HealthCheck reports an error when the API doesn't respond. cmd-flags is cheap and available in all API versions
+func (api *apiImpl) HealthCheck(ctx context.Context) error {
+	_, status, err := api.get(ctx, "/sdapi/v1/cmd-flags")
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package stable_diffusion_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The count is shown in the interaction response, which has to be sent within 3 seconds
+const tokenCounterTimeout = time.Second
+
+type tokenCounterRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+type tokenCounterResponse struct {
+	TokenCount int `json:"token_count"`
+}
+
+// CountTokens returns the number of CLIP tokens in the prompt
+func (api *apiImpl) CountTokens(ctx context.Context, prompt string) (int, error) {
+	postURL := api.hosts.next() + "/sdapi/v1/token-counter"
+
+	jsonData, err := json.Marshal(&tokenCounterRequest{Prompt: prompt})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tokenCounterTimeout)
+	defer cancel()
+
+	request, err := api.newRequest(ctx, "POST", postURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Error with API Request: %v", err)
+
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected API status %d: %s", response.StatusCode, body)
+	}
+
+	respStruct := &tokenCounterResponse{}
+
+	err = json.Unmarshal(body, respStruct)
+	if err != nil {
+		log.Printf("API URL: %s", postURL)
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return 0, err
+	}
+
+	return respStruct.TokenCount, nil
+}
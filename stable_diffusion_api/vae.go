@@ -0,0 +1,56 @@
+package stable_diffusion_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Special values of the sd_vae option besides the VAE names
+const (
+	// VAE with the same name as the checkpoint, or the one baked into it
+	VAEAutomatic = "Automatic"
+	// The VAE baked into the checkpoint
+	VAENone = "None"
+)
+
+type VAEInfo struct {
+	// Name for SetVAE, e.g. "vae-ft-mse-840000-ema-pruned.safetensors"
+	ModelName string `json:"model_name"`
+	Filename  string `json:"filename"`
+}
+
+// GetVAEs returns the VAEs available on the server
+func (api *apiImpl) GetVAEs(ctx context.Context) ([]*VAEInfo, error) {
+	body, status, err := api.get(ctx, "/sdapi/v1/sd-vae")
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	var resp []*VAEInfo
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		log.Printf("Unexpected API response: %s", string(body))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// SetVAE loads the VAE with the given name, VAEAutomatic or VAENone
+func (api *apiImpl) SetVAE(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New("missing VAE")
+	}
+
+	return api.postOptions(ctx, map[string]string{"sd_vae": name})
+}